@@ -0,0 +1,64 @@
+// sorm-gen 读取库中已有的表, 生成带sorm标签的结构体
+//
+//	sorm-gen -driver postgres -conn '{"user":"postgres","database":"test"}' -table user,order -pkg model -o model.go
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/suboat/sorm"
+	_ "github.com/suboat/sorm/driver/mongo"
+	_ "github.com/suboat/sorm/driver/mssql"
+	_ "github.com/suboat/sorm/driver/mysql"
+	_ "github.com/suboat/sorm/driver/pg"
+	_ "github.com/suboat/sorm/driver/sqlite"
+)
+
+var (
+	flagDriver = flag.String("driver", orm.DriverNamePostgres, "驱动名: postgres, mysql, mssql, sqlite3, mongo")
+	flagConn   = flag.String("conn", "", "连接参数, 与orm.New相同的json")
+	flagTable  = flag.String("table", "", "表名, 多个用逗号分隔")
+	flagPkg    = flag.String("pkg", "model", "生成文件的包名")
+	flagOut    = flag.String("o", "", "输出文件, 为空时输出到标准输出")
+)
+
+func main() {
+	flag.Parse()
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, "sorm-gen:", err)
+		os.Exit(1)
+	}
+}
+
+func run() (err error) {
+	var (
+		db     orm.Database
+		src    []byte
+		tables []string
+	)
+	for _, t := range strings.Split(*flagTable, ",") {
+		if t = strings.TrimSpace(t); len(t) > 0 {
+			tables = append(tables, t)
+		}
+	}
+	if len(tables) == 0 {
+		flag.Usage()
+		return orm.ErrDbParamsEmpty
+	}
+	if db, err = orm.New(*flagDriver, *flagConn); err != nil {
+		return
+	}
+	defer db.Close()
+	if src, err = orm.GenFile(db, *flagPkg, tables...); err != nil {
+		return
+	}
+	if len(*flagOut) == 0 {
+		_, err = os.Stdout.Write(src)
+		return
+	}
+	return ioutil.WriteFile(*flagOut, src, 0644)
+}
@@ -0,0 +1,209 @@
+package orm
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Cursor 游标分页的位置, 编码后作为Meta.Next/Meta.Prev返回给调用方
+type Cursor struct {
+	Values []interface{} `json:"v"`           // 排序字段的取值, 与Sort字段一一对应
+	Prev   bool          `json:"p,omitempty"` // 向前翻页
+}
+
+// cursorTime 游标中的时间值, 以类型标记区分于字符串, 保留纳秒精度及时区
+type cursorTime struct {
+	T string `json:"t"`
+}
+
+// CursorEncode 编码游标
+func CursorEncode(c *Cursor) (token string) {
+	if c == nil || len(c.Values) == 0 {
+		return
+	}
+	enc := &Cursor{Values: make([]interface{}, len(c.Values)), Prev: c.Prev}
+	for i, v := range c.Values {
+		if t, ok := v.(time.Time); ok {
+			v = cursorTime{T: t.Format(time.RFC3339Nano)}
+		}
+		enc.Values[i] = v
+	}
+	if b, err := json.Marshal(enc); err == nil {
+		token = base64.RawURLEncoding.EncodeToString(b)
+	}
+	return
+}
+
+// CursorDecode 解码游标, 数字保留整数精度, 时间还原为time.Time
+func CursorDecode(token string) (c *Cursor, err error) {
+	var b []byte
+	if b, err = base64.RawURLEncoding.DecodeString(token); err != nil {
+		return nil, ErrCursorInvalid
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	c = new(Cursor)
+	if err = dec.Decode(c); err != nil || len(c.Values) == 0 {
+		return nil, ErrCursorInvalid
+	}
+	for i, v := range c.Values {
+		switch _v := v.(type) {
+		case json.Number:
+			if n, _err := _v.Int64(); _err == nil {
+				c.Values[i] = n
+			} else if f, _err := _v.Float64(); _err == nil {
+				c.Values[i] = f
+			} else {
+				return nil, ErrCursorInvalid
+			}
+		case map[string]interface{}:
+			// 时间: {"t": RFC3339Nano}
+			s, ok := _v["t"].(string)
+			if !ok || len(_v) != 1 {
+				return nil, ErrCursorInvalid
+			}
+			t, _err := time.Parse(time.RFC3339Nano, s)
+			if _err != nil {
+				return nil, ErrCursorInvalid
+			}
+			c.Values[i] = t
+		case string, bool, nil:
+			break
+		default:
+			return nil, ErrCursorInvalid
+		}
+	}
+	return
+}
+
+// CursorSortField 解析排序字段, 返回小写字段名及是否降序
+func CursorSortField(s string) (field string, desc bool) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if strings.HasPrefix(s, "-") {
+		return s[1:], true
+	}
+	return strings.TrimPrefix(s, "+"), false
+}
+
+// CursorSortsReverse 翻转排序方向, 向前翻页时使用
+func CursorSortsReverse(sorts []string) (ret []string) {
+	for _, s := range sorts {
+		if f, desc := CursorSortField(s); desc {
+			ret = append(ret, f)
+		} else {
+			ret = append(ret, "-"+f)
+		}
+	}
+	return
+}
+
+// CursorFilter 按排序字段生成游标之后(向前翻页时为之前)的过滤条件
+// (a, b) > (va, vb) 展开为 a > va OR (a = va AND b > vb)
+func CursorFilter(sorts []string, c *Cursor) (ret M, err error) {
+	if c == nil || len(sorts) == 0 || len(sorts) != len(c.Values) {
+		return nil, ErrCursorInvalid
+	}
+	var lis []interface{}
+	for i := range sorts {
+		unit := make(map[string]interface{})
+		for j := 0; j < i; j++ {
+			f, _ := CursorSortField(sorts[j])
+			unit[f] = c.Values[j]
+		}
+		f, desc := CursorSortField(sorts[i])
+		if len(f) == 0 {
+			return nil, ErrCursorInvalid
+		}
+		if desc != c.Prev {
+			unit[f+TagValLt] = c.Values[i]
+		} else {
+			unit[f+TagValGt] = c.Values[i]
+		}
+		if len(unit) > 1 {
+			// $or$中同一map的条件按OR连接, 需以$and$包裹
+			lis = append(lis, map[string]interface{}{TagQueryKeyAnd: []interface{}{unit}})
+		} else {
+			lis = append(lis, unit)
+		}
+	}
+	ret = M{TagQueryKeyOr: lis}
+	return
+}
+
+// CursorValues 读取记录中排序字段的值, record为结构体或map
+func CursorValues(sorts []string, record interface{}) (ret []interface{}) {
+	var vals map[string]interface{}
+	switch r := record.(type) {
+	case M:
+		vals = r
+	case map[string]interface{}:
+		vals = r
+	default:
+		vals = make(map[string]interface{})
+		cursorStructValues(reflect.Indirect(reflect.ValueOf(record)), vals)
+	}
+	for _, s := range sorts {
+		f, _ := CursorSortField(s)
+		ret = append(ret, vals[f])
+	}
+	return
+}
+
+// cursorStructValues 按字段名(同StructModelInfo)读取结构体的值
+func cursorStructValues(v reflect.Value, vals map[string]interface{}) {
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		fType := t.Field(i)
+		if fType.Anonymous {
+			cursorStructValues(reflect.Indirect(v.Field(i)), vals)
+			continue
+		}
+		if len(fType.PkgPath) > 0 {
+			continue
+		}
+		name := strings.ToLower(fType.Name)
+		if dKey := strings.Split(fType.Tag.Get("db"), ",")[0]; len(dKey) > 0 {
+			name = dKey
+		}
+		vals[name] = v.Field(i).Interface()
+	}
+}
+
+// CursorTokens 按本次读取的首尾记录生成前后页游标
+func CursorTokens(sorts []string, result interface{}, limit int, c *Cursor) (next string, prev string) {
+	v := reflect.Indirect(reflect.ValueOf(result))
+	if len(sorts) == 0 || v.Kind() != reflect.Slice || v.Len() == 0 {
+		return
+	}
+	var (
+		first = CursorValues(sorts, v.Index(0).Interface())
+		last  = CursorValues(sorts, v.Index(v.Len()-1).Interface())
+		full  = limit > 0 && v.Len() >= limit // 本页已满, 可能还有记录
+	)
+	if full || (c != nil && c.Prev) {
+		next = CursorEncode(&Cursor{Values: last})
+	}
+	if c != nil && (!c.Prev || full) {
+		prev = CursorEncode(&Cursor{Values: first, Prev: true})
+	}
+	return
+}
+
+// SliceReverse 原地翻转切片
+func SliceReverse(dest interface{}) {
+	v := reflect.Indirect(reflect.ValueOf(dest))
+	if v.Kind() != reflect.Slice {
+		return
+	}
+	swap := reflect.Swapper(v.Interface())
+	for i, j := 0, v.Len()-1; i < j; i, j = i+1, j-1 {
+		swap(i, j)
+	}
+}
@@ -0,0 +1,59 @@
+package orm
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	token := CursorEncode(&Cursor{Values: []interface{}{int64(1) << 60, "a", 1.5}, Prev: true})
+	c, err := CursorDecode(token)
+	if err != nil {
+		t.Fatal(err)
+	} else if !c.Prev || c.Values[0] != int64(1)<<60 || c.Values[1] != "a" || c.Values[2] != 1.5 {
+		t.Fatalf("get %v", JSONMust(c))
+	}
+	// 时间按类型还原, 保留纳秒及时区; 字符串不受影响
+	at := time.Date(2021, 3, 4, 5, 6, 7, 123456789, time.FixedZone("", 8*3600))
+	if c, err = CursorDecode(CursorEncode(&Cursor{Values: []interface{}{at, at.Format(time.RFC3339Nano)}})); err != nil {
+		t.Fatal(err)
+	} else if v, ok := c.Values[0].(time.Time); !ok || !v.Equal(at) || v.Format(time.RFC3339Nano) != at.Format(time.RFC3339Nano) {
+		t.Fatalf("get %v", JSONMust(c))
+	} else if c.Values[1] != at.Format(time.RFC3339Nano) {
+		t.Fatalf("get %v", JSONMust(c))
+	}
+	if _, err = CursorDecode(base64.RawURLEncoding.EncodeToString([]byte(`{"v":[{"t":"x"}]}`))); err != ErrCursorInvalid {
+		t.Fatalf("get %v", err)
+	}
+	if _, err = CursorDecode("!!"); err != ErrCursorInvalid {
+		t.Fatalf("get %v", err)
+	}
+	// 向前翻页时比较方向相反
+	m, err := CursorFilter([]string{"-amount", "Username"}, &Cursor{Values: []interface{}{1, "a"}, Prev: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis := m[TagQueryKeyOr].([]interface{})
+	if u := lis[0].(map[string]interface{}); u["amount$gt$"] != 1 {
+		t.Fatalf("get %v", JSONMust(m))
+	}
+	if u := lis[1].(map[string]interface{})[TagQueryKeyAnd].([]interface{})[0].(map[string]interface{}); u["amount"] != 1 || u["username$lt$"] != "a" {
+		t.Fatalf("get %v", JSONMust(m))
+	}
+	if _, err = CursorFilter([]string{"amount"}, &Cursor{Values: []interface{}{1, "a"}}); err != ErrCursorInvalid {
+		t.Fatalf("get %v", err)
+	}
+	// 读取结构体字段
+	type user struct {
+		Username string
+		Amount   float64 `db:"total"`
+	}
+	if vals := CursorValues([]string{"-total", "username"}, &user{Username: "u", Amount: 2}); vals[0] != 2.0 || vals[1] != "u" {
+		t.Fatalf("get %v", vals)
+	}
+	s := []int{1, 2, 3}
+	if SliceReverse(&s); s[0] != 3 || s[2] != 1 {
+		t.Fatalf("get %v", s)
+	}
+}
@@ -12,6 +12,8 @@ type ArgModel struct {
 	LogLevel int
 	// 自定义表的SQL语句
 	Sql string
+	// 表对应的结构体, 登记softdelete/version/updated字段; 未设置时沿用本库Ensure/Create登记的信息
+	Struct interface{}
 }
 
 // Database 数据库对象
@@ -23,5 +25,8 @@ type Database interface {
 	//
 	Model(table string) Model                    // 获取table或者collection
 	ModelWith(table string, opt *ArgModel) Model // 获取table或者collection
+	//
+	Begin() (Trans, error)                  // 开启事务, 可用于本库的任意model
+	BeginWith(opt *ArgTrans) (Trans, error) // 指定参数开启事务
 	// 设置默认值
 }
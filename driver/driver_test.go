@@ -5,10 +5,10 @@ import (
 	"github.com/suboat/sorm/log"
 
 	_ "github.com/suboat/sorm/driver/mongo"
-	_ "github.com/suboat/sorm/driver/mssql"
-	_ "github.com/suboat/sorm/driver/mysql"
-	_ "github.com/suboat/sorm/driver/pg"
-	_ "github.com/suboat/sorm/driver/sqlite"
+	"github.com/suboat/sorm/driver/mssql"
+	"github.com/suboat/sorm/driver/mysql"
+	"github.com/suboat/sorm/driver/pg"
+	"github.com/suboat/sorm/driver/sqlite"
 
 	"fmt"
 	"os"
@@ -52,33 +52,50 @@ func testGetDB() orm.Database {
 	//orm.Log = log.NewLogFile(filepath.Join(testDir, "test.log"))
 	//orm.SetLogLevel(orm.LogLevel)
 
-	// pg
-	var (
-		conn string
-	)
+	if db, err := orm.New(TestName, testConn()); err == nil {
+		testDB = db
+	} else {
+		panic(err)
+	}
+
+	return testDB
+}
+
+// 测试数据库的连接参数
+func testConn() string {
 	switch TestName {
 	case orm.DriverNamePostgres:
-		conn = `{"user":"business", "password": "business", "host": "127.0.0.1", "port": "65432", "database": "business"}`
+		return `{"user":"business", "password": "business", "host": "127.0.0.1", "port": "65432", "database": "business"}`
 	case orm.DriverNameMysql:
-		conn = `{"user":"tester", "password": "business", "host": "192.168.6.6", "port": "3306", "database": "tester_sorm"}`
+		return `{"user":"tester", "password": "business", "host": "192.168.6.6", "port": "3306", "database": "tester_sorm"}`
 	case orm.DriverNameMsSql:
-		conn = `{"user":"tester", "password": "business", "host": "192.168.6.6", "port": "1433", "database": "tester_main"}`
+		return `{"user":"tester", "password": "business", "host": "192.168.6.6", "port": "1433", "database": "tester_main"}`
 	case orm.DriverNameSQLite:
-		conn = `{"database":"data_sqlite/business.db"}`
+		return `{"database":"data_sqlite/business.db", "params": {"_foreign_keys": ["1"]}}`
 	case orm.DriverNameMongo:
-		conn = `{"url":"mongodb://127.0.0.1:27017/", "db": "business"}`
-
-	default:
-		panic(fmt.Errorf(`unknown database "%s"`, TestName))
+		return `{"url":"mongodb://127.0.0.1:27017/", "db": "business"}`
 	}
+	panic(fmt.Errorf(`unknown database "%s"`, TestName))
+}
 
-	if db, err := orm.New(TestName, conn); err == nil {
-		testDB = db
-	} else {
-		panic(err)
+// 设置当前sql驱动批量插入的占位符上限, 返回恢复函数; 非sql驱动返回nil
+func testBatchArgsMax(n int) (restore func()) {
+	var p *int
+	switch TestName {
+	case orm.DriverNamePostgres:
+		p = &pg.CfgBatchArgsMax
+	case orm.DriverNameMysql:
+		p = &mysql.CfgBatchArgsMax
+	case orm.DriverNameMsSql:
+		p = &mssql.CfgBatchArgsMax
+	case orm.DriverNameSQLite:
+		p = &sqlite.CfgBatchArgsMax
+	default:
+		return nil
 	}
-
-	return testDB
+	old := *p
+	*p = n
+	return func() { *p = old }
 }
 
 // TestMain
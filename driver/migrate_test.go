@@ -0,0 +1,172 @@
+package driver
+
+import (
+	"github.com/suboat/sorm"
+
+	"testing"
+	"time"
+)
+
+// testMigrations 测试用的迁移
+func testMigrations(name string) []*orm.Migration {
+	sqlMap := func(s string) map[string]string {
+		return map[string]string{
+			orm.DriverNamePostgres: s,
+			orm.DriverNameMysql:    s,
+			orm.DriverNameSQLite:   s,
+			orm.DriverNameMsSql:    s,
+		}
+	}
+	exec := func(db orm.Database, t orm.Trans, s string) (err error) {
+		if t != nil {
+			_, err = t.Exec(s)
+		} else {
+			_, err = db.Model("test_migrate_item").Exec(s)
+		}
+		return
+	}
+	return []*orm.Migration{
+		{
+			Version: 1,
+			Name:    name,
+			UpSQL:   sqlMap(`CREATE TABLE test_migrate_item (id int PRIMARY KEY, name varchar(32))`),
+			DownSQL: sqlMap(`DROP TABLE test_migrate_item`),
+		},
+		{
+			Version: 2,
+			Name:    "insert item",
+			Up: func(db orm.Database, t orm.Trans) error {
+				return exec(db, t, `INSERT INTO test_migrate_item (id, name) VALUES (1, 'a')`)
+			},
+			Down: func(db orm.Database, t orm.Trans) error {
+				return exec(db, t, `DELETE FROM test_migrate_item WHERE id = 1`)
+			},
+		},
+	}
+}
+
+// 测试迁移
+func Test_Migrate(t *testing.T) {
+	db := testGetDB()
+	if db.DriverName() == orm.DriverNameMongo {
+		t.Skip("sql migration only")
+		return
+	}
+	for _, tbl := range []string{orm.MigrationTable, orm.MigrationLockTable, "test_migrate_item"} {
+		if err := db.Model(tbl).Drop(); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+	var (
+		mg    = orm.NewMigrator(db)
+		count = func() (n int) {
+			var err error
+			if n, err = db.Model("test_migrate_item").Objects().Count(); err != nil {
+				t.Fatal(err)
+			}
+			return
+		}
+	)
+	if err := mg.Register(testMigrations("create item")...); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// 执行
+	if n, err := mg.Migrate(); err != nil || n != 2 {
+		t.Fatalf(`"%v" migrate %d %v`, db, n, err)
+		return
+	} else if count() != 1 {
+		t.Fatalf(`"%v" item not inserted`, db)
+		return
+	}
+	if n, err := mg.Migrate(); err != nil || n != 0 {
+		t.Fatalf(`"%v" migrate again %d %v`, db, n, err)
+		return
+	}
+	if lis, err := mg.Status(); err != nil || len(lis) != 2 || !lis[0].Applied || !lis[1].Applied {
+		t.Fatalf(`"%v" status %s %v`, db, orm.JSONMust(lis), err)
+		return
+	}
+
+	// 锁被占用
+	mLock := db.Model(orm.MigrationLockTable)
+	if err := mLock.Objects().Create(&struct {
+		LockID   int       `sorm:"primary" json:"lockId"`
+		Owner    string    `sorm:"size(255)" json:"owner"`
+		LockTime time.Time `json:"lockTime"`
+	}{LockID: 1, Owner: "other", LockTime: time.Now()}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if _, err := mg.Migrate(); err != orm.ErrMigrationLocked {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrMigrationLocked, err)
+		return
+	}
+	if err := mLock.Objects().Delete(); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// 已执行的迁移被修改
+	mg2 := orm.NewMigrator(db)
+	_ = mg2.Register(testMigrations("create item modified")...)
+	if _, err := mg2.Migrate(); err != orm.ErrMigrationModified {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrMigrationModified, err)
+		return
+	}
+	if lis, _ := mg2.Status(); len(lis) != 2 || !lis[0].Modified || lis[1].Modified {
+		t.Fatalf(`"%v" status %s`, db, orm.JSONMust(lis))
+		return
+	}
+
+	// 回滚
+	if n, err := mg.Rollback(1); err != nil || n != 1 {
+		t.Fatalf(`"%v" rollback %d %v`, db, n, err)
+		return
+	} else if count() != 0 {
+		t.Fatalf(`"%v" item not deleted`, db)
+		return
+	}
+	if lis, _ := mg.Status(); len(lis) != 2 || !lis[0].Applied || lis[1].Applied {
+		t.Fatalf(`"%v" status %s`, db, orm.JSONMust(lis))
+		return
+	}
+	if n, err := mg.Rollback(5); err != nil || n != 1 {
+		t.Fatalf(`"%v" rollback %d %v`, db, n, err)
+		return
+	}
+	if n, err := mg.Migrate(); err != nil || n != 2 {
+		t.Fatalf(`"%v" migrate %d %v`, db, n, err)
+		return
+	}
+
+	// 执行较久时锁被刷新, 不会被视为超时
+	var (
+		mg3   = orm.NewMigrator(db)
+		start time.Time
+	)
+	mg3.LockRefresh = 50 * time.Millisecond
+	_ = mg3.Register(&orm.Migration{Version: 3, Name: "slow", Up: func(orm.Database, orm.Trans) error {
+		start = time.Now()
+		time.Sleep(400 * time.Millisecond)
+		return nil
+	}}, &orm.Migration{Version: 4, Name: "check lock", Up: func(_db orm.Database, _ orm.Trans) error {
+		var lis []map[string]interface{}
+		if err := _db.Model(orm.MigrationLockTable).Objects().All(&lis); err != nil {
+			return err
+		} else if len(lis) != 1 {
+			t.Fatalf(`"%v" lock %v`, db, lis)
+		} else if at, _ := lis[0]["locktime"].(time.Time); at.Before(start) {
+			t.Fatalf(`"%v" lock not refreshed: %v`, db, lis)
+		}
+		return nil
+	}})
+	if n, err := mg3.Migrate(); err != nil || n != 2 {
+		t.Fatalf(`"%v" migrate %d %v`, db, n, err)
+		return
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
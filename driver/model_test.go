@@ -263,6 +263,584 @@ func Test_ModelEnsure(t *testing.T) {
 	}
 }
 
+// 预览表结构变更
+func Test_ModelPlan(t *testing.T) {
+	var (
+		db  = testGetDB()
+		tbl = "test_plan"
+		m   = db.Model(tbl)
+		p   *orm.Plan
+		err error
+	)
+	if err = m.Drop(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 新表
+	if p, err = m.Plan(&Eukaryota{}); err != nil {
+		t.Fatal(err)
+		return
+	} else if p.TableExist || len(p.DDL) == 0 {
+		t.Fatalf(`"%v" new table plan %s`, db, orm.JSONMust(p))
+		return
+	}
+	if db.DriverName() != orm.DriverNameMongo && len(p.ColumnMissing) != 5 {
+		t.Fatalf(`"%v" new table columns %s`, db, orm.JSONMust(p.ColumnMissing))
+		return
+	}
+	if err = m.Ensure(&Eukaryota{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// 已同步: 无差异
+	if p, err = m.Plan(&Eukaryota{}); err != nil {
+		t.Fatal(err)
+		return
+	} else if !p.TableExist || !p.Empty() {
+		t.Fatalf(`"%v" synced plan %s`, db, orm.JSONMust(p))
+		return
+	}
+
+	// 新增字段及索引, 预览不执行
+	for i := 0; i < 2; i++ {
+		if p, err = m.Plan(&Programmer{}); err != nil {
+			t.Fatal(err)
+			return
+		} else if len(p.DDL) == 0 || len(p.IndexMissing) == 0 || len(p.ColumnChanged) > 0 {
+			t.Fatalf(`"%v" evolve plan %s`, db, orm.JSONMust(p))
+			return
+		}
+		if db.DriverName() != orm.DriverNameMongo && len(p.ColumnMissing) == 0 {
+			t.Fatalf(`"%v" evolve columns %s`, db, orm.JSONMust(p))
+			return
+		}
+	}
+	if err = m.Ensure(&Programmer{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if p, err = m.Plan(&Programmer{}); err != nil {
+		t.Fatal(err)
+		return
+	} else if !p.Empty() {
+		t.Fatalf(`"%v" evolved plan %s`, db, orm.JSONMust(p))
+		return
+	}
+
+	// 库中多余的索引
+	if p, err = m.Plan(&Eukaryota{}); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(p.DDL) > 0 || len(p.IndexExtra) == 0 {
+		t.Fatalf(`"%v" extra plan %s`, db, orm.JSONMust(p))
+		return
+	}
+}
+
+// EnsureWith: 改字段, 删字段及索引, 重命名
+func Test_ModelEnsureWith(t *testing.T) {
+	type witemV1 struct {
+		UID  string `sorm:"primary;size(36)" json:"uid"`
+		Name string `sorm:"size(16);index" json:"name"`
+		Nick string `sorm:"size(16)" json:"nick"`
+		Old  int    `sorm:"index" json:"old"`
+	}
+	type witemV2 struct {
+		UID   string `sorm:"primary;size(36)" json:"uid"`
+		Name  string `sorm:"size(64)" json:"name"`
+		Alias string `sorm:"size(16);was(nick)" json:"alias"`
+	}
+	var (
+		db  = testGetDB()
+		m   = db.Model("test_ensure_with")
+		p   *orm.Plan
+		err error
+	)
+	if err = m.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Ensure(&witemV1{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	w := &witemV1{UID: types.NewUID(), Name: "go", Nick: "gopher", Old: 1}
+	if err = m.Objects().Create(w); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// 默认不做破坏性修改
+	if p, err = m.Plan(&witemV2{}); err != nil {
+		t.Fatal(err)
+		return
+	} else if db.DriverName() != orm.DriverNameMongo &&
+		(len(p.ColumnRenamed) != 1 || len(p.ColumnExtra) != 1 || len(p.ColumnChanged) != 1) {
+		t.Fatalf(`"%v" plan %s`, db, orm.JSONMust(p))
+		return
+	} else if len(p.IndexExtra) != 2 {
+		t.Fatalf(`"%v" plan index %s`, db, orm.JSONMust(p.IndexExtra))
+		return
+	}
+	if err = m.EnsureWith(&witemV2{}, &orm.ArgEnsure{
+		AlterColumn: true, DropColumn: true, DropIndex: true, Rename: true}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if p, err = m.Plan(&witemV2{}); err != nil {
+		t.Fatal(err)
+		return
+	} else if !p.Empty() {
+		t.Fatalf(`"%v" synced plan %s`, db, orm.JSONMust(p))
+		return
+	}
+
+	// 数据保留
+	r := new(witemV2)
+	if err = m.Objects().Filter(orm.M{"uid": w.UID}).One(r); err != nil {
+		t.Fatal(err)
+		return
+	} else if r.Name != w.Name || r.Alias != w.Nick {
+		t.Fatalf(`"%v" get %s`, db, orm.JSONMust(r))
+		return
+	}
+}
+
+func Test_ModelInspect(t *testing.T) {
+	type iitem struct {
+		UID    string  `sorm:"primary;size(36)" json:"uid"`
+		Name   string  `sorm:"size(32);index(age)" json:"name"`
+		Age    int     `json:"age"`
+		Amount float64 `sorm:"decimal(20,8)" json:"amount"`
+		Code   string  `sorm:"size(16);unique" json:"code"`
+	}
+	var (
+		db   = testGetDB()
+		m    = db.Model("test_inspect")
+		info *orm.TableInfo
+		err  error
+	)
+	if err = m.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Inspect(); err != orm.ErrSyncTableNotExist {
+		t.Fatalf(`"%v" inspect not exist: %v`, db, err)
+		return
+	}
+	if err = m.Ensure(&iitem{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m.Objects().Create(&iitem{UID: types.NewUID(), Name: "go", Age: 1, Code: "c1"}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if info, err = m.Inspect(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	hasIndex := false
+	for _, idx := range info.Indexes {
+		if strings.Join(idx.Key, ",") == "name,age" && !idx.Unique {
+			hasIndex = true
+		}
+	}
+	if !hasIndex {
+		t.Fatalf(`"%v" inspect index %s`, db, orm.JSONMust(info.Indexes))
+		return
+	}
+	if db.DriverName() == orm.DriverNameMongo {
+		return
+	}
+
+	// 生成的结构体与原结构体标签一致
+	src := orm.GenStruct(info, "")
+	for _, s := range []string{
+		"UID string `sorm:\"primary;size(36)\" json:\"uid\"`",
+		"Name string `sorm:\"size(32);index(age)\" json:\"name\"`",
+		"Age int `json:\"age\"`",
+		"Amount float64 `sorm:\"decimal(20,8)\" json:\"amount\"`",
+		"Code string `sorm:\"size(16);unique\" json:\"code\"`",
+	} {
+		if !strings.Contains(src, s) {
+			t.Fatalf(`"%v" gen missing %q in:\n%s`, db, s, src)
+			return
+		}
+	}
+}
+
+func Test_ModelForeignKey(t *testing.T) {
+	type fkParent struct {
+		UID  string `sorm:"primary;size(36)" json:"uid"`
+		Name string `sorm:"size(32)" json:"name"`
+	}
+	type fkChildV1 struct {
+		UID       string `sorm:"primary;size(36)" json:"uid"`
+		ParentUID string `sorm:"size(36);index" json:"parentUid"`
+	}
+	type fkChildV2 struct {
+		UID       string `sorm:"primary;size(36)" json:"uid"`
+		ParentUID string `sorm:"size(36);index;fk(test_fk_parent.uid,ondelete=cascade)" json:"parentUid"`
+	}
+	var (
+		db      = testGetDB()
+		mParent = db.Model("test_fk_parent")
+		mChild  = db.Model("test_fk_child")
+		p       *orm.Plan
+		n       int
+		err     error
+	)
+	if db.DriverName() == orm.DriverNameMongo {
+		t.Skip("mongo not support foreign key")
+	}
+	if err = mChild.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = mParent.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = mParent.Ensure(&fkParent{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = mChild.Ensure(&fkChildV1{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// 已有表缺少外键
+	if p, err = mChild.Plan(&fkChildV2{}); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(p.FkMissing) != 1 || p.FkMissing[0].RefTable != "test_fk_parent" {
+		t.Fatalf(`"%v" plan %s`, db, orm.JSONMust(p))
+		return
+	}
+	if err = mChild.EnsureWith(&fkChildV2{}, &orm.ArgEnsure{AlterColumn: true}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if p, err = mChild.Plan(&fkChildV2{}); err != nil {
+		t.Fatal(err)
+		return
+	} else if !p.Empty() {
+		t.Fatalf(`"%v" synced plan %s`, db, orm.JSONMust(p))
+		return
+	}
+
+	// 约束生效
+	parent := &fkParent{UID: types.NewUID(), Name: "p"}
+	if err = mParent.Objects().Create(parent); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = mChild.Objects().Create(&fkChildV2{UID: types.NewUID(), ParentUID: parent.UID}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = mChild.Objects().Create(&fkChildV2{UID: types.NewUID(), ParentUID: types.NewUID()}); err == nil {
+		t.Fatalf(`"%v" insert without parent should fail`, db)
+		return
+	}
+	if err = mParent.Objects().Filter(orm.M{"uid": parent.UID}).Delete(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if n, err = mChild.Objects().Count(); err != nil {
+		t.Fatal(err)
+		return
+	} else if n != 0 {
+		t.Fatalf(`"%v" cascade delete left %d`, db, n)
+		return
+	}
+
+	// 新表在建表时带外键
+	if err = mChild.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = mChild.Ensure(&fkChildV2{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if p, err = mChild.Plan(&fkChildV2{}); err != nil {
+		t.Fatal(err)
+		return
+	} else if !p.Empty() {
+		t.Fatalf(`"%v" new table plan %s`, db, orm.JSONMust(p))
+		return
+	}
+	if err = mChild.Drop(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func Test_ModelColumnTags(t *testing.T) {
+	type ctagV1 struct {
+		UID  string `sorm:"primary;size(36)" json:"uid"`
+		Name string `sorm:"size(32);notnull;default('guest');comment(user's name)" json:"name"`
+		Age  int    `sorm:"notnull;check(age >= 0)" json:"age"`
+	}
+	type ctagV2 struct {
+		UID  string  `sorm:"primary;size(36)" json:"uid"`
+		Name string  `sorm:"size(32);notnull;default('guest');comment(user's name)" json:"name"`
+		Age  int     `sorm:"notnull;check(age >= 0)" json:"age"`
+		Rank int     `sorm:"notnull;default(5);check(rank > 0);comment(排名)" json:"rank"`
+		Note *string `sorm:"size(64);notnull" json:"note"`
+	}
+	var (
+		db   = testGetDB()
+		m    = db.Model("test_column_tags")
+		p    *orm.Plan
+		info *orm.TableInfo
+		err  error
+	)
+	if db.DriverName() == orm.DriverNameMongo {
+		t.Skip("mongo has no column define")
+	}
+	if err = m.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Ensure(&ctagV1{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m.Objects().Create(&ctagV1{UID: types.NewUID(), Name: "go", Age: 1}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m.Objects().Create(&ctagV1{UID: types.NewUID(), Name: "go", Age: -1}); err == nil {
+		t.Fatalf(`"%v" check constraint not work`, db)
+		return
+	}
+
+	// 已有表新增字段
+	if err = m.Ensure(&ctagV2{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if p, err = m.Plan(&ctagV2{}); err != nil {
+		t.Fatal(err)
+		return
+	} else if !p.Empty() {
+		t.Fatalf(`"%v" synced plan %s`, db, orm.JSONMust(p))
+		return
+	}
+	if info, err = m.Inspect(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	for _, c := range info.Columns {
+		switch c.Name {
+		case "name", "age", "rank", "note":
+			if c.Null {
+				t.Fatalf(`"%v" column %s should not null`, db, c.Name)
+				return
+			}
+		}
+	}
+	r := new(ctagV2)
+	if err = m.Objects().Filter(orm.M{"name": "go"}).One(r); err != nil {
+		t.Fatal(err)
+		return
+	} else if r.Rank != 5 {
+		t.Fatalf(`"%v" default rank %d`, db, r.Rank)
+		return
+	}
+	if r.Note == nil || len(*r.Note) > 0 {
+		t.Fatalf(`"%v" default note %v`, db, r.Note)
+		return
+	}
+	if err = m.Objects().Create(&ctagV2{UID: types.NewUID(), Name: "go", Age: 1, Rank: 0}); err == nil {
+		t.Fatalf(`"%v" added check constraint not work`, db)
+		return
+	}
+}
+
+func Test_ModelText(t *testing.T) {
+	type textPost struct {
+		UID   string `sorm:"primary;size(36)" json:"uid"`
+		Title string `sorm:"size(128);text" json:"title"`
+	}
+	var (
+		db   = testGetDB()
+		m    = db.Model("test_text_post")
+		lis  []*textPost
+		p    *orm.Plan
+		info *orm.TableInfo
+		num  int
+		err  error
+	)
+	if err = m.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Ensure(&textPost{}); err != nil && strings.Contains(err.Error(), "fts5") {
+		t.Skip("go-sqlite3 built without sqlite_fts5 tag")
+	} else if err != nil {
+		t.Fatal(err)
+		return
+	}
+	if db.DriverName() == orm.DriverNameMsSql {
+		t.Skip("mssql populates full-text index asynchronously")
+	}
+	for _, title := range []string{"golang orm database", "golang golang tutorial", "python web"} {
+		if err = m.Objects().Create(&textPost{UID: types.NewUID(), Title: title}); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	// 搜索及按相关度排序
+	if err = m.Objects().Filter(orm.M{"title$text$": "golang"}).Sort("-$text$").All(&lis); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(lis) != 2 || lis[0].Title != "golang golang tutorial" {
+		t.Fatalf(`"%v" text search %s`, db, orm.JSONMust(lis))
+		return
+	}
+	if num, err = m.Objects().Filter(orm.M{"title": "$text$python"}).Count(); err != nil {
+		t.Fatal(err)
+		return
+	} else if num != 1 {
+		t.Fatalf(`"%v" text count %d`, db, num)
+		return
+	}
+
+	// 更新后索引同步
+	if err = m.Objects().Filter(orm.M{"title": "python web"}).Update(map[string]interface{}{"title": "golang web"}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if num, err = m.Objects().Filter(orm.M{"title$text$": "golang"}).Count(); err != nil {
+		t.Fatal(err)
+		return
+	} else if num != 3 {
+		t.Fatalf(`"%v" text count after update %d`, db, num)
+		return
+	}
+	if db.DriverName() == orm.DriverNameMongo {
+		return
+	}
+
+	// 无全文搜索条件时不能按相关度排序
+	if err = m.Objects().Sort("-$text$").All(&lis); err != orm.ErrIndexTextSortInvalid {
+		t.Fatalf(`"%v" text sort without query: %v`, db, err)
+		return
+	}
+	if p, err = m.Plan(&textPost{}); err != nil {
+		t.Fatal(err)
+		return
+	} else if !p.Empty() {
+		t.Fatalf(`"%v" synced plan %s`, db, orm.JSONMust(p))
+		return
+	}
+	if info, err = m.Inspect(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	for _, idx := range info.Indexes {
+		if idx.Text && len(idx.Key) == 1 && idx.Key[0] == "title" {
+			return
+		}
+	}
+	t.Fatalf(`"%v" text index not found %s`, db, orm.JSONMust(info.Indexes))
+}
+
+func Test_ModelIndexOptions(t *testing.T) {
+	type indexUser struct {
+		UID     string `sorm:"primary;size(36)" json:"uid"`
+		Name    string `sorm:"size(64)" json:"name"`
+		Email   string `sorm:"size(128)" json:"email"`
+		At      int64  `json:"at"`
+		Deleted int    `json:"deleted"`
+	}
+	var (
+		db      = testGetDB()
+		m       = db.Model("test_index_user")
+		driver  = db.DriverName()
+		info    *orm.TableInfo
+		nameLis []string
+		err     error
+	)
+	if err = m.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Ensure(&indexUser{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// 降序及覆盖索引
+	if err = m.EnsureIndex(orm.Index{"Key": []string{"name", "-at"}, "Include": []string{"email"}}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	nameLis = append(nameLis, "test_index_user_name_at")
+	if driver == orm.DriverNameMongo {
+		nameLis[0] = "name_1_at_-1"
+	}
+	// 表达式唯一索引
+	err = m.EnsureIndex(orm.Index{"Expr": []string{"lower(email)"}, "Unique": true, "Name": "test_index_user_email_ci"})
+	if driver == orm.DriverNameMongo || driver == orm.DriverNameMsSql {
+		if err != orm.ErrIndexNotSupport {
+			t.Fatalf(`"%v" expr index: %v`, db, err)
+			return
+		}
+	} else if err != nil {
+		t.Fatal(err)
+		return
+	} else {
+		nameLis = append(nameLis, "test_index_user_email_ci")
+	}
+	// 部分索引
+	err = m.EnsureIndex(orm.Index{"Key": []string{"name"}, "Unique": true, "Where": orm.M{"deleted": 0},
+		"Name": "test_index_user_name_live"})
+	if driver == orm.DriverNameMysql {
+		if err != orm.ErrIndexNotSupport {
+			t.Fatalf(`"%v" partial index: %v`, db, err)
+			return
+		}
+	} else if err != nil {
+		t.Fatal(err)
+		return
+	} else {
+		nameLis = append(nameLis, "test_index_user_name_live")
+		if err = m.Objects().Create(&indexUser{UID: types.NewUID(), Name: "go", Email: "a@b.c", Deleted: 1}); err != nil {
+			t.Fatal(err)
+			return
+		}
+		if err = m.Objects().Create(&indexUser{UID: types.NewUID(), Name: "go", Email: "b@b.c", Deleted: 0}); err != nil {
+			t.Fatal(err)
+			return
+		}
+		if err = m.Objects().Create(&indexUser{UID: types.NewUID(), Name: "go", Email: "c@b.c", Deleted: 0}); err == nil {
+			t.Fatalf(`"%v" partial unique index not applied`, db)
+			return
+		}
+	}
+	// 重复调用不报错
+	if err = m.EnsureIndex(orm.Index{"Key": []string{"name", "-at"}, "Include": []string{"email"}}); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	if info, err = m.Inspect(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	for _, name := range nameLis {
+		found := false
+		for _, idx := range info.Indexes {
+			if strings.EqualFold(idx.Name, name) {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf(`"%v" index %s not found %s`, db, name, orm.JSONMust(info.Indexes))
+			return
+		}
+	}
+}
+
 func testModelVirtual(t *testing.T) {
 	as := require.New(t)
 	var (
@@ -326,7 +904,6 @@ func testModelVirtual(t *testing.T) {
 	}
 }
 
-//
 func testModelGroup(t *testing.T) {
 	as := require.New(t)
 	as.Nil(nil)
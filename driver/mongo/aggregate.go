@@ -0,0 +1,147 @@
+package mongo
+
+import (
+	"github.com/suboat/sorm"
+
+	//"gopkg.in/mgo.v2/bson"
+	"github.com/globalsign/mgo/bson"
+
+	"fmt"
+	"strings"
+)
+
+// Sum 求和
+func (o *Objects) Sum(field string) (float64, error) {
+	return o.aggregateOne(orm.AggSum, field)
+}
+
+// Avg 平均值
+func (o *Objects) Avg(field string) (float64, error) {
+	return o.aggregateOne(orm.AggAvg, field)
+}
+
+// Min 最小值
+func (o *Objects) Min(field string) (float64, error) {
+	return o.aggregateOne(orm.AggMin, field)
+}
+
+// Max 最大值
+func (o *Objects) Max(field string) (float64, error) {
+	return o.aggregateOne(orm.AggMax, field)
+}
+
+// Aggregate 分组聚合, 每组一行写入dest
+func (o *Objects) Aggregate(groupBy []string, aggs map[string]string, dest interface{}) (err error) {
+	if o.err != nil {
+		return o.err
+	}
+	var lis []*orm.AggregateField
+	if lis, err = orm.AggregateParse(aggs); err != nil {
+		return
+	}
+	if groupBy, err = orm.AggregateGroupBy(groupBy); err != nil {
+		return
+	}
+	if err = orm.AggregateSorts(o.sorts, groupBy, lis); err != nil {
+		return
+	}
+	pipeline := o.aggregatePipeline(groupBy, lis)
+	// sort
+	if len(o.sorts) > 0 {
+		sort := bson.D{}
+		for _, s := range o.sorts {
+			s = strings.ToLower(s)
+			if strings.HasPrefix(s, "-") {
+				sort = append(sort, bson.DocElem{Name: s[1:], Value: -1})
+			} else {
+				sort = append(sort, bson.DocElem{Name: strings.TrimPrefix(s, "+"), Value: 1})
+			}
+		}
+		pipeline = append(pipeline, bson.M{"$sort": sort})
+	}
+	if o.skip > 0 {
+		pipeline = append(pipeline, bson.M{"$skip": o.skip})
+	}
+	if o.limit > 0 {
+		pipeline = append(pipeline, bson.M{"$limit": o.limit})
+	}
+	if err = o.ctxCheck(); err != nil {
+		return
+	}
+	if err = o.Model.Collection.Pipe(pipeline).All(dest); err == nil {
+		o.log.Debug("[MONGO AGGREGATE] ", pipeline)
+	}
+	return
+}
+
+// aggregateOne 单值聚合, 无匹配记录时返回0
+func (o *Objects) aggregateOne(fn string, field string) (ret float64, err error) {
+	if o.err != nil {
+		return 0, o.err
+	}
+	var a *orm.AggregateField
+	if a, err = orm.AggregateFieldNew("value", fmt.Sprintf("%s(%s)", fn, field)); err != nil {
+		return
+	}
+	if err = o.ctxCheck(); err != nil {
+		return
+	}
+	var (
+		pipeline = o.aggregatePipeline(nil, []*orm.AggregateField{a})
+		res      []bson.M
+	)
+	if err = o.Model.Collection.Pipe(pipeline).All(&res); err != nil || len(res) == 0 {
+		return
+	}
+	switch v := res[0]["value"].(type) {
+	case float64:
+		ret = v
+	case int:
+		ret = float64(v)
+	case int64:
+		ret = float64(v)
+	case bson.Decimal128:
+		_, err = fmt.Sscan(v.String(), &ret)
+	}
+	o.log.Debug("[MONGO ", strings.ToUpper(fn), "] ", pipeline)
+	return
+}
+
+// aggregatePipeline 以当前搜索条件为$match, 按groupBy分组并将分组字段展开
+func (o *Objects) aggregatePipeline(groupBy []string, lis []*orm.AggregateField) (pipeline []bson.M) {
+	var (
+		id      interface{}
+		group   = bson.M{}
+		project = bson.M{"_id": 0}
+	)
+	if len(groupBy) > 0 {
+		_id := bson.M{}
+		for _, f := range groupBy {
+			_id[f] = "$" + f
+			project[f] = "$_id." + f
+		}
+		id = _id
+	}
+	group["_id"] = id
+	for _, a := range lis {
+		if a.Func == orm.AggCount {
+			if a.Field == "*" {
+				group[a.Alias] = bson.M{"$sum": 1}
+			} else {
+				// 只计非空字段
+				group[a.Alias] = bson.M{"$sum": bson.M{"$cond": []interface{}{
+					bson.M{"$gt": []interface{}{"$" + a.Field, nil}}, 1, 0}}}
+			}
+		} else {
+			group[a.Alias] = bson.M{"$" + a.Func: "$" + a.Field}
+		}
+		project[a.Alias] = 1
+	}
+	o.queryCheck() // 未调用Filter时补充软删除条件
+	match := o.m
+	if match == nil {
+		match = bson.M{}
+	}
+	pipeline = append(pipeline, bson.M{"$match": match}, bson.M{"$group": group}, bson.M{"$project": project})
+	return
+}
@@ -17,6 +17,8 @@ var (
 	MaxOpenConns = 50    // 默认最大链接数
 	CfgDbUnsafe  = false // true: fetch fields unsafe
 	CfgTxUnsafe  = false // true: support trans can not rollback
+	// CfgBatchRowsMax 批量插入时每批的文档数
+	CfgBatchRowsMax = 1000
 )
 
 // 数据库链接参数
@@ -31,6 +33,7 @@ type DatabaseSQL struct {
 	DB      *mgo.Database // 数据库
 	Session *mgo.Session  // mongo session
 	log     orm.Logger
+	models  orm.ModelInfoMap // 表名->附加信息
 }
 
 //
@@ -103,9 +106,27 @@ func (db *DatabaseSQL) ModelWith(s string, arg *orm.ArgModel) orm.Model {
 	if arg != nil && arg.LogLevel > 0 && m.log != nil {
 		m.log.SetLevel(arg.LogLevel)
 	}
+	m.db = db
+	if arg != nil && arg.Struct != nil {
+		if lis, _err := orm.StructModelInfo(arg.Struct); _err != nil {
+			m.log.Errorf("model %s struct: %v", s, _err)
+		} else {
+			db.models.Register(s, lis)
+		}
+	}
 	return m
 }
 
+// Begin 开启事务, 同Model.Begin
+func (db *DatabaseSQL) Begin() (orm.Trans, error) {
+	return db.Model("").BeginWith(nil)
+}
+
+// BeginWith 指定参数开启事务, 同Model.BeginWith
+func (db *DatabaseSQL) BeginWith(arg *orm.ArgTrans) (orm.Trans, error) {
+	return db.Model("").BeginWith(arg)
+}
+
 // ** other
 // 解析参数
 func argParser(jsonStr string) (db *DatabaseSQL, err error) {
@@ -157,8 +178,14 @@ func NewDb(arg string) (orm.Database, error) {
 }
 
 // register
+// duplicateKey 主键或唯一索引冲突
+func duplicateKey(err error) bool {
+	return mgo.IsDup(err)
+}
+
 func init() {
 	orm.RegisterDriver(orm.DriverNameMongo, NewDb)
+	orm.HookDuplicateKey[orm.DriverNameMongo] = duplicateKey
 	// 用songo作为解析驱动
 	orm.HookParseSafe = songo.ParseSafe //
 	orm.HookParseMgo = songo.ParseMgo   // test
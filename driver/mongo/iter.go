@@ -0,0 +1,85 @@
+package mongo
+
+import (
+	"github.com/suboat/sorm"
+
+	//"gopkg.in/mgo.v2"
+	"github.com/globalsign/mgo"
+)
+
+// Iter 游标实现
+type Iter struct {
+	o    *Objects
+	iter *mgo.Iter
+	err  error
+}
+
+// Next 读取下一条记录
+func (it *Iter) Next(result interface{}) bool {
+	if it.err != nil || it.iter == nil {
+		return false
+	}
+	if it.err = it.o.ctxCheck(); it.err != nil {
+		return false
+	}
+	if !it.iter.Next(result) {
+		return false
+	}
+	if it.err = it.o.hook(orm.HookAfterFind, result); it.err != nil {
+		return false
+	}
+	it.o.nums++
+	return true
+}
+
+// Err 迭代中的错误
+func (it *Iter) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+	if it.iter != nil {
+		return it.iter.Err()
+	}
+	return nil
+}
+
+// Close 关闭游标
+func (it *Iter) Close() (err error) {
+	if it.iter != nil {
+		err = it.iter.Close()
+	}
+	return
+}
+
+// Iter 逐条读取
+func (o *Objects) Iter() orm.Iter {
+	if o.err != nil {
+		return &Iter{o: o, err: o.err}
+	}
+	o.queryCheck()
+	if err := o.ctxCheck(); err != nil {
+		return &Iter{o: o, err: err}
+	}
+	o.nums = 0
+	o.log.Debug("[MONGO ITER] ", o.m)
+	return &Iter{o: o, iter: o.query.Iter()}
+}
+
+// Each 逐条读取并回调
+func (o *Objects) Each(result interface{}, fn func() error) (err error) {
+	return orm.IterEach(o.Iter(), result, fn)
+}
+
+// TIter 在事务中逐条读取
+func (o *Objects) TIter(t orm.Trans) orm.Iter {
+	if err := o.transCheck(t); err != nil {
+		return &Iter{o: o, err: err}
+	}
+	o.trans = t
+	return o.Iter()
+}
+
+// TEach 在事务中逐条读取并回调
+func (o *Objects) TEach(result interface{}, fn func() error, t orm.Trans) (err error) {
+	return orm.IterEach(o.TIter(t), result, fn)
+}
@@ -6,8 +6,14 @@ import (
 
 	//"gopkg.in/mgo.v2"
 	"github.com/globalsign/mgo"
+	"github.com/globalsign/mgo/bson"
 
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
 	"sync"
+	"time"
 )
 
 // Model model
@@ -17,7 +23,11 @@ type Model struct {
 	TableName  string
 	Collection *mgo.Collection // mgo table
 	//
-	log orm.Logger
+	plan *orm.Plan // 非nil时Ensure只记录语句, 不执行
+	log  orm.Logger
+	ctx  context.Context // nil: context.Background()
+	//
+	db *DatabaseSQL // 所属数据库
 }
 
 // Copy 全拷贝
@@ -25,6 +35,8 @@ func (m *Model) Copy() (r *Model) {
 	r = new(Model)
 	r.TableName = m.TableName
 	r.Collection = m.Collection
+	r.ctx = m.ctx
+	r.db = m.db
 	if m.log != nil {
 		if _log, ok := m.log.(*log.Logger); ok {
 			r.log = _log.Copy()
@@ -38,6 +50,27 @@ func (m *Model) Copy() (r *Model) {
 	return
 }
 
+// info 所属数据库登记的表附加信息, 未登记时为nil
+func (m *Model) info() *orm.ModelInfo {
+	if m.db == nil {
+		return nil
+	}
+	return m.db.models.Get(m.TableName)
+}
+
+// infoLoad 表未登记时从orm.ModelInfoTable读取附加信息, 如重启后未经Ensure的表
+func (m *Model) infoLoad() (err error) {
+	if m.db == nil || m.db.models.Get(m.TableName) != nil {
+		return
+	}
+	var info *orm.ModelInfo
+	if info, err = orm.ModelInfoLoad(m.db, m.TableName); err != nil {
+		return
+	}
+	m.db.models.Init(m.TableName, info)
+	return
+}
+
 func (m *Model) String() string {
 	return m.TableName
 }
@@ -51,6 +84,7 @@ func (m *Model) Objects() orm.Objects {
 func (m *Model) ObjectsWith(arg *orm.ArgObjects) orm.Objects {
 	ob := new(Objects)
 	ob.Model = m
+	ob.ctx = m.ctx
 	ob.count = -1
 	ob.nums = -1
 	if m.log != nil {
@@ -121,16 +155,88 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 			index.LanguageOverride = v
 		}
 	}
+	// Name
+	if v, ok := indexMap["Name"].(string); ok {
+		index.Name = v
+	}
+	// Expr: mongo不支持表达式索引; Include: 忽略
+	if _, ok := indexMap["Expr"]; ok {
+		return orm.ErrIndexNotSupport
+	}
+	// Where: 部分索引, 不能与Sparse同时使用
+	var where map[string]interface{}
+	switch w := indexMap["Where"].(type) {
+	case orm.M:
+		where = w
+	case map[string]interface{}:
+		where = w
+	}
+	if where != nil {
+		var filter map[string]interface{}
+		if filter, err = orm.HookParseMgo(where); err != nil {
+			return
+		}
+		index.PartialFilter = bson.M(filter)
+		index.Sparse = false
+	}
 	// Sparse
 	if i, ok := indexMap["Sparse"]; ok {
 		if v, ok := i.(bool); ok {
 			index.Sparse = v
 		}
 	}
+	if m.plan != nil {
+		m.planIndex(index)
+		return
+	}
 	err = m.Collection.EnsureIndex(index)
 	return
 }
 
+// planIndex 预览时登记索引, 缺少时记录创建语句; 索引名与mgo的默认命名一致
+func (m *Model) planIndex(index mgo.Index) {
+	var (
+		nameLis []string
+		docLis  []string
+	)
+	for _, k := range index.Key {
+		switch {
+		case strings.HasPrefix(k, "$") && strings.Contains(k, ":"):
+			i := strings.Index(k, ":")
+			nameLis = append(nameLis, k[i+1:]+"_"+k[1:i])
+			docLis = append(docLis, fmt.Sprintf(`"%s":"%s"`, k[i+1:], k[1:i]))
+		case strings.HasPrefix(k, "-"):
+			nameLis = append(nameLis, k[1:]+"_-1")
+			docLis = append(docLis, fmt.Sprintf(`"%s":-1`, k[1:]))
+		case strings.HasPrefix(k, "@"):
+			nameLis = append(nameLis, k[1:]+"_2d")
+			docLis = append(docLis, fmt.Sprintf(`"%s":"2d"`, k[1:]))
+		default:
+			k = strings.TrimPrefix(k, "+")
+			nameLis = append(nameLis, k+"_1")
+			docLis = append(docLis, fmt.Sprintf(`"%s":1`, k))
+		}
+	}
+	name := index.Name
+	if len(name) == 0 {
+		name = strings.Join(nameLis, "_")
+	}
+	if m.plan.IndexExpect(name, index.Key, index.Unique) {
+		return
+	}
+	opt := fmt.Sprintf(`"unique":%v,"background":%v,"sparse":%v`, index.Unique, index.Background, index.Sparse)
+	if len(index.Name) > 0 {
+		opt += fmt.Sprintf(`,"name":"%s"`, index.Name)
+	}
+	if index.PartialFilter != nil {
+		if b, _err := json.Marshal(index.PartialFilter); _err == nil {
+			opt += `,"partialFilterExpression":` + string(b)
+		}
+	}
+	m.plan.DDL = append(m.plan.DDL, fmt.Sprintf(`db.%s.createIndex({%s}, {%s})`,
+		m.TableName, strings.Join(docLis, ","), opt))
+}
+
 // EnsureColumn 确认字段
 func (m *Model) EnsureColumn(st interface{}) (err error) {
 	return m.Ensure(st)
@@ -145,6 +251,17 @@ func (m *Model) Ensure(st interface{}) (err error) {
 	if fieldInfoLis, err = orm.StructModelInfo(st); err != nil {
 		return
 	}
+	if m.db != nil {
+		m.db.models.Register(m.TableName, fieldInfoLis)
+	}
+	if err = m.ensure(fieldInfoLis); err != nil || m.db == nil {
+		return
+	}
+	return orm.ModelInfoSave(m.db, m.TableName, m.info())
+}
+
+// ensure 确认索引
+func (m *Model) ensure(fieldInfoLis []*orm.FieldInfo) (err error) {
 	for _, f := range fieldInfoLis {
 		if !f.Index && !f.Unique && !f.IndexText && !f.Primary {
 			continue
@@ -195,15 +312,168 @@ func (m *Model) Ensure(st interface{}) (err error) {
 	return
 }
 
+// Plan 对比struct与库中的索引, 返回Ensure将执行的语句, 不执行
+func (m *Model) Plan(st interface{}) (ret *orm.Plan, err error) {
+	var (
+		mp           = m.Copy()
+		fieldInfoLis []*orm.FieldInfo
+		nameLis      []string
+		indexLis     []mgo.Index
+	)
+	ret = orm.NewPlan(m.TableName)
+	if fieldInfoLis, err = orm.StructModelInfo(st); err != nil {
+		return
+	}
+	if nameLis, err = m.Collection.Database.CollectionNames(); err != nil {
+		return
+	}
+	for _, name := range nameLis {
+		if name == m.TableName {
+			ret.TableExist = true
+			break
+		}
+	}
+	if ret.TableExist {
+		if indexLis, err = m.Collection.Indexes(); err != nil {
+			return
+		}
+		for _, idx := range indexLis {
+			if idx.Name == "_id_" {
+				continue // 自带的_id索引
+			}
+			ret.IndexActual(idx.Name, idx.Key, idx.Unique)
+		}
+	}
+	// 按Ensure的流程记录语句
+	mp.plan = ret
+	if err = mp.ensure(fieldInfoLis); err != nil {
+		return
+	}
+	ret.Diff()
+	return
+}
+
+// Inspect 读取库中的索引, 字段由一条文档推断; 文档无固定结构, 仅供参考
+func (m *Model) Inspect() (ret *orm.TableInfo, err error) {
+	var (
+		nameLis  []string
+		indexLis []mgo.Index
+		doc      bson.D
+		exist    bool
+	)
+	if nameLis, err = m.Collection.Database.CollectionNames(); err != nil {
+		return
+	}
+	for _, name := range nameLis {
+		if name == m.TableName {
+			exist = true
+			break
+		}
+	}
+	if !exist {
+		err = orm.ErrSyncTableNotExist
+		return
+	}
+	ret = &orm.TableInfo{Name: m.TableName}
+	if indexLis, err = m.Collection.Indexes(); err != nil {
+		return
+	}
+	for _, idx := range indexLis {
+		if idx.Name == "_id_" {
+			continue // 自带的_id索引
+		}
+		ret.Indexes = append(ret.Indexes, &orm.IndexInfo{Name: idx.Name, Key: idx.Key, Unique: idx.Unique})
+	}
+	if err = m.Collection.Find(nil).One(&doc); err == mgo.ErrNotFound {
+		return ret, nil
+	} else if err != nil {
+		return
+	}
+	for _, e := range doc {
+		if e.Name == "_id" {
+			continue
+		}
+		c := &orm.ColumnInfo{Name: e.Name, Null: true}
+		switch e.Value.(type) {
+		case string:
+			c.Kind = "text"
+		case int, int32:
+			c.Kind = "integer"
+		case int64:
+			c.Kind = "bigint"
+		case float64:
+			c.Kind = "float"
+		case bool:
+			c.Kind = "boolean"
+		case time.Time:
+			c.Kind = "timestamp"
+		case []byte, bson.Binary:
+			c.Kind = "bytea"
+		default:
+			c.Kind = "json"
+		}
+		ret.Columns = append(ret.Columns, c)
+	}
+	return
+}
+
+// EnsureWith 同Ensure, 并按参数重命名字段及删除多余的索引; 文档无固定字段, 不修改或删除字段
+func (m *Model) EnsureWith(st interface{}, opt *orm.ArgEnsure) (err error) {
+	var (
+		fieldInfoLis []*orm.FieldInfo
+		p            *orm.Plan
+	)
+	if opt == nil {
+		return m.Ensure(st)
+	}
+	if fieldInfoLis, err = orm.StructModelInfo(st); err != nil {
+		return
+	}
+	// rename
+	if opt.Rename {
+		for _, f := range fieldInfoLis {
+			if len(f.Was) == 0 || f.Was == f.Name {
+				continue
+			}
+			if _, err = m.Collection.UpdateAll(bson.M{f.Was: bson.M{"$exists": true}},
+				bson.M{"$rename": bson.M{f.Was: f.Name}}); err != nil {
+				m.log.Errorf(`[ensure-with] rename %s to %s err: %v`, f.Was, f.Name, err)
+				return
+			}
+		}
+	}
+	// drop
+	if opt.DropIndex {
+		if p, err = m.Plan(st); err != nil {
+			return
+		}
+		for _, idx := range p.IndexExtra {
+			if err = m.Collection.DropIndexName(idx.Name); err != nil {
+				m.log.Errorf(`[ensure-with] drop index %s err: %v`, idx.Name, err)
+				return
+			}
+		}
+	}
+	return m.Ensure(st)
+}
+
 // Begin 事务起始
 func (m *Model) Begin() (orm.Trans, error) {
 	return m.BeginWith(nil)
 }
 
+// BeginNested 不支持保存点
+func (m *Model) BeginNested(parent orm.Trans) (ret orm.Trans, err error) {
+	if parent == nil {
+		return m.BeginWith(nil)
+	}
+	return nil, orm.ErrTransNotSupportMethod
+}
+
 // BeginWith 事务
 func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 	if CfgTxUnsafe {
-		return &Trans{}, nil
+		return &Trans{db: m.db}, nil
 	} else {
 		return nil, orm.ErrTransNotSupport
 	}
@@ -255,6 +525,13 @@ func (m *Model) Select(dest interface{}, query string, args ...interface{}) (err
 	return
 }
 
+// WithContext 返回绑定上下文的model
+func (m *Model) WithContext(ctx context.Context) (ret orm.Model) {
+	r := m.Copy()
+	r.ctx = ctx
+	return r
+}
+
 // With 设置日志级别
 func (m *Model) With(arg *orm.ArgModel) (ret orm.Model) {
 	r := m.Copy()
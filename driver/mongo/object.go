@@ -3,14 +3,17 @@ package mongo
 import (
 	"github.com/suboat/sorm"
 	"github.com/suboat/sorm/log"
+	"github.com/suboat/sorm/songo"
 
 	//"gopkg.in/mgo.v2"
 	//"gopkg.in/mgo.v2/bson"
 	"github.com/globalsign/mgo"
 	"github.com/globalsign/mgo/bson"
 
+	"context"
 	"reflect"
 	"strings"
+	"time"
 )
 
 // Objects
@@ -28,39 +31,157 @@ type Objects struct {
 	// filter
 	queryM orm.M    // store filter regular
 	sorts  []string // sort
+	fields []string // select fields
+	omit   []string // omit fields
+	scope  int      // soft delete scope: orm.SoftDelete*
+
+	// cursor: keyset pagination
+	cursor     *orm.Cursor // cursor from After
+	cursorM    orm.M       // cursor filter, only for All/Iter, not in Count and Meta.Key
+	cursorNext string      // cursor of next page
+	cursorPrev string      // cursor of prev page
 
 	// cache
-	err error
-	log orm.Logger
+	err    error
+	log    orm.Logger
+	result orm.Result
+	trans  orm.Trans       // 事务操作时, 钩子出错标记回滚
+	ctx    context.Context // nil: context.Background()
 }
 
 // query检查
 func (o *Objects) queryCheck() {
 	if o.query == nil {
-		o.query = o.Model.Collection.Find(nil)
+		o.find()
+	}
+}
+
+// find 按搜索条件及软删除范围生成query, 游标条件只加入query, 不计入o.m
+func (o *Objects) find() {
+	o.m = nil
+	if err := o.Model.infoLoad(); err != nil {
+		o.err = err
+	}
+	q := orm.SoftDeleteQuery(o.Model.info(), o.queryM, o.scope)
+	if q != nil {
+		if m, err := orm.HookParseMgo(q); err != nil {
+			o.err = err // cache
+		} else {
+			o.m = bson.M(m)
+		}
+	}
+	filter := o.m
+	if o.cursorM != nil {
+		if m, err := orm.HookParseMgo(orm.QueryAnd(q, o.cursorM)); err != nil {
+			o.err = err
+		} else {
+			filter = bson.M(m)
+		}
+	}
+	o.query = o.Model.Collection.Find(filter)
+	o.selectCheck()
+}
+
+// reload 重建query, 并恢复排序及分页
+func (o *Objects) reload() {
+	o.count = -1
+	o.find()
+	sorts := o.sorts
+	if o.cursor != nil && o.cursor.Prev {
+		// 向前翻页: 反向排序读取, 读取后再翻转
+		sorts = orm.CursorSortsReverse(sorts)
+	}
+	if len(sorts) > 0 {
+		_fields := []string{}
+		for _, _s := range sorts {
+			_fields = append(_fields, sortField(_s))
+		}
+		o.query = o.query.Sort(_fields...)
+	}
+	if o.skip > 0 {
+		o.query = o.query.Skip(o.skip)
+	}
+	if o.limit > 0 {
+		o.query = o.query.Limit(o.limit)
 	}
 }
 
+// ctxCheck 上下文检查, 有截止时间时限制查询执行时长
+func (o *Objects) ctxCheck() (err error) {
+	if o.ctx == nil {
+		return
+	}
+	if o.ctx.Err() != nil {
+		return orm.ErrContextCanceled
+	}
+	if dl, ok := o.ctx.Deadline(); ok && o.query != nil {
+		o.query.SetMaxTime(time.Until(dl))
+	}
+	return
+}
+
+// collection 写操作及统计的集合; 上下文有截止时间时复制会话, 以剩余时长为socket超时, 用完需调用done
+// 取消只在执行前由ctxCheck检查, 执行中的写操作不会中断
+func (o *Objects) collection() (c *mgo.Collection, done func()) {
+	c, done = o.Model.Collection, func() {}
+	if o.ctx == nil {
+		return
+	}
+	if dl, ok := o.ctx.Deadline(); ok {
+		s := c.Database.Session.Copy()
+		s.SetSocketTimeout(time.Until(dl))
+		c, done = c.With(s), s.Close
+	}
+	return
+}
+
+// countQuery 在集合c上生成统计用的query, 游标条件不计入总数; 上下文有截止时间时限制执行时长
+func (o *Objects) countQuery(c *mgo.Collection) (q *mgo.Query) {
+	q = c.Find(o.m)
+	if o.skip > 0 {
+		q = q.Skip(o.skip)
+	}
+	if o.limit > 0 {
+		q = q.Limit(o.limit)
+	}
+	if o.ctx != nil {
+		if dl, ok := o.ctx.Deadline(); ok {
+			q.SetMaxTime(time.Until(dl))
+		}
+	}
+	return
+}
+
 // countCheck 数目检查
 func (o *Objects) countCheck() {
 	if o.err != nil {
 		return
 	}
+	// 先生成query, 无搜索条件时同样限制执行时长
+	if o.queryCheck(); o.err != nil {
+		return
+	}
+	if err := o.ctxCheck(); err != nil {
+		o.err = err
+		return
+	}
 	if o.count == -1 {
-		if o.query == nil {
-			o.count, o.err = o.Model.Collection.Count()
-		} else {
-			o.count, o.err = o.query.Count()
-		}
+		c, done := o.collection()
+		o.count, o.err = o.countQuery(c).Count()
+		done()
 	}
 }
 
 // All all
 func (o *Objects) All(result interface{}) (err error) {
+	defer o.hookAfter(orm.HookAfterFind, result, &err)
 	if o.err != nil {
 		return o.err
 	}
 	o.countCheck()
+	if o.err != nil {
+		return o.err
+	}
 
 	if err = o.query.All(result); err == nil {
 		// nums
@@ -68,6 +189,13 @@ func (o *Objects) All(result interface{}) (err error) {
 		if v.Kind() == reflect.Slice {
 			o.nums = v.Len()
 		}
+		// cursor
+		if len(o.sorts) > 0 {
+			if o.cursor != nil && o.cursor.Prev {
+				orm.SliceReverse(result)
+			}
+			o.cursorNext, o.cursorPrev = orm.CursorTokens(o.sorts, result, o.limit, o.cursor)
+		}
 		// debug
 		o.log.Debug("[MONGO ALL] ", o.m)
 	}
@@ -76,11 +204,16 @@ func (o *Objects) All(result interface{}) (err error) {
 
 // One 保存一条记录至
 func (o *Objects) One(result interface{}) (err error) {
+	defer o.hookAfter(orm.HookAfterFind, result, &err)
 	if o.err != nil {
 		err = o.err
 		return
 	}
 	o.countCheck()
+	if o.err != nil {
+		err = o.err
+		return
+	}
 
 	if o.count >= 1 {
 		err = o.query.One(result)
@@ -97,6 +230,9 @@ func (o *Objects) One(result interface{}) (err error) {
 func (o *Objects) Count() (n int, err error) {
 	o.count = -1
 	o.countCheck()
+	if o.err == orm.ErrContextCanceled {
+		err = o.err
+	}
 	return o.count, err
 	//if o.err != nil {
 	//	return -1, o.err
@@ -113,22 +249,18 @@ func (o *Objects) Count() (n int, err error) {
 // Filter 搜索
 func (o *Objects) Filter(t orm.M) orm.Objects {
 	o.queryM = t // cache
-	if m, err := orm.HookParseMgo(t); err != nil {
-		o.err = err // cache
+	if o.find(); o.err != nil {
 		return o
-	} else {
-		o.m = bson.M(m)
 	}
-	o.query = o.Model.Collection.Find(o.m)
 	o.countCheck()
 	return o
 }
 
-// Sort 排序 小写
+// Sort 排序 小写, $text$按全文搜索的相关度排序
 func (o *Objects) Sort(fields ...string) orm.Objects {
 	_fields := []string{}
 	for _, _s := range fields {
-		_fields = append(_fields, strings.ToLower(_s))
+		_fields = append(_fields, sortField(_s))
 	}
 	o.queryCheck()
 	o.query = o.query.Sort(_fields...)
@@ -136,12 +268,143 @@ func (o *Objects) Sort(fields ...string) orm.Objects {
 	return o
 }
 
+// sortField 排序字段转为小写, 相关度排序转为mgo的textScore写法, 总是最相关在前
+func sortField(s string) string {
+	if strings.TrimLeft(s, "+-") == songo.TagValText {
+		return "$textScore:score"
+	}
+	return strings.ToLower(s)
+}
+
+// Fields 只取指定字段 小写
+func (o *Objects) Fields(fields ...string) orm.Objects {
+	for _, _s := range fields {
+		if _s = songo.SafeField(_s); len(_s) > 0 {
+			o.fields = append(o.fields, _s)
+		}
+	}
+	o.selectCheck()
+	return o
+}
+
+// Omit 排除字段 小写
+func (o *Objects) Omit(fields ...string) orm.Objects {
+	for _, _s := range fields {
+		if _s = songo.SafeField(_s); len(_s) > 0 {
+			o.omit = append(o.omit, _s)
+		}
+	}
+	o.selectCheck()
+	return o
+}
+
+// selectCheck 按Fields/Omit设置返回字段
+func (o *Objects) selectCheck() {
+	sel := bson.M{}
+	if len(o.fields) > 0 {
+		for _, _s := range o.fields {
+			sel[strings.ToLower(_s)] = 1
+		}
+		for _, _s := range o.omit {
+			delete(sel, strings.ToLower(_s))
+		}
+	} else {
+		for _, _s := range o.omit {
+			sel[strings.ToLower(_s)] = 0
+		}
+	}
+	if len(sel) == 0 {
+		return
+	}
+	o.queryCheck()
+	o.query = o.query.Select(sel)
+}
+
+// After 游标分页, 需在Filter及Sort之后调用
+func (o *Objects) After(token string) orm.Objects {
+	if len(token) == 0 || o.err != nil {
+		return o
+	}
+	var (
+		c   *orm.Cursor
+		m   orm.M
+		err error
+	)
+	if c, err = orm.CursorDecode(token); err == nil {
+		m, err = orm.CursorFilter(o.sorts, c)
+	}
+	if err != nil {
+		o.err = err
+		return o
+	}
+	o.cursor, o.cursorM = c, m
+	o.reload()
+	return o
+}
+
+// WithDeleted 包含已软删除的记录
+func (o *Objects) WithDeleted() orm.Objects {
+	return o.scopeSet(orm.SoftDeleteWith)
+}
+
+// OnlyDeleted 只取已软删除的记录
+func (o *Objects) OnlyDeleted() orm.Objects {
+	return o.scopeSet(orm.SoftDeleteOnly)
+}
+
+// scopeSet 设置软删除的搜索范围
+func (o *Objects) scopeSet(scope int) orm.Objects {
+	if o.scope != scope {
+		o.scope = scope
+		o.reload()
+	}
+	return o
+}
+
 // 去重
 func (o *Objects) Group(fields ...string) orm.Objects {
 	o.log.Debugf(`[group-not-implement]`)
 	return o
 }
 
+// hook 调用记录上的钩子, 在事务中出错时标记事务回滚
+func (o *Objects) hook(name string, record interface{}) (err error) {
+	if err = orm.HookCall(name, record); err != nil {
+		o.log.Warnf(`[mongo-hook] %s err: %v`, name, err)
+		if o.trans != nil {
+			o.trans.ErrorSet(err)
+		}
+	}
+	return
+}
+
+// hookAfter 操作成功后调用钩子, 用于defer
+func (o *Objects) hookAfter(name string, record interface{}, err *error) {
+	if *err == nil {
+		*err = o.hook(name, record)
+	}
+}
+
+// timeFillMgo map更新时补充updated字段
+func (o *Objects) timeFillMgo(val map[string]interface{}) map[string]interface{} {
+	_, hasSet := val["$set"]
+	_, hasInc := val["$inc"]
+	if !hasSet && !hasInc {
+		return orm.TimeFillMap(o.Model.info(), val)
+	}
+	var set map[string]interface{}
+	switch _v := val["$set"].(type) {
+	case bson.M:
+		set = _v
+	case map[string]interface{}:
+		set = _v
+	}
+	if set = orm.TimeFillMap(o.Model.info(), set); len(set) > 0 {
+		val["$set"] = set
+	}
+	return val
+}
+
 // Meta 摘要
 func (ob *Objects) Meta() (mt *orm.Meta, err error) {
 	if ob.err != nil {
@@ -170,6 +433,15 @@ func (ob *Objects) Meta() (mt *orm.Meta, err error) {
 	if ob.sorts != nil {
 		mt.Sort = ob.sorts
 	}
+	// field
+	if ob.fields != nil {
+		mt.Field = ob.fields
+	}
+	if ob.omit != nil {
+		mt.Omit = ob.omit
+	}
+	// cursor
+	mt.Next, mt.Prev = ob.cursorNext, ob.cursorPrev
 	return
 }
 
@@ -190,25 +462,47 @@ func (o *Objects) Skip(n int) orm.Objects {
 }
 
 // Delete 删除
-func (o *Objects) Delete() (err error) {
+func (o *Objects) Delete(record ...interface{}) (err error) {
+	for _, r := range record {
+		if err = o.hook(orm.HookBeforeDelete, r); err != nil {
+			return
+		}
+	}
+	if err = o.Model.infoLoad(); err != nil {
+		return
+	}
+	if set, ok := orm.SoftDeleteSet(o.Model.info(), true); ok {
+		// 软删除: 只标记不删除
+		return o.Update(set)
+	}
 	o.countCheck()
+	if o.err != nil {
+		err = o.err
+		return
+	}
+	c, done := o.collection()
+	defer done()
 	if o.count == 0 {
 		err = orm.ErrMatchNone
 	} else if o.count == 1 {
-		err = o.Model.Collection.Remove(o.m) // 删除一个记录
+		err = c.Remove(o.m) // 删除一个记录
 	} else {
-		_, err = o.Model.Collection.RemoveAll(o.m) // 删除所有匹配记录
+		_, err = c.RemoveAll(o.m) // 删除所有匹配记录
 	}
 	return
 }
 
 // DeleteOne 删除一条记录
-func (ob *Objects) DeleteOne() (err error) {
+func (ob *Objects) DeleteOne(record ...interface{}) (err error) {
 	ob.countCheck()
+	if ob.err != nil {
+		err = ob.err
+		return
+	}
 	if ob.count == 0 {
 		err = orm.ErrMatchNone
 	} else if ob.count == 1 {
-		err = ob.Delete()
+		err = ob.Delete(record...)
 	} else {
 		err = orm.ErrMatchMultiple
 	}
@@ -217,13 +511,142 @@ func (ob *Objects) DeleteOne() (err error) {
 
 // Create 插入记录
 func (o *Objects) Create(i interface{}) (err error) {
-	err = o.Model.Collection.Insert(i)
+	if err = o.hook(orm.HookBeforeCreate, i); err != nil {
+		return
+	}
+	defer o.hookAfter(orm.HookAfterCreate, i, &err)
+	orm.TimeFill(i, true)
+	if err = o.ctxCheck(); err != nil {
+		return
+	}
+	c, done := o.collection()
+	defer done()
+	err = c.Insert(i)
+	return
+}
+
+// CreateMany 批量插入记录
+func (o *Objects) CreateMany(st interface{}) (err error) {
+	if err = o.hook(orm.HookBeforeCreate, st); err != nil {
+		return
+	}
+	defer o.hookAfter(orm.HookAfterCreate, st, &err)
+	orm.TimeFill(st, true)
+	if err = o.ctxCheck(); err != nil {
+		return
+	}
+	c, done := o.collection()
+	defer done()
+	v := reflect.Indirect(reflect.ValueOf(st))
+	if v.Kind() != reflect.Slice {
+		return orm.ErrCreateManyNotSlice
+	}
+	var (
+		n   = v.Len()
+		res = orm.ResultBatch{}
+	)
+	o.result = res
+	for i := 0; i < n; i += CfgBatchRowsMax {
+		j := i + CfgBatchRowsMax
+		if j > n {
+			j = n
+		}
+		docs := make([]interface{}, 0, j-i)
+		for k := i; k < j; k++ {
+			docs = append(docs, v.Index(k).Interface())
+		}
+		if err = c.Insert(docs...); err != nil {
+			return
+		}
+		res = append(res, resultInsert(len(docs)))
+		o.result = res
+	}
+	return
+}
+
+// Upsert 插入或更新, 冲突字段默认取主键或唯一索引
+func (o *Objects) Upsert(record interface{}, keys ...string) (err error) {
+	if err = o.ctxCheck(); err != nil {
+		return
+	}
+	c, done := o.collection()
+	defer done()
+	orm.TimeFill(record, true)
+	var (
+		conflict  []string
+		columns   []string
+		update    []string
+		version   string
+		b         []byte
+		doc       = bson.M{}
+		selector  = bson.M{}
+		insert    = bson.M{} // 仅插入时写入的字段, 如created
+		change    = bson.M{}
+		updateMap = make(map[string]bool)
+	)
+	if conflict, columns, update, version, err = orm.UpsertFields(record, keys, ""); err != nil {
+		return
+	}
+	if b, err = bson.Marshal(record); err != nil {
+		return
+	}
+	if err = bson.Unmarshal(b, &doc); err != nil {
+		return
+	}
+	for _, k := range conflict {
+		selector[k] = doc[k]
+		delete(doc, k)
+	}
+	for _, k := range update {
+		updateMap[k] = true
+	}
+	for _, c := range columns {
+		if !updateMap[c] {
+			if v, ok := doc[c]; ok {
+				insert[c] = v
+				delete(doc, c)
+			}
+		}
+	}
+	if len(doc) > 0 {
+		change["$set"] = doc
+	}
+	if len(insert) > 0 {
+		change["$setOnInsert"] = insert
+	}
+	if len(change) == 0 {
+		change["$setOnInsert"] = selector
+	}
+	if len(version) > 0 && len(doc) > 0 {
+		// 乐观锁: 冲突更新时版本号在原值上加1; $inc与$setOnInsert不能作用于同一字段, 先更新, 无匹配时再插入
+		set := bson.M{"$set": doc, "$inc": bson.M{version: 1}}
+		if err = c.Update(selector, set); err != mgo.ErrNotFound {
+			return
+		}
+		if err = c.Insert(record); mgo.IsDup(err) {
+			// 并发插入: 改为更新
+			err = c.Update(selector, set)
+		}
+		return
+	}
+	_, err = c.Upsert(selector, change)
 	return
 }
 
 // Update 更新记录
 func (o *Objects) Update(i interface{}) (err error) {
+	if err = o.hook(orm.HookBeforeUpdate, i); err != nil {
+		return
+	}
+	defer o.hookAfter(orm.HookAfterUpdate, i, &err)
+	orm.TimeFill(i, false)
 	o.countCheck()
+	if o.err != nil {
+		err = o.err
+		return
+	}
+	c, done := o.collection()
+	defer done()
 	if o.count == 0 {
 		err = orm.ErrMatchNone
 	} else if o.count == 1 {
@@ -233,28 +656,63 @@ func (o *Objects) Update(i interface{}) (err error) {
 			if val, err = orm.HookParseMgo(val); err != nil {
 				return
 			}
+			val = o.timeFillMgo(val)
 			// 特殊操作处理
 			if _, ok := val["$set"]; ok {
-				err = o.Model.Collection.Update(o.m, bson.M(val))
+				err = c.Update(o.m, bson.M(val))
 			} else if _, ok := val["$inc"]; ok {
-				err = o.Model.Collection.Update(o.m, bson.M(val))
+				err = c.Update(o.m, bson.M(val))
 			} else {
-				err = o.Model.Collection.Update(o.m, bson.M{"$set": val})
+				err = c.Update(o.m, bson.M{"$set": val})
 			}
 		default:
 			// 覆盖更新
-			err = o.Model.Collection.Update(o.m, i)
+			err = o.updateStruct(c, i)
 		}
 	} else {
 		// multi update only works with $ operators
-		_, err = o.Model.Collection.UpdateAll(o.m, bson.M{"$set": i}) // 更新所有匹配记录
+		_, err = c.UpdateAll(o.m, bson.M{"$set": i}) // 更新所有匹配记录
+	}
+	return
+}
+
+// updateStruct 覆盖更新一条记录, 定义version字段时在条件中校验版本并递增
+func (o *Objects) updateStruct(c *mgo.Collection, record interface{}) (err error) {
+	where, version := orm.VersionQuery(o.Model.info(), nil, record)
+	if !version {
+		return c.Update(o.m, record)
+	}
+	if where, err = orm.HookParseMgo(where); err != nil {
+		return
+	}
+	selector := bson.M(where)
+	if len(o.m) > 0 {
+		selector = bson.M{"$and": []interface{}{o.m, selector}}
+	}
+	orm.VersionAdd(o.Model.info(), record, 1)
+	if err = c.Update(selector, record); err == mgo.ErrNotFound {
+		err = orm.ErrVersionConflict
+	}
+	if err != nil {
+		orm.VersionAdd(o.Model.info(), record, -1)
 	}
 	return
 }
 
 // UpdateOne 更新记录, 1条
 func (o *Objects) UpdateOne(i interface{}) (err error) {
+	if err = o.hook(orm.HookBeforeUpdate, i); err != nil {
+		return
+	}
+	defer o.hookAfter(orm.HookAfterUpdate, i, &err)
+	orm.TimeFill(i, false)
 	o.countCheck()
+	if o.err != nil {
+		err = o.err
+		return
+	}
+	c, done := o.collection()
+	defer done()
 	if o.count == 0 {
 		err = orm.ErrMatchNone
 	} else if o.count == 1 {
@@ -264,17 +722,18 @@ func (o *Objects) UpdateOne(i interface{}) (err error) {
 			if val, err = orm.HookParseMgo(val); err != nil {
 				return
 			}
+			val = o.timeFillMgo(val)
 			// 特殊操作处理
 			if _, ok := val["$set"]; ok {
-				err = o.Model.Collection.Update(o.m, bson.M(val))
+				err = c.Update(o.m, bson.M(val))
 			} else if _, ok := val["$inc"]; ok {
-				err = o.Model.Collection.Update(o.m, bson.M(val))
+				err = c.Update(o.m, bson.M(val))
 			} else {
-				err = o.Model.Collection.Update(o.m, bson.M{"$set": val})
+				err = c.Update(o.m, bson.M{"$set": val})
 			}
 		default:
 			// 覆盖更新
-			err = o.Model.Collection.Update(o.m, i)
+			err = o.updateStruct(c, i)
 		}
 	} else {
 		err = orm.ErrMatchMultiple
@@ -282,33 +741,69 @@ func (o *Objects) UpdateOne(i interface{}) (err error) {
 	return
 }
 
+// Restore 恢复已软删除的记录
+func (o *Objects) Restore() (err error) {
+	if err = o.Model.infoLoad(); err != nil {
+		return
+	}
+	set, ok := orm.SoftDeleteSet(o.Model.info(), false)
+	if !ok {
+		return orm.ErrSoftDeleteUndefined
+	}
+	o.scopeSet(orm.SoftDeleteOnly)
+	return o.Update(set)
+}
+
 /*事务操作*/
 
 // TDelete 事务中删除
-func (o *Objects) TDelete(t orm.Trans) (err error) {
-	if !CfgTxUnsafe {
-		err = orm.ErrTransNotSupport
-	} else {
-		err = o.Delete()
+func (o *Objects) TDelete(t orm.Trans, record ...interface{}) (err error) {
+	if err = o.transCheck(t); err == nil {
+		o.trans = t
+		err = o.Delete(record...)
+	}
+	return
+}
+
+// TRestore 事务中恢复已软删除的记录
+func (o *Objects) TRestore(t orm.Trans) (err error) {
+	if err = o.transCheck(t); err == nil {
+		o.trans = t
+		err = o.Restore()
 	}
 	return
 }
 
 // TCreate 事务中创建
 func (o *Objects) TCreate(i interface{}, t orm.Trans) (err error) {
-	if !CfgTxUnsafe {
-		err = orm.ErrTransNotSupport
-	} else {
+	if err = o.transCheck(t); err == nil {
+		o.trans = t
 		err = o.Create(i)
 	}
 	return
 }
 
+// TCreateMany 事务中批量创建
+func (o *Objects) TCreateMany(st interface{}, t orm.Trans) (err error) {
+	if err = o.transCheck(t); err == nil {
+		o.trans = t
+		err = o.CreateMany(st)
+	}
+	return
+}
+
+// TUpsert 事务中插入或更新
+func (o *Objects) TUpsert(record interface{}, t orm.Trans, keys ...string) (err error) {
+	if err = o.transCheck(t); err == nil {
+		err = o.Upsert(record, keys...)
+	}
+	return
+}
+
 // TUpdate 事务中更新
 func (o *Objects) TUpdate(i interface{}, t orm.Trans) (err error) {
-	if !CfgTxUnsafe {
-		err = orm.ErrTransNotSupport
-	} else {
+	if err = o.transCheck(t); err == nil {
+		o.trans = t
 		err = o.Update(i)
 	}
 	return
@@ -316,9 +811,8 @@ func (o *Objects) TUpdate(i interface{}, t orm.Trans) (err error) {
 
 // TUpdateOne 事务中更新
 func (o *Objects) TUpdateOne(i interface{}, t orm.Trans) (err error) {
-	if !CfgTxUnsafe {
-		err = orm.ErrTransNotSupport
-	} else {
+	if err = o.transCheck(t); err == nil {
+		o.trans = t
 		err = o.UpdateOne(i)
 	}
 	return
@@ -326,9 +820,8 @@ func (o *Objects) TUpdateOne(i interface{}, t orm.Trans) (err error) {
 
 // TAll 在事务中获取
 func (o *Objects) TAll(i interface{}, t orm.Trans) (err error) {
-	if !CfgTxUnsafe {
-		err = orm.ErrTransNotSupport
-	} else {
+	if err = o.transCheck(t); err == nil {
+		o.trans = t
 		err = o.All(i)
 	}
 	return
@@ -336,9 +829,8 @@ func (o *Objects) TAll(i interface{}, t orm.Trans) (err error) {
 
 // TOne fetch one to (in tx)
 func (o *Objects) TOne(i interface{}, t orm.Trans) (err error) {
-	if !CfgTxUnsafe {
-		err = orm.ErrTransNotSupport
-	} else {
+	if err = o.transCheck(t); err == nil {
+		o.trans = t
 		err = o.One(i)
 	}
 	return
@@ -346,31 +838,43 @@ func (o *Objects) TOne(i interface{}, t orm.Trans) (err error) {
 
 // TCount is Count in transaction
 func (o *Objects) TCount(t orm.Trans) (n int, err error) {
-	if !CfgTxUnsafe {
-		err = orm.ErrTransNotSupport
-	} else {
+	if err = o.transCheck(t); err == nil {
 		n, err = o.Count()
 	}
 	return
 }
 
 // TDeleteOne 事务中删除
-func (o *Objects) TDeleteOne(t orm.Trans) (err error) {
-	if !CfgTxUnsafe {
-		err = orm.ErrTransNotSupport
-	} else {
-		err = o.DeleteOne()
+func (o *Objects) TDeleteOne(t orm.Trans, record ...interface{}) (err error) {
+	if err = o.transCheck(t); err == nil {
+		o.trans = t
+		err = o.DeleteOne(record...)
 	}
 	return
 }
 
-// TLockUpdate 兼容
+// TLockUpdate 兼容, 只检查事务
 func (o *Objects) TLockUpdate(t orm.Trans) (err error) {
+	return o.transCheck(t)
+}
+
+// transCheck 检查事务由本库开启
+func (o *Objects) transCheck(_t orm.Trans) (err error) {
+	if !CfgTxUnsafe {
+		return orm.ErrTransNotSupport
+	}
+	if _t == nil {
+		return orm.ErrTransEmpty
+	}
+	if t, ok := _t.(*Trans); !ok || t == nil || t.db != o.Model.db {
+		return orm.ErrTransInvalid
+	}
 	return
 }
 
-// GetResult sql 兼容
+// GetResult 取结果, 目前仅CreateMany记录各批插入数
 func (o *Objects) GetResult() (r orm.Result, err error) {
+	r = o.result
 	return
 }
 
@@ -391,6 +895,13 @@ func (ob *Objects) Copy() (ret *Objects) {
 	return
 }
 
+// WithContext 绑定上下文
+func (ob *Objects) WithContext(ctx context.Context) (ret orm.Objects) {
+	r := ob.Copy()
+	r.ctx = ctx
+	return r
+}
+
 // With 设置日志级别
 func (ob *Objects) With(arg *orm.ArgObjects) (ret orm.Objects) {
 	r := ob.Copy()
@@ -401,3 +912,16 @@ func (ob *Objects) With(arg *orm.ArgObjects) (ret orm.Objects) {
 	}
 	return r
 }
+
+// resultInsert 插入结果
+type resultInsert int64
+
+// LastInsertId mongo不支持
+func (r resultInsert) LastInsertId() (int64, error) {
+	return 0, orm.ErrNotImplementMethod
+}
+
+// RowsAffected 插入数
+func (r resultInsert) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
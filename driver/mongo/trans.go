@@ -3,6 +3,7 @@ package mongo
 import (
 	"github.com/suboat/sorm"
 
+	"context"
 	"database/sql"
 	"strings"
 	"time"
@@ -21,6 +22,8 @@ type Trans struct {
 	debugInfo []string
 	// trans expired
 	timer *time.Timer
+	// 开启事务的数据库
+	db *DatabaseSQL
 }
 
 func (t *Trans) Error() error {
@@ -91,6 +94,11 @@ func (t *Trans) Exec(query string, args ...interface{}) (result sql.Result, err
 	return
 }
 
+// ExecContext 带上下文执行; mongo不支持执行语句, ctx不生效
+func (t *Trans) ExecContext(ctx context.Context, query string, args ...interface{}) (result sql.Result, err error) {
+	return t.Exec(query, args...)
+}
+
 // Get 在事务中获取
 func (t *Trans) Get(dest interface{}, query string, args ...interface{}) (err error) {
 	if t.TxError != nil {
@@ -145,6 +153,21 @@ func (t *Trans) Rollback() (err error) {
 	return
 }
 
+// Savepoint 不支持保存点
+func (t *Trans) Savepoint(name string) error {
+	return orm.ErrTransNotSupportMethod
+}
+
+// RollbackTo 不支持保存点
+func (t *Trans) RollbackTo(name string) error {
+	return orm.ErrTransNotSupportMethod
+}
+
+// Release 不支持保存点
+func (t *Trans) Release(name string) error {
+	return orm.ErrTransNotSupportMethod
+}
+
 // Promise 返回已绑定
 func (t *Trans) Promise() []func(error) {
 	return t.promise
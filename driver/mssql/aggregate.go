@@ -0,0 +1,127 @@
+package mssql
+
+import (
+	"github.com/suboat/sorm"
+
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// Sum 求和
+func (ob *Objects) Sum(field string) (float64, error) {
+	return ob.aggregateOne(orm.AggSum, field)
+}
+
+// Avg 平均值
+func (ob *Objects) Avg(field string) (float64, error) {
+	return ob.aggregateOne(orm.AggAvg, field)
+}
+
+// Min 最小值
+func (ob *Objects) Min(field string) (float64, error) {
+	return ob.aggregateOne(orm.AggMin, field)
+}
+
+// Max 最大值
+func (ob *Objects) Max(field string) (float64, error) {
+	return ob.aggregateOne(orm.AggMax, field)
+}
+
+// Aggregate 分组聚合, 每组一行写入dest
+func (ob *Objects) Aggregate(groupBy []string, aggs map[string]string, dest interface{}) (err error) {
+	defer ob.ctxErr(&err)
+	var (
+		lis    []*orm.AggregateField
+		groups []string
+		fields []string
+	)
+	if lis, err = orm.AggregateParse(aggs); err != nil {
+		return
+	}
+	if groupBy, err = orm.AggregateGroupBy(groupBy); err != nil {
+		return
+	}
+	for _, f := range groupBy {
+		groups = append(groups, `"`+f+`"`)
+	}
+	fields = append(fields, groups...)
+	for _, a := range lis {
+		fields = append(fields, aggregateExpr(a))
+	}
+	if err = orm.AggregateSorts(ob.sorts, groupBy, lis); err != nil {
+		return
+	}
+	if err = ob.updateQuery(); err != nil {
+		return
+	}
+	var (
+		sqlCmd string
+		where  string
+		group  string
+		order  = ob.cacheQueryOrder
+		limit  string
+	)
+	if len(ob.cacheQueryWhere) > 0 {
+		where = fmt.Sprintf("WHERE %s", ob.cacheQueryWhere)
+	}
+	if len(groups) > 0 {
+		group = fmt.Sprintf("GROUP BY %s", strings.Join(groups, ","))
+	}
+	if ob.limit > 0 {
+		limit = ob.cacheQueryLimit
+	} else if ob.skip > 0 {
+		limit = fmt.Sprintf(`OFFSET %d ROWS`, ob.skip)
+	}
+	if len(limit) > 0 && len(order) == 0 {
+		// OFFSET须与ORDER BY同用
+		order = `ORDER BY (SELECT NULL)`
+	}
+	sqlCmd = fmt.Sprintf(`SELECT %s FROM %s %s %s %s %s`,
+		strings.Join(fields, ","), ob.Model.GetTable(), where, group, order, limit)
+	sqlCmd = strings.ReplaceAll(strings.TrimSpace(sqlCmd), "  ", " ")
+	if err = ob.selectDo(ob.Model.DatabaseSQL.DB, false, dest, sqlCmd, ob.cacheQueryValues...); err != nil {
+		ob.log.Errorf(`[sql-aggregate] %s VAL: %v err: %v`, sqlCmd, ob.cacheQueryValues, err)
+	} else {
+		ob.log.Debugf(`[sql-aggregate] %s VAL: %v`, sqlCmd, ob.cacheQueryValues)
+	}
+	return
+}
+
+// aggregateOne 单值聚合, 无匹配记录时返回0
+func (ob *Objects) aggregateOne(fn string, field string) (ret float64, err error) {
+	defer ob.ctxErr(&err)
+	var a *orm.AggregateField
+	if a, err = orm.AggregateFieldNew("value", fmt.Sprintf("%s(%s)", fn, field)); err != nil {
+		return
+	}
+	if err = ob.updateQuery(); err != nil {
+		return
+	}
+	var (
+		sqlCmd string
+		where  string
+		val    sql.NullFloat64
+	)
+	if len(ob.cacheQueryWhere) > 0 {
+		where = fmt.Sprintf("WHERE %s", ob.cacheQueryWhere)
+	}
+	sqlCmd = fmt.Sprintf(`SELECT %s FROM %s %s`, aggregateExpr(a), ob.Model.GetTable(), where)
+	sqlCmd = strings.TrimSpace(sqlCmd)
+	if err = ob.Model.DatabaseSQL.DB.GetContext(ob.getContext(), &val, sqlCmd, ob.cacheQueryValues...); err != nil {
+		ob.log.Errorf(`[sql-%s] %s VAL: %v err: %v`, fn, sqlCmd, ob.cacheQueryValues, err)
+		return
+	}
+	ob.log.Debugf(`[sql-%s] %s VAL: %v`, fn, sqlCmd, ob.cacheQueryValues)
+	ret = val.Float64
+	return
+}
+
+// aggregateExpr 聚合项的sql片段
+func aggregateExpr(a *orm.AggregateField) string {
+	field := a.Field
+	if field != "*" {
+		field = `"` + field + `"`
+	}
+	return fmt.Sprintf(`%s(%s) AS %s`, strings.ToUpper(a.Func), field, `"`+a.Alias+`"`)
+}
@@ -0,0 +1,98 @@
+package mssql
+
+import (
+	"github.com/jmoiron/sqlx"
+	"github.com/suboat/sorm"
+)
+
+// Iter 游标实现, 首次Next时查询
+type Iter struct {
+	ob   *Objects
+	ex   execer
+	rows *sqlx.Rows
+	err  error
+	done bool // 已读完或已关闭
+}
+
+// Next 读取下一条记录
+func (it *Iter) Next(result interface{}) bool {
+	if it.err != nil || it.done {
+		return false
+	}
+	if it.rows == nil {
+		if it.rows, it.err = it.ob.iter(it.ex, result); it.err != nil {
+			it.done = true
+			return false
+		}
+	}
+	if !it.rows.Next() {
+		it.err = it.rows.Err()
+		_ = it.Close()
+		return false
+	}
+	if it.err = it.rows.StructScan(result); it.err != nil {
+		_ = it.Close()
+		return false
+	}
+	if it.err = it.ob.hook(it.ex, orm.HookAfterFind, result); it.err != nil {
+		_ = it.Close()
+		return false
+	}
+	it.ob.nums++
+	return true
+}
+
+// Err 迭代中的错误
+func (it *Iter) Err() error {
+	return orm.ContextErr(it.ob.ctx, it.err)
+}
+
+// Close 关闭游标
+func (it *Iter) Close() (err error) {
+	it.done = true
+	if it.rows != nil {
+		err = it.rows.Close()
+	}
+	return
+}
+
+// Iter 逐条读取
+func (ob *Objects) Iter() orm.Iter {
+	return &Iter{ob: ob, ex: ob.Model.DatabaseSQL.DB}
+}
+
+// Each 逐条读取并回调
+func (ob *Objects) Each(result interface{}, fn func() error) (err error) {
+	return orm.IterEach(ob.Iter(), result, fn)
+}
+
+// TIter 在事务中逐条读取, 游标关闭前不可在同一事务中执行其它语句
+func (ob *Objects) TIter(_t orm.Trans) orm.Iter {
+	t, err := ob.trans(_t)
+	if err != nil {
+		return &Iter{ob: ob, err: err}
+	}
+	_ = t.DebugPush(`[iter]` + ob.cacheQueryWhere)
+	return &Iter{ob: ob, ex: t}
+}
+
+// TEach 在事务中逐条读取并回调
+func (ob *Objects) TEach(result interface{}, fn func() error, _t orm.Trans) (err error) {
+	return orm.IterEach(ob.TIter(_t), result, fn)
+}
+
+// iter 执行游标查询
+func (ob *Objects) iter(ex execer, result interface{}) (rows *sqlx.Rows, err error) {
+	defer ob.ctxErr(&err)
+	if err = ob.allPrepare(); err != nil {
+		return
+	}
+	_sql, args := ob.allSQL(result)
+	if rows, err = ex.QueryxContext(ob.getContext(), _sql, args...); err != nil {
+		ob.log.Errorf(`[sql-iter] %s VAL: %v err: %v`, _sql, args, err)
+		return
+	}
+	ob.nums = 0
+	ob.log.Debugf(`[sql-iter] %s`, _sql)
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/suboat/sorm/log"
 	"reflect"
 
+	"context"
 	"database/sql"
 	"fmt"
 	"runtime"
@@ -26,15 +27,21 @@ type Model struct {
 	ContigUpdate       string
 	AutoIncrementField string
 	//
-	log orm.Logger
+	plan *orm.Plan // 非nil时Ensure只记录语句, 不执行
+	log  orm.Logger
+	ctx  context.Context // nil: context.Background()
 }
 
 // SQLIndex is index info of SQL-like database
 type SQLIndex struct {
-	Key    []string
-	Unique bool
-	Kind   string // field type
-	Method string // 索引方法
+	Key     []string
+	Unique  bool
+	Kind    string   // field type
+	Method  string   // 索引方法
+	Name    string   // 索引名, 为空时由表名及字段生成
+	Expr    bool     // Key为表达式
+	Where   string   // 部分索引的条件
+	Include []string // 覆盖索引的附加字段
 }
 
 // Copy 全拷贝
@@ -45,6 +52,7 @@ func (m *Model) Copy() (r *Model) {
 	r.ContigInsert = m.ContigInsert
 	r.ContigUpdate = m.ContigUpdate
 	r.AutoIncrementField = m.AutoIncrementField
+	r.ctx = m.ctx
 	if m.log != nil {
 		if _log, ok := m.log.(*log.Logger); ok {
 			r.log = _log.Copy()
@@ -59,6 +67,24 @@ func (m *Model) Copy() (r *Model) {
 }
 
 // table name
+// info 所属数据库登记的表附加信息, 未登记时为nil
+func (m *Model) info() *orm.ModelInfo {
+	return m.DatabaseSQL.models.Get(m.TableName)
+}
+
+// infoLoad 表未登记时从orm.ModelInfoTable读取附加信息, 如重启后未经Ensure的表
+func (m *Model) infoLoad() (err error) {
+	if m.DatabaseSQL.models.Get(m.TableName) != nil {
+		return
+	}
+	var info *orm.ModelInfo
+	if info, err = orm.ModelInfoLoad(m.DatabaseSQL, m.TableName); err != nil {
+		return
+	}
+	m.DatabaseSQL.models.Init(m.TableName, info)
+	return
+}
+
 func (m *Model) String() (s string) {
 	s = m.TableName
 	return
@@ -73,6 +99,7 @@ func (m *Model) Objects() orm.Objects {
 func (m *Model) ObjectsWith(arg *orm.ArgObjects) orm.Objects {
 	ob := new(Objects)
 	ob.Model = m
+	ob.ctx = m.ctx
 	ob.count = -1
 	ob.nums = -1
 	if m.log != nil {
@@ -105,7 +132,8 @@ func (m *Model) GetTable() (ret string) {
 
 // Drop table
 func (m *Model) Drop() (err error) {
-	m.Result, err = m.DatabaseSQL.DB.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, m.TableName))
+	m.Result, err = m.DatabaseSQL.DB.ExecContext(m.getContext(), fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, m.TableName))
+	err = orm.ContextErr(m.ctx, err)
 	return
 }
 
@@ -128,14 +156,20 @@ func (m *Model) ContigParse(st interface{}) (err error) {
 			c := `"` + f.Name + `"`
 			v := `:` + f.Name
 			p := c + ` = ` + v
+			if f.Version {
+				p = c + ` = COALESCE(` + c + `, 0)+1`
+			}
 			columns = append(columns, c)
 			values = append(values, v)
-			pairs = append(pairs, p)
+			if !f.Created {
+				pairs = append(pairs, p)
+			}
 		}
 	}
 	m.ContigInsert = fmt.Sprintf(`"%s" (%s) VALUES (%s)`, m.TableName, strings.Join(columns, ", "), strings.Join(values, ", "))
 	//m.ContigUpdate = fmt.Sprintf(`"%s" SET (%s) = (%s)`, m.TableName, strings.Join(columns, ", "), strings.Join(values, ", "))
 	m.ContigUpdate = fmt.Sprintf(`"%s" SET %s`, m.TableName, strings.Join(pairs, ", ")) // mssql update sql
+	m.DatabaseSQL.models.Register(m.TableName, fieldInfoLis)
 	return
 }
 
@@ -144,6 +178,7 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 	var (
 		fieldInfoLis []*orm.FieldInfo
 		colCmdLis    []string
+		commentLis   []string
 		primaryKey   []string
 		primaryCmd   = ""
 		tableExist   = 0
@@ -276,6 +311,13 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		} else {
 			cmdDef += ` NULL`
 		}
+		cmdDef = orm.ColumnDefine(f, cmdDef)
+		if m.plan != nil {
+			m.plan.ColumnExpect(f, strings.TrimPrefix(cmdAdd, fmt.Sprintf(`[%s] `, f.Name)), cmdDef)
+		}
+		if len(f.Check) > 0 {
+			cmdDef += fmt.Sprintf(` CHECK (%s)`, f.Check)
+		}
 		//
 		if tableExist == 1 {
 			// add
@@ -287,6 +329,12 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 			// create
 		}
 		colCmdLis = append(colCmdLis, cmdAdd+" "+cmdDef)
+		// comment: 只在新建字段时写入
+		if len(f.Comment) > 0 {
+			commentLis = append(commentLis, fmt.Sprintf(`EXEC sp_addextendedproperty @name = N'MS_Description', @value = N'%s',
+@level0type = N'SCHEMA', @level0name = N'dbo', @level1type = N'TABLE', @level1name = N'%s',
+@level2type = N'COLUMN', @level2name = N'%s';`, strings.Replace(f.Comment, "'", "''", -1), m.TableName, f.Name))
+		}
 	}
 
 	// run
@@ -295,7 +343,7 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		// alter不支持一次性进行多个字段的新增,所以要分逐个执行
 		for _, v := range colCmdLis {
 			_cmd := fmt.Sprintf("ALTER TABLE %s %s;\n", m.TableName, v)
-			if m.Result, err = m.DatabaseSQL.DB.Exec(_cmd); err != nil {
+			if err = m.ensureExec(_cmd); err != nil {
 				m.log.Errorf(`[ensure-column] %s err: %v`, _cmd, err)
 				return
 			}
@@ -308,13 +356,19 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		}
 		//
 		_cmd := fmt.Sprintf("CREATE TABLE \"%s\" (\n%s\n);", m.TableName, strings.Join(colCmdLis, ",\n"))
-		if m.Result, err = m.DatabaseSQL.DB.Exec(_cmd); err != nil {
+		if err = m.ensureExec(_cmd); err != nil {
 			m.log.Errorf(`[ensure-column] %s err: %v`, _cmd, err)
 			return
 		}
 		// log
 		m.log.Infof(`[ensure-column] %s`, _cmd)
 	}
+	for _, cmd := range commentLis {
+		if err = m.ensureExec(cmd); err != nil {
+			m.log.Errorf(`[ensure-column] %s err: %v`, cmd, err)
+			return
+		}
+	}
 	return
 }
 
@@ -329,6 +383,12 @@ func (m *Model) EnsurePrimary(key []string) (err error) {
 	for _, k := range key {
 		keys = append(keys, strings.ToLower(k))
 	}
+	// 预览: 新表的主键已在CREATE TABLE中
+	if m.plan != nil {
+		if m.plan.IndexExpect(pkey+"_"+strings.Join(keys, "_"), key, true) || !m.plan.TableExist {
+			return
+		}
+	}
 	if err = m.DatabaseSQL.DB.Get(&tableExist,
 		`SELECT COUNT(*) FROM information_schema.KEY_COLUMN_USAGE WHERE table_name=? AND CONSTRAINT_NAME=? `, m.TableName, pkey+"_"+strings.Join(keys, "_")); err != nil {
 		m.log.Errorf("[ensure-column] check table exist err: %v", err)
@@ -339,7 +399,7 @@ func (m *Model) EnsurePrimary(key []string) (err error) {
 		cmd = fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT [%s_%s] PRIMARY KEY CLUSTERED (%s)",
 			m.TableName, pkey, strings.Join(keys, "_"), strings.Join(keys, ","))
 		// run
-		if m.Result, err = m.DatabaseSQL.DB.Exec(cmd); err != nil {
+		if err = m.ensureExec(cmd); err != nil {
 			m.log.Errorf(`[ensure-primary] "%s" err: %v`, cmd, err)
 			return
 		}
@@ -354,6 +414,10 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 		indexLis       []*SQLIndex // index and unique
 		indexMethod    = ""        // both index and unique method
 		indexFiledType = ""        // field type
+		indexName      = ""        // 指定的索引名
+		indexWhere     = ""        // 部分索引的条件
+		indexInclude   []string    // 覆盖索引的附加字段
+		indexUnique    = false     // Key及Expr为唯一索引
 	)
 
 	// filed type
@@ -368,17 +432,52 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 		}
 	}
 
+	// name, unique, include, where
+	if _, ok := indexMap["Expr"]; ok {
+		// mssql需以计算列建立表达式索引
+		return orm.ErrIndexNotSupport
+	}
+	if s, ok := indexMap["Name"].(string); ok {
+		indexName = s
+	}
+	if v, ok := indexMap["Unique"].(bool); ok {
+		indexUnique = v
+	}
+	if v, ok := indexMap["Include"].([]string); ok {
+		indexInclude = v
+	}
+	switch w := indexMap["Where"].(type) {
+	case orm.M:
+		indexWhere, err = orm.IndexWhere(driverName, w)
+	case map[string]interface{}:
+		indexWhere, err = orm.IndexWhere(driverName, w)
+	}
+	if err != nil {
+		return
+	}
+
 	// keys
 	if i, ok := indexMap["Key"]; ok {
 		if v, ok := i.([]string); ok {
 			indexLis = append(indexLis, &SQLIndex{
 				Key:    v,
-				Unique: false,
+				Unique: indexUnique,
 				Kind:   indexFiledType,
 				Method: indexMethod,
 			})
 		}
 	}
+
+	// expression
+	if v, ok := indexMap["Expr"].([]string); ok {
+		indexLis = append(indexLis, &SQLIndex{
+			Key:    v,
+			Unique: indexUnique,
+			Kind:   indexFiledType,
+			Method: indexMethod,
+			Expr:   true,
+		})
+	}
 	// ignore index type
 	switch indexFiledType {
 	case "bytearray", "json", "text":
@@ -395,6 +494,12 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 			})
 		}
 	}
+	for _, index := range indexLis {
+		index.Where, index.Include = indexWhere, indexInclude
+	}
+	if len(indexName) > 0 && len(indexLis) == 1 {
+		indexLis[0].Name = indexName
+	}
 
 	//
 	for _, index := range indexLis {
@@ -406,15 +511,37 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 			}
 			var keys []string
 			for _, k := range index.Key {
+				if index.Expr {
+					keys = append(keys, "("+k+")")
+					continue
+				}
+				k, desc := orm.IndexKey(k)
 				k = strings.ToLower(k)
-				keys = append(keys, fmt.Sprintf(`[%s]`, k))
+				if desc {
+					keys = append(keys, fmt.Sprintf(`[%s]`, k)+" DESC")
+				} else {
+					keys = append(keys, fmt.Sprintf(`[%s]`, k))
+				}
 			}
 			//if len(index.Method) == 0 {
 			//	index.Method = "USING btree" // 默认 btree
 			//}
-			indexKey := fmt.Sprintf(`%s_%s`, m.TableName, strings.Join(index.Key, "_"))
-			indexCmd := fmt.Sprintf(`CREATE %s [%s] ON [%s] %s (%s);`, indexType,
+			indexKey := index.Name
+			if len(indexKey) == 0 {
+				indexKey = orm.IndexName(m.TableName, index.Key)
+			}
+			indexCmd := fmt.Sprintf(`CREATE %s [%s] ON [%s] %s (%s)`, indexType,
 				indexKey, m.TableName, index.Method, strings.Join(keys, ", "))
+			if len(index.Include) > 0 {
+				indexCmd += fmt.Sprintf(` INCLUDE ([%s])`, strings.Join(index.Include, `], [`))
+			}
+			if len(index.Where) > 0 {
+				indexCmd += " WHERE " + index.Where
+			}
+			indexCmd += ";"
+			if m.plan != nil && m.plan.IndexExpect(indexKey, index.Key, index.Unique) {
+				continue
+			}
 
 			// https://stackoverflow.com/questions/2689766/how-do-you-check-if-a-certain-index-exists-in-a-table
 			checkCmd := fmt.Sprintf(`select count(*) from sys.indexes where name = '%s' and object_id = OBJECT_ID('%s');`,
@@ -425,9 +552,9 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 				return
 			} else if exist > 0 {
 				// index exist
-				return
+				continue
 			}
-			if m.Result, err = m.DatabaseSQL.DB.Exec(indexCmd); err != nil {
+			if err = m.ensureExec(indexCmd); err != nil {
 				m.log.Errorf(`[ensure-index] %s, type: %s, err: %v`, indexCmd, indexType, err)
 				return
 
@@ -439,8 +566,90 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 	return
 }
 
+// EnsureForeignKey 确认外键; mssql不支持RESTRICT, 以NO ACTION代替
+func (m *Model) EnsureForeignKey(f *orm.FieldInfo) (err error) {
+	var (
+		fkey  = fmt.Sprintf(`%s_%s_fkey`, m.TableName, f.Name)
+		cmd   string
+		exist = 0
+	)
+	cmd = fmt.Sprintf("ALTER TABLE %s ADD CONSTRAINT [%s] FOREIGN KEY ([%s]) REFERENCES %s ([%s])%s",
+		m.TableName, fkey, f.Name, f.Fk.Table, f.Fk.Column, strings.Replace(f.Fk.Action(), "RESTRICT", "NO ACTION", -1))
+	if m.plan != nil && m.plan.FkExpect(fkey, f) {
+		return
+	}
+	if err = m.DatabaseSQL.DB.Get(&exist, `SELECT COUNT(*) FROM sys.foreign_keys WHERE name = ?`, fkey); err != nil {
+		m.log.Errorf("[ensure-fk] check fk exist err: %v", err)
+		return
+	} else if exist > 0 {
+		return
+	}
+
+	// run
+	if err = m.ensureExec(cmd); err != nil {
+		m.log.Errorf(`[ensure-fk] "%s" err: %v`, cmd, err)
+		return
+	}
+	return
+}
+
+// EnsureTextIndex 确认全文索引: mssql每表只有一个全文索引, 以主键为KEY INDEX, 已有时追加字段
+func (m *Model) EnsureTextIndex(f *orm.FieldInfo) (err error) {
+	var (
+		indexKey = fmt.Sprintf(`%s_%s_text`, m.TableName, f.Name)
+		pkey     = fmt.Sprintf(`%s_pkey_%s`, m.TableName, strings.ToLower(strings.Join(f.PrimaryKeys, "_")))
+		cmdLis   []string
+		exist    = 0
+	)
+	if m.plan != nil && m.plan.IndexExpect(indexKey, []string{f.Name}, false) {
+		return
+	}
+	if err = m.DatabaseSQL.DB.Get(&exist, `SELECT COUNT(*) FROM sys.fulltext_index_columns c
+JOIN sys.columns col ON col.object_id = c.object_id AND col.column_id = c.column_id
+WHERE c.object_id = OBJECT_ID(?) AND col.name = ?`, m.TableName, f.Name); err != nil {
+		m.log.Errorf("[ensure-text] check index exist err: %v", err)
+		return
+	} else if exist > 0 {
+		return
+	}
+	if err = m.DatabaseSQL.DB.Get(&exist,
+		`SELECT COUNT(*) FROM sys.fulltext_indexes WHERE object_id = OBJECT_ID(?)`, m.TableName); err != nil {
+		m.log.Errorf("[ensure-text] check index exist err: %v", err)
+		return
+	} else if exist > 0 {
+		cmdLis = append(cmdLis, fmt.Sprintf("ALTER FULLTEXT INDEX ON %s ADD ([%s])", m.TableName, f.Name))
+	} else {
+		cmdLis = append(cmdLis,
+			"IF NOT EXISTS (SELECT 1 FROM sys.fulltext_catalogs WHERE name = 'sorm_fulltext') CREATE FULLTEXT CATALOG sorm_fulltext",
+			fmt.Sprintf("CREATE FULLTEXT INDEX ON %s ([%s]) KEY INDEX [%s] ON sorm_fulltext", m.TableName, f.Name, pkey))
+	}
+
+	// run
+	for _, cmd := range cmdLis {
+		if err = m.ensureExec(cmd); err != nil {
+			m.log.Errorf(`[ensure-text] "%s" err: %v`, cmd, err)
+			return
+		}
+	}
+	return
+}
+
 // Ensure is sync table with struct
 func (m *Model) Ensure(st interface{}) (err error) {
+	if err = m.ensure(st); err != nil {
+		return
+	}
+	// contig parse and cache
+	m.ContigInsert = ""
+	m.ContigUpdate = ""
+	if err = m.ContigParse(st); err != nil {
+		return
+	}
+	return orm.ModelInfoSave(m.DatabaseSQL, m.TableName, m.info())
+}
+
+// ensure 同步字段及索引
+func (m *Model) ensure(st interface{}) (err error) {
 	// syncDB
 	if err = m.EnsureColumn(st); err != nil {
 		return
@@ -478,28 +687,303 @@ func (m *Model) Ensure(st interface{}) (err error) {
 			return
 		}
 	}
-	// contig parse and cache
-	m.ContigInsert = ""
-	m.ContigUpdate = ""
-	if err = m.ContigParse(st); err != nil {
+	// text index
+	for _, f := range fieldInfoLis {
+		if !f.IndexText {
+			continue
+		}
+		if err = m.EnsureTextIndex(f); err != nil {
+			return
+		}
+	}
+	// foreign key
+	for _, f := range fieldInfoLis {
+		if f.Fk == nil {
+			continue
+		}
+		if err = m.EnsureForeignKey(f); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ensureExec 执行Ensure的语句, 预览时只记录
+func (m *Model) ensureExec(cmd string) (err error) {
+	if m.plan != nil {
+		m.plan.DDL = append(m.plan.DDL, cmd)
+		return
+	}
+	m.Result, err = m.DatabaseSQL.DB.Exec(cmd)
+	return
+}
+
+// Plan 对比struct与库中的表结构, 返回Ensure将执行的语句, 不执行
+func (m *Model) Plan(st interface{}) (ret *orm.Plan, err error) {
+	var (
+		mp   = m.Copy()
+		info *orm.TableInfo
+	)
+	ret = orm.NewPlan(m.TableName)
+	if info, err = m.Inspect(); err == orm.ErrSyncTableNotExist {
+		err = nil
+	} else if err != nil {
+		return
+	} else {
+		ret.TableExist = true
+		ret.TableActual(info)
+	}
+	// 按Ensure的流程记录语句
+	mp.plan = ret
+	if err = mp.ensure(st); err != nil {
+		return
+	}
+	ret.Diff()
+	return
+}
+
+// Inspect 读取库中表的字段及索引
+func (m *Model) Inspect() (ret *orm.TableInfo, err error) {
+	var (
+		tableExist = 0
+		primary    = make(map[string]bool)
+	)
+	if err = m.DatabaseSQL.DB.Get(&tableExist,
+		`SELECT count(*) FROM information_schema.tables WHERE table_name=$1`, m.TableName); err != nil {
+		m.log.Errorf("[inspect] check table exist err: %v", err)
 		return
+	} else if tableExist == 0 {
+		err = orm.ErrSyncTableNotExist
+		return
+	}
+	ret = &orm.TableInfo{Name: m.TableName}
+	// indexes
+	var indexLis []struct {
+		Name    string `db:"name"`
+		Unique  bool   `db:"is_unique"`
+		Primary bool   `db:"is_primary_key"`
+		Column  string `db:"column_name"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&indexLis, `SELECT i.name, i.is_unique, i.is_primary_key, c.name AS column_name
+FROM sys.indexes i
+JOIN sys.index_columns ic ON ic.object_id = i.object_id AND ic.index_id = i.index_id
+JOIN sys.columns c ON c.object_id = ic.object_id AND c.column_id = ic.column_id
+WHERE i.object_id = OBJECT_ID($1) AND i.name IS NOT NULL AND ic.is_included_column = 0
+ORDER BY i.name, ic.key_ordinal`,
+		m.TableName); err != nil {
+		m.log.Errorf("[inspect] select index err: %v", err)
+		return
+	}
+	for i := 0; i < len(indexLis); {
+		idx := &orm.IndexInfo{Name: indexLis[i].Name, Unique: indexLis[i].Unique, Primary: indexLis[i].Primary}
+		for ; i < len(indexLis) && indexLis[i].Name == idx.Name; i++ {
+			idx.Key = append(idx.Key, indexLis[i].Column)
+			if idx.Primary {
+				primary[indexLis[i].Column] = true
+			}
+		}
+		ret.Indexes = append(ret.Indexes, idx)
+	}
+	// columns
+	var columnLis []struct {
+		Name      string         `db:"name"`
+		Kind      string         `db:"kind"`
+		Size      int            `db:"max_length"`
+		Precision int            `db:"precision"`
+		Scale     int            `db:"scale"`
+		Null      bool           `db:"is_nullable"`
+		Identity  bool           `db:"is_identity"`
+		Default   sql.NullString `db:"definition"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&columnLis, `SELECT c.name, t.name AS kind, c.max_length, c.precision,
+c.scale, c.is_nullable, c.is_identity, d.definition FROM sys.columns c
+JOIN sys.types t ON t.user_type_id = c.user_type_id
+LEFT JOIN sys.default_constraints d ON d.object_id = c.default_object_id
+WHERE c.object_id = OBJECT_ID($1) ORDER BY c.column_id`, m.TableName); err != nil {
+		m.log.Errorf("[inspect] select column err: %v", err)
+		return
+	}
+	for _, c := range columnLis {
+		kind := c.Kind
+		switch kind {
+		case "varchar", "char", "varbinary", "binary":
+			if c.Size < 0 {
+				kind += "(max)"
+			} else {
+				kind = fmt.Sprintf("%s(%d)", kind, c.Size)
+			}
+		case "decimal", "numeric":
+			kind = fmt.Sprintf("%s(%d,%d)", kind, c.Precision, c.Scale)
+		}
+		ret.Columns = append(ret.Columns, &orm.ColumnInfo{
+			Name:    c.Name,
+			Kind:    kind,
+			Null:    c.Null,
+			Default: c.Default.String,
+			Primary: primary[c.Name],
+			Serial:  c.Identity,
+		})
+	}
+	// 全文索引: 每表一个, 按字段拆分并按EnsureTextIndex的规则命名
+	var textLis []string
+	if err = m.DatabaseSQL.DB.Select(&textLis, `SELECT col.name FROM sys.fulltext_index_columns c
+JOIN sys.columns col ON col.object_id = c.object_id AND col.column_id = c.column_id
+WHERE c.object_id = OBJECT_ID($1) ORDER BY col.name`, m.TableName); err != nil {
+		m.log.Errorf("[inspect] select fulltext index err: %v", err)
+		return
+	}
+	for _, col := range textLis {
+		ret.Indexes = append(ret.Indexes, &orm.IndexInfo{Name: fmt.Sprintf(`%s_%s_text`, m.TableName, col),
+			Key: []string{col}, Text: true})
+	}
+	// foreign keys
+	var fkLis []struct {
+		Name      string `db:"name"`
+		Column    string `db:"column_name"`
+		RefTable  string `db:"ref_table"`
+		RefColumn string `db:"ref_column"`
+		OnDelete  string `db:"delete_rule"`
+		OnUpdate  string `db:"update_rule"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&fkLis, `SELECT fk.name, c.name AS column_name, rt.name AS ref_table, rc.name AS ref_column,
+fk.delete_referential_action_desc AS delete_rule, fk.update_referential_action_desc AS update_rule
+FROM sys.foreign_keys fk
+JOIN sys.foreign_key_columns fc ON fc.constraint_object_id = fk.object_id
+JOIN sys.columns c ON c.object_id = fc.parent_object_id AND c.column_id = fc.parent_column_id
+JOIN sys.tables rt ON rt.object_id = fc.referenced_object_id
+JOIN sys.columns rc ON rc.object_id = fc.referenced_object_id AND rc.column_id = fc.referenced_column_id
+WHERE fk.parent_object_id = OBJECT_ID($1) ORDER BY fk.name`,
+		m.TableName); err != nil {
+		m.log.Errorf("[inspect] select fk err: %v", err)
+		return
+	}
+	for _, fk := range fkLis {
+		ret.Fks = append(ret.Fks, &orm.FkInfo{Name: fk.Name, Column: fk.Column, RefTable: fk.RefTable,
+			RefColumn: fk.RefColumn, OnDelete: strings.Replace(fk.OnDelete, "_", " ", -1), OnUpdate: strings.Replace(fk.OnUpdate, "_", " ", -1)})
 	}
 	return
 }
 
+// EnsureWith 同Ensure, 并按参数修改/删除/重命名已有的字段及索引
+func (m *Model) EnsureWith(st interface{}, opt *orm.ArgEnsure) (err error) {
+	var (
+		p    *orm.Plan
+		pkey = fmt.Sprintf(`%s_pkey`, m.TableName)
+	)
+	if opt == nil {
+		return m.Ensure(st)
+	}
+	if p, err = m.Plan(st); err != nil {
+		return
+	}
+	// rename
+	if opt.Rename && len(p.ColumnRenamed) > 0 {
+		for _, c := range p.ColumnRenamed {
+			if err = m.ensureWithExec(fmt.Sprintf(`EXEC sp_rename '%s.%s', '%s', 'COLUMN';`,
+				m.TableName, c.Was, c.Name)); err != nil {
+				return
+			}
+		}
+		if p, err = m.Plan(st); err != nil {
+			return
+		}
+	}
+	// drop
+	if opt.DropIndex {
+		for _, idx := range p.IndexExtra {
+			if strings.HasPrefix(idx.Name, pkey) {
+				continue // 主键约束
+			}
+			if err = m.ensureWithExec(fmt.Sprintf(`DROP INDEX [%s] ON [%s];`,
+				idx.Name, m.TableName)); err != nil {
+				return
+			}
+		}
+	}
+	if opt.DropColumn {
+		for _, c := range p.ColumnExtra {
+			if err = m.ensureWithExec(fmt.Sprintf("%s\nALTER TABLE [%s] DROP COLUMN [%s];",
+				m.dropDefault(c.Name), m.TableName, c.Name)); err != nil {
+				return
+			}
+		}
+	}
+	// alter: 默认值为约束, 先删除再重建
+	if opt.AlterColumn {
+		for _, c := range p.ColumnChanged {
+			if strings.Contains(c.Kind, "IDENTITY") {
+				continue
+			}
+			null := "NULL"
+			if !c.Null {
+				null = "NOT NULL"
+			}
+			cmd := fmt.Sprintf("%s\nALTER TABLE [%s] ALTER COLUMN [%s] %s %s;",
+				m.dropDefault(c.Name), m.TableName, c.Name, c.Kind, null)
+			if len(c.DefaultSQL) > 0 {
+				cmd += fmt.Sprintf("\nALTER TABLE [%s] ADD DEFAULT %s FOR [%s];", m.TableName, c.DefaultSQL, c.Name)
+			}
+			if err = m.ensureWithExec(cmd); err != nil {
+				return
+			}
+		}
+	}
+	return m.Ensure(st)
+}
+
+// dropDefault 删除字段默认值约束的语句, 修改或删除字段前需先删除
+func (m *Model) dropDefault(col string) string {
+	return fmt.Sprintf(`DECLARE @df sysname;
+SELECT @df = d.name FROM sys.default_constraints d
+JOIN sys.columns c ON c.object_id = d.parent_object_id AND c.column_id = d.parent_column_id
+WHERE d.parent_object_id = OBJECT_ID('%s') AND c.name = '%s';
+IF @df IS NOT NULL EXEC('ALTER TABLE [%s] DROP CONSTRAINT [' + @df + ']');`, m.TableName, col, m.TableName)
+}
+
+// ensureWithExec 执行EnsureWith的语句
+func (m *Model) ensureWithExec(cmd string) (err error) {
+	if err = m.ensureExec(cmd); err != nil {
+		m.log.Errorf(`[ensure-with] %s err: %v`, cmd, err)
+		return
+	}
+	m.log.Infof(`[ensure-with] %s`, cmd)
+	return
+}
+
 // Begin 事务起始
 func (m *Model) Begin() (orm.Trans, error) {
 	return m.BeginWith(nil)
 }
 
+// BeginNested 在parent中以保存点开启子事务
+func (m *Model) BeginNested(parent orm.Trans) (ret orm.Trans, err error) {
+	if parent == nil {
+		return m.BeginWith(nil)
+	}
+	t, ok := parent.(*Trans)
+	if !ok || t == nil || t.db != m.DatabaseSQL {
+		return nil, orm.ErrTransInvalid
+	}
+	var c *Trans
+	if c, err = t.nested(); err != nil {
+		return
+	}
+	return c, nil
+}
+
 // BeginWith 事务
 func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 	var (
-		t = &Trans{}
+		t = &Trans{db: m.DatabaseSQL}
 	)
-	if t.Tx, err = m.DatabaseSQL.DB.Beginx(); err != nil {
-		return t, err
+	if arg != nil && (arg.Deferrable || len(arg.Lock) > 0) {
+		// 驱动不支持的参数, 不静默忽略
+		return t, orm.ErrTransOptionNotSupport
 	}
+	if t.Tx, err = m.DatabaseSQL.DB.BeginTxx(m.getContext(), nil); err != nil {
+		return t, orm.ContextErr(m.ctx, err)
+	}
+	t.ctx = m.ctx
 
 	// 记录调用处
 	if pc, file, line, ok := runtime.Caller(2); ok {
@@ -551,7 +1035,11 @@ func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 	}
 
 	// 超时回滚
-	t.timer = time.NewTimer(orm.TransTimeout)
+	timeout := orm.TransTimeout
+	if arg.Timeout > 0 {
+		timeout = arg.Timeout
+	}
+	t.timer = time.NewTimer(timeout)
 	go func() {
 		defer func() {
 			if _err := recover(); _err != nil {
@@ -561,10 +1049,12 @@ func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 
 		<-t.timer.C
 		// timeout
-		if !t.isFinish {
+		if t.finish(transExpired) {
+			// 只有CAS成功的一方写入错误, 之后Error/Commit均返回超时
+			t.errSet(orm.ErrTransTimeout)
 			// log sql history
 			m.log.Errorf("[trans-timeout] tableName=%s sql=%s", m.TableName, t.debugReport())
-			_ = m.Rollback(t)
+			_ = t.rollback(orm.ErrTransTimeout)
 		}
 	}()
 
@@ -605,16 +1095,34 @@ func (m *Model) AutoTrans(t orm.Trans) (err error) {
 
 // Exec 执行语句
 func (m *Model) Exec(query string, args ...interface{}) (result orm.Result, err error) {
-	result, err = m.DatabaseSQL.DB.Exec(query, args...)
+	result, err = m.DatabaseSQL.DB.ExecContext(m.getContext(), query, args...)
+	err = orm.ContextErr(m.ctx, err)
 	return
 }
 
 // Exec 执行语句
 func (m *Model) Select(dest interface{}, query string, args ...interface{}) (err error) {
-	err = m.DatabaseSQL.DB.Select(dest, query, args...)
+	err = m.DatabaseSQL.DB.SelectContext(m.getContext(), dest, query, args...)
+	err = orm.ContextErr(m.ctx, err)
 	return
 }
 
+// WithContext 返回绑定上下文的model
+func (m *Model) WithContext(ctx context.Context) (ret orm.Model) {
+	r := m.Copy()
+	r.VirtualSQL = m.VirtualSQL
+	r.ctx = ctx
+	return r
+}
+
+// getContext 取上下文
+func (m *Model) getContext() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
 // With 设置日志级别
 func (m *Model) With(arg *orm.ArgModel) (ret orm.Model) {
 	r := m.Copy()
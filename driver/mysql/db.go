@@ -20,6 +20,8 @@ var (
 	MaxOpenConns = 50
 	// CfgDbUnsafe false:数据库严格映射到结构体
 	CfgDbUnsafe = false // true: sqlx.Unsafe 防止报错 https://github.com/jmoiron/sqlx/blob/master/sqlx.go#L601
+	// CfgBatchArgsMax 批量插入时单条语句的占位符上限
+	CfgBatchArgsMax = 65535
 	// 针对数据库
 	driverName = orm.DriverNameMysql //
 )
@@ -49,6 +51,7 @@ type DatabaseSQL struct {
 	DB      *sqlx.DB
 	Unsafe  bool
 	log     orm.Logger
+	models  orm.ModelInfoMap // 表名->附加信息
 	lock    sync.RWMutex
 	version string
 }
@@ -210,9 +213,26 @@ func (db *DatabaseSQL) ModelWith(s string, arg *orm.ArgModel) orm.Model {
 			}
 		}
 	}
+	if arg != nil && arg.Struct != nil {
+		if lis, _err := orm.StructModelInfo(arg.Struct); _err != nil {
+			m.log.Errorf("model %s struct: %v", s, _err)
+		} else {
+			db.models.Register(s, lis)
+		}
+	}
 	return m
 }
 
+// Begin 开启事务, 可用于本库的任意model
+func (db *DatabaseSQL) Begin() (orm.Trans, error) {
+	return db.Model("").BeginWith(nil)
+}
+
+// BeginWith 指定参数开启事务, 可用于本库的任意model
+func (db *DatabaseSQL) BeginWith(arg *orm.ArgTrans) (orm.Trans, error) {
+	return db.Model("").BeginWith(arg)
+}
+
 // NewDb 新键连接
 func NewDb(arg string) (ret orm.Database, err error) {
 	var (
@@ -247,6 +267,8 @@ func NewDb(arg string) (ret orm.Database, err error) {
 // register
 func init() {
 	orm.RegisterDriver(driverName, NewDb)
+	orm.HookTransRetryable[driverName] = retryable
+	orm.HookDuplicateKey[driverName] = duplicateKey
 	// 用songo作为解析驱动
 	orm.HookParseSafe = songo.ParseSafe             //
 	orm.HookParseSQL[driverName] = songo.ParseMysql //
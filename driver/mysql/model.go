@@ -4,6 +4,7 @@ import (
 	"github.com/suboat/sorm"
 	"github.com/suboat/sorm/log"
 
+	"context"
 	"database/sql"
 	"fmt"
 	"runtime"
@@ -25,15 +26,21 @@ type Model struct {
 	ContigUpdate       string
 	AutoIncrementField string
 	//
-	log orm.Logger
+	plan *orm.Plan // 非nil时Ensure只记录语句, 不执行
+	log  orm.Logger
+	ctx  context.Context // nil: context.Background()
 }
 
 // SQLIndex is index info of SQL-like database
 type SQLIndex struct {
-	Key    []string
-	Unique bool
-	Kind   string // field type
-	Method string // 索引方法
+	Key     []string
+	Unique  bool
+	Kind    string   // field type
+	Method  string   // 索引方法
+	Name    string   // 索引名, 为空时由表名及字段生成
+	Expr    bool     // Key为表达式
+	Where   string   // 部分索引的条件
+	Include []string // 覆盖索引的附加字段
 }
 
 // Copy 全拷贝
@@ -44,6 +51,7 @@ func (m *Model) Copy() (r *Model) {
 	r.ContigInsert = m.ContigInsert
 	r.ContigUpdate = m.ContigUpdate
 	r.AutoIncrementField = m.AutoIncrementField
+	r.ctx = m.ctx
 	if m.log != nil {
 		if _log, ok := m.log.(*log.Logger); ok {
 			r.log = _log.Copy()
@@ -58,6 +66,24 @@ func (m *Model) Copy() (r *Model) {
 }
 
 // table name
+// info 所属数据库登记的表附加信息, 未登记时为nil
+func (m *Model) info() *orm.ModelInfo {
+	return m.DatabaseSQL.models.Get(m.TableName)
+}
+
+// infoLoad 表未登记时从orm.ModelInfoTable读取附加信息, 如重启后未经Ensure的表
+func (m *Model) infoLoad() (err error) {
+	if m.DatabaseSQL.models.Get(m.TableName) != nil {
+		return
+	}
+	var info *orm.ModelInfo
+	if info, err = orm.ModelInfoLoad(m.DatabaseSQL, m.TableName); err != nil {
+		return
+	}
+	m.DatabaseSQL.models.Init(m.TableName, info)
+	return
+}
+
 func (m *Model) String() (s string) {
 	s = m.TableName
 	return
@@ -72,6 +98,7 @@ func (m *Model) Objects() orm.Objects {
 func (m *Model) ObjectsWith(arg *orm.ArgObjects) orm.Objects {
 	ob := new(Objects)
 	ob.Model = m
+	ob.ctx = m.ctx
 	ob.count = -1
 	ob.nums = -1
 	if m.log != nil {
@@ -104,7 +131,8 @@ func (m *Model) GetTable() (ret string) {
 
 // Drop table
 func (m *Model) Drop() (err error) {
-	m.Result, err = m.DatabaseSQL.DB.Exec(fmt.Sprintf("DROP TABLE IF EXISTS `%s`", m.TableName))
+	m.Result, err = m.DatabaseSQL.DB.ExecContext(m.getContext(), fmt.Sprintf("DROP TABLE IF EXISTS `%s`", m.TableName))
+	err = orm.ContextErr(m.ctx, err)
 	return
 }
 
@@ -126,11 +154,18 @@ func (m *Model) ContigParse(st interface{}) (err error) {
 		if f.Name != m.AutoIncrementField {
 			columns = append(columns, "`"+f.Name+"`")
 			values = append(values, ":"+f.Name)
-			colVals = append(colVals, f.Name+"=:"+f.Name)
+			if !f.Created {
+				if f.Version {
+					colVals = append(colVals, f.Name+"=COALESCE("+f.Name+", 0)+1")
+				} else {
+					colVals = append(colVals, f.Name+"=:"+f.Name)
+				}
+			}
 		}
 	}
 	m.ContigInsert = fmt.Sprintf("`%s` (%s) VALUES (%s)", m.TableName, strings.Join(columns, ", "), strings.Join(values, ", "))
 	m.ContigUpdate = fmt.Sprintf("`%s` SET %s", m.TableName, strings.Join(colVals, ", "))
+	m.DatabaseSQL.models.Register(m.TableName, fieldInfoLis)
 	return
 }
 
@@ -207,6 +242,9 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 
 			// mysql covert, auto column and it must be defined as a key
 			autoIncKey = fmt.Sprintf("KEY `%s` (`%s`)", f.Name, f.Name)
+			if m.plan != nil {
+				m.plan.IndexExpect(f.Name, []string{f.Name}, false)
+			}
 
 		default:
 		}
@@ -296,6 +334,17 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		if f.DefaultVal == nil {
 			cmdDef = `NULL`
 		}
+		cmdDef = orm.ColumnDefine(f, cmdDef)
+		if m.plan != nil {
+			m.plan.ColumnExpect(f, strings.TrimPrefix(cmdAdd, fmt.Sprintf("`%s` ", f.Name)), cmdDef)
+		}
+		if len(f.Check) > 0 {
+			// mysql8.0.16起生效
+			cmdDef += fmt.Sprintf(` CHECK (%s)`, f.Check)
+		}
+		if len(f.Comment) > 0 {
+			cmdDef += fmt.Sprintf(` COMMENT '%s'`, strings.Replace(f.Comment, "'", "''", -1))
+		}
 		//
 		if tableExist == 1 {
 			// add
@@ -316,7 +365,7 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		// 因为tidb的alter不支持一次性进行多个字段的新增,所以要分逐个执行
 		for _, v := range colCmdLis {
 			_cmd := fmt.Sprintf("ALTER TABLE `%s` %s;\n", m.TableName, v)
-			if m.Result, err = m.DatabaseSQL.DB.Exec(_cmd); err != nil {
+			if err = m.ensureExec(_cmd); err != nil {
 				m.log.Errorf(`[ensure-column] %s err: %v`, _cmd, err)
 				return
 			}
@@ -333,7 +382,7 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		}
 		//
 		_cmd := fmt.Sprintf("CREATE TABLE `%s` (\n%s\n);", m.TableName, strings.Join(colCmdLis, ",\n"))
-		if m.Result, err = m.DatabaseSQL.DB.Exec(_cmd); err != nil {
+		if err = m.ensureExec(_cmd); err != nil {
 			m.log.Errorf(`[ensure-column] %s err: %v`, _cmd, err)
 			return
 		}
@@ -353,6 +402,12 @@ func (m *Model) EnsurePrimary(key []string) (err error) {
 	for _, k := range key {
 		keys = append(keys, strings.ToLower(k))
 	}
+	// 预览: 新表的主键已在CREATE TABLE中
+	if m.plan != nil {
+		if m.plan.IndexExpect("PRIMARY", key, true) || !m.plan.TableExist {
+			return
+		}
+	}
 	if err = m.DatabaseSQL.DB.Get(&tableExist,
 		`SELECT COUNT(*) FROM information_schema.KEY_COLUMN_USAGE WHERE table_name=? AND CONSTRAINT_NAME='PRIMARY' `, m.TableName); err != nil {
 		m.log.Errorf("[ensure-column] check table exist err: %v", err)
@@ -361,7 +416,7 @@ func (m *Model) EnsurePrimary(key []string) (err error) {
 	if tableExist == 0 {
 		cmd = fmt.Sprintf("ALTER TABLE `%s` ADD PRIMARY KEY (`%s`)", m.TableName, strings.Join(keys, ","))
 		// run
-		if m.Result, err = m.DatabaseSQL.DB.Exec(cmd); err != nil {
+		if err = m.ensureExec(cmd); err != nil {
 			m.log.Errorf(`[ensure-primary] "%s" err: %v`, cmd, err)
 			return
 		}
@@ -386,6 +441,10 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 		indexLis       []*SQLIndex // index and unique
 		indexMethod    = ""        // both index and unique method
 		indexFiledType = ""        // field type
+		indexName      = ""        // 指定的索引名
+		indexWhere     = ""        // 部分索引的条件
+		indexInclude   []string    // 覆盖索引的附加字段
+		indexUnique    = false     // Key及Expr为唯一索引
 	)
 
 	// filed type
@@ -400,18 +459,53 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 		}
 	}
 
+	// name, unique, include, where
+	if _, ok := indexMap["Where"]; ok {
+		// mysql不支持部分索引
+		return orm.ErrIndexNotSupport
+	}
+	if s, ok := indexMap["Name"].(string); ok {
+		indexName = s
+	}
+	if v, ok := indexMap["Unique"].(bool); ok {
+		indexUnique = v
+	}
+	if v, ok := indexMap["Include"].([]string); ok {
+		indexInclude = v
+	}
+	switch w := indexMap["Where"].(type) {
+	case orm.M:
+		indexWhere, err = orm.IndexWhere(driverName, w)
+	case map[string]interface{}:
+		indexWhere, err = orm.IndexWhere(driverName, w)
+	}
+	if err != nil {
+		return
+	}
+
 	// keys
 	if i, ok := indexMap["Key"]; ok {
 		if v, ok := i.([]string); ok {
 			indexLis = append(indexLis, &SQLIndex{
 				Key:    v,
-				Unique: false,
+				Unique: indexUnique,
 				Kind:   indexFiledType,
 				Method: indexMethod,
 			})
 		}
 	}
 
+	// expression
+	if v, ok := indexMap["Expr"].([]string); ok {
+		indexLis = append(indexLis, &SQLIndex{
+			Key:    v,
+			Unique: indexUnique,
+			Kind:   indexFiledType,
+			Method: indexMethod,
+			Expr:   true,
+		})
+	}
+
 	// ignore index type
 	switch indexFiledType {
 	case "bytearray", "json":
@@ -429,6 +523,12 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 			})
 		}
 	}
+	for _, index := range indexLis {
+		index.Where, index.Include = indexWhere, indexInclude
+	}
+	if len(indexName) > 0 && len(indexLis) == 1 {
+		indexLis[0].Name = indexName
+	}
 
 	//
 	for _, index := range indexLis {
@@ -439,10 +539,18 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 				indexType = "UNIQUE INDEX"
 			}
 			var keys []string
-
 			for _, k := range index.Key {
+				if index.Expr {
+					keys = append(keys, "("+k+")")
+					continue
+				}
+				k, desc := orm.IndexKey(k)
 				k = strings.ToLower(k)
-				keys = append(keys, "`"+k+"`")
+				if desc {
+					keys = append(keys, "`"+k+"`"+" DESC")
+				} else {
+					keys = append(keys, "`"+k+"`")
+				}
 			}
 			//if len(index.Method) == 0 {
 			//	index.Method = "USING btree" // 默认 btree
@@ -450,8 +558,14 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 
 			//indexCmd := fmt.Sprintf("CREATE %s IF NOT EXISTS %s_%s ON %s %s (%s);", indexType,
 			//	m.TableName, strings.Join(index.Key, "_"), m.TableName, index.Method, strings.Join(keys, ", "))
-			indexKey := fmt.Sprintf(`%s_%s`, m.TableName, strings.Join(index.Key, "_"))
+			indexKey := index.Name
+			if len(indexKey) == 0 {
+				indexKey = orm.IndexName(m.TableName, index.Key)
+			}
 			indexCmd := fmt.Sprintf("ALTER TABLE `%s` ADD %s %s (%s)", m.TableName, indexType, indexKey, strings.Join(keys, ", "))
+			if m.plan != nil && m.plan.IndexExpect(indexKey, index.Key, index.Unique) {
+				continue
+			}
 
 			// https://stackoverflow.com/questions/30259196/add-index-to-table-if-it-does-not-exist
 			checkCmd := fmt.Sprintf(`select count(*) from information_schema.statistics where table_name = '%s' and index_name = '%s' and table_schema = database();`, m.TableName, indexKey)
@@ -461,11 +575,11 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 				return
 			} else if exist > 0 {
 				// index exist
-				return
+				continue
 			}
 
 			//
-			if m.Result, err = m.DatabaseSQL.DB.Exec(indexCmd); err != nil {
+			if err = m.ensureExec(indexCmd); err != nil {
 				m.log.Errorf(`[ensure-index] %s, type: %s, err: %v`, indexCmd, indexType, err)
 				return
 			}
@@ -476,8 +590,78 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 	return
 }
 
+// EnsureForeignKey 确认外键
+func (m *Model) EnsureForeignKey(f *orm.FieldInfo) (err error) {
+	var (
+		fkey  = fmt.Sprintf(`%s_%s_fkey`, m.TableName, f.Name)
+		cmd   string
+		exist = 0
+	)
+	cmd = fmt.Sprintf("ALTER TABLE `%s` ADD CONSTRAINT `%s` FOREIGN KEY (`%s`) REFERENCES `%s` (`%s`)%s",
+		m.TableName, fkey, f.Name, f.Fk.Table, f.Fk.Column, f.Fk.Action())
+	if m.plan != nil && m.plan.FkExpect(fkey, f) {
+		return
+	}
+	if err = m.DatabaseSQL.DB.Get(&exist, `SELECT COUNT(*) FROM information_schema.TABLE_CONSTRAINTS
+WHERE TABLE_SCHEMA = database() AND TABLE_NAME = ? AND CONSTRAINT_NAME = ? AND CONSTRAINT_TYPE = 'FOREIGN KEY'`,
+		m.TableName, fkey); err != nil {
+		m.log.Errorf("[ensure-fk] check fk exist err: %v", err)
+		return
+	} else if exist > 0 {
+		return
+	}
+
+	// run
+	if err = m.ensureExec(cmd); err != nil {
+		m.log.Errorf(`[ensure-fk] "%s" err: %v`, cmd, err)
+		return
+	}
+	return
+}
+
+// EnsureTextIndex 确认全文索引
+func (m *Model) EnsureTextIndex(f *orm.FieldInfo) (err error) {
+	var (
+		indexKey = fmt.Sprintf(`%s_%s_text`, m.TableName, f.Name)
+		cmd      = fmt.Sprintf("ALTER TABLE `%s` ADD FULLTEXT %s (`%s`)", m.TableName, indexKey, f.Name)
+		exist    = 0
+	)
+	if m.plan != nil && m.plan.IndexExpect(indexKey, []string{f.Name}, false) {
+		return
+	}
+	if err = m.DatabaseSQL.DB.Get(&exist, `SELECT count(*) FROM information_schema.statistics
+WHERE table_name = ? AND index_name = ? AND table_schema = database()`, m.TableName, indexKey); err != nil {
+		m.log.Errorf("[ensure-text] check index exist err: %v", err)
+		return
+	} else if exist > 0 {
+		return
+	}
+
+	// run
+	if err = m.ensureExec(cmd); err != nil {
+		m.log.Errorf(`[ensure-text] "%s" err: %v`, cmd, err)
+		return
+	}
+	m.log.Debug(cmd)
+	return
+}
+
 // Ensure is sync table with struct
 func (m *Model) Ensure(st interface{}) (err error) {
+	if err = m.ensure(st); err != nil {
+		return
+	}
+	// contig parse and cache
+	m.ContigInsert = ""
+	m.ContigUpdate = ""
+	if err = m.ContigParse(st); err != nil {
+		return
+	}
+	return orm.ModelInfoSave(m.DatabaseSQL, m.TableName, m.info())
+}
+
+// ensure 同步字段及索引
+func (m *Model) ensure(st interface{}) (err error) {
 	// syncDB
 	if err = m.EnsureColumn(st); err != nil {
 		return
@@ -515,12 +699,219 @@ func (m *Model) Ensure(st interface{}) (err error) {
 			return
 		}
 	}
-	// contig parse and cache
-	m.ContigInsert = ""
-	m.ContigUpdate = ""
-	if err = m.ContigParse(st); err != nil {
+	// text index
+	for _, f := range fieldInfoLis {
+		if !f.IndexText {
+			continue
+		}
+		if err = m.EnsureTextIndex(f); err != nil {
+			return
+		}
+	}
+	// foreign key
+	for _, f := range fieldInfoLis {
+		if f.Fk == nil {
+			continue
+		}
+		if err = m.EnsureForeignKey(f); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ensureExec 执行Ensure的语句, 预览时只记录
+func (m *Model) ensureExec(cmd string) (err error) {
+	if m.plan != nil {
+		m.plan.DDL = append(m.plan.DDL, cmd)
 		return
 	}
+	m.Result, err = m.DatabaseSQL.DB.Exec(cmd)
+	return
+}
+
+// Plan 对比struct与库中的表结构, 返回Ensure将执行的语句, 不执行
+func (m *Model) Plan(st interface{}) (ret *orm.Plan, err error) {
+	var (
+		mp   = m.Copy()
+		info *orm.TableInfo
+	)
+	ret = orm.NewPlan(m.TableName)
+	if info, err = m.Inspect(); err == orm.ErrSyncTableNotExist {
+		err = nil
+	} else if err != nil {
+		return
+	} else {
+		ret.TableExist = true
+		ret.TableActual(info)
+	}
+	// 按Ensure的流程记录语句
+	mp.plan = ret
+	if err = mp.ensure(st); err != nil {
+		return
+	}
+	ret.Diff()
+	return
+}
+
+// Inspect 读取库中表的字段及索引
+func (m *Model) Inspect() (ret *orm.TableInfo, err error) {
+	var (
+		tableExist = 0
+	)
+	if err = m.DatabaseSQL.DB.Get(&tableExist,
+		`SELECT count(*) FROM information_schema.tables WHERE table_name=? AND table_schema=?`,
+		m.TableName, m.DatabaseSQL.ArgConn.Database); err != nil {
+		m.log.Errorf("[inspect] check table exist err: %v", err)
+		return
+	} else if tableExist == 0 {
+		err = orm.ErrSyncTableNotExist
+		return
+	}
+	ret = &orm.TableInfo{Name: m.TableName}
+	// columns
+	var columnLis []struct {
+		Name    string         `db:"COLUMN_NAME"`
+		Kind    string         `db:"COLUMN_TYPE"`
+		Null    string         `db:"IS_NULLABLE"`
+		Default sql.NullString `db:"COLUMN_DEFAULT"`
+		Key     string         `db:"COLUMN_KEY"`
+		Extra   string         `db:"EXTRA"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&columnLis, `SELECT COLUMN_NAME, COLUMN_TYPE, IS_NULLABLE, COLUMN_DEFAULT,
+COLUMN_KEY, EXTRA FROM information_schema.columns WHERE table_name=? AND table_schema=? ORDER BY ORDINAL_POSITION`,
+		m.TableName, m.DatabaseSQL.ArgConn.Database); err != nil {
+		m.log.Errorf("[inspect] select column err: %v", err)
+		return
+	}
+	for _, c := range columnLis {
+		ret.Columns = append(ret.Columns, &orm.ColumnInfo{
+			Name:    c.Name,
+			Kind:    c.Kind,
+			Null:    c.Null == "YES",
+			Default: c.Default.String,
+			Primary: c.Key == "PRI",
+			Serial:  strings.Contains(strings.ToLower(c.Extra), "auto_increment"),
+		})
+	}
+	// indexes
+	var indexLis []struct {
+		Name      string `db:"INDEX_NAME"`
+		NonUnique int    `db:"NON_UNIQUE"`
+		Column    string `db:"COLUMN_NAME"`
+		Kind      string `db:"INDEX_TYPE"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&indexLis, `SELECT INDEX_NAME, NON_UNIQUE, IFNULL(COLUMN_NAME, '') AS COLUMN_NAME, INDEX_TYPE
+FROM information_schema.statistics WHERE table_name=? AND table_schema=? ORDER BY INDEX_NAME, SEQ_IN_INDEX`,
+		m.TableName, m.DatabaseSQL.ArgConn.Database); err != nil {
+		m.log.Errorf("[inspect] select index err: %v", err)
+		return
+	}
+	for i := 0; i < len(indexLis); {
+		idx := &orm.IndexInfo{Name: indexLis[i].Name, Unique: indexLis[i].NonUnique == 0, Primary: indexLis[i].Name == "PRIMARY",
+			Text: indexLis[i].Kind == "FULLTEXT"}
+		for ; i < len(indexLis) && indexLis[i].Name == idx.Name; i++ {
+			idx.Key = append(idx.Key, indexLis[i].Column)
+		}
+		ret.Indexes = append(ret.Indexes, idx)
+	}
+	// foreign keys
+	var fkLis []struct {
+		Name      string `db:"name"`
+		Column    string `db:"column_name"`
+		RefTable  string `db:"ref_table"`
+		RefColumn string `db:"ref_column"`
+		OnDelete  string `db:"delete_rule"`
+		OnUpdate  string `db:"update_rule"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&fkLis, `SELECT k.CONSTRAINT_NAME AS name, k.COLUMN_NAME AS column_name,
+k.REFERENCED_TABLE_NAME AS ref_table, k.REFERENCED_COLUMN_NAME AS ref_column,
+r.DELETE_RULE AS delete_rule, r.UPDATE_RULE AS update_rule FROM information_schema.KEY_COLUMN_USAGE k
+JOIN information_schema.REFERENTIAL_CONSTRAINTS r ON r.CONSTRAINT_SCHEMA = k.TABLE_SCHEMA AND r.CONSTRAINT_NAME = k.CONSTRAINT_NAME
+WHERE k.TABLE_NAME=? AND k.TABLE_SCHEMA=? AND k.REFERENCED_TABLE_NAME IS NOT NULL ORDER BY k.CONSTRAINT_NAME`,
+		m.TableName, m.DatabaseSQL.ArgConn.Database); err != nil {
+		m.log.Errorf("[inspect] select fk err: %v", err)
+		return
+	}
+	// 外键自动创建的同名索引不计入
+	fkName := make(map[string]bool)
+	for _, fk := range fkLis {
+		fkName[fk.Name] = true
+		ret.Fks = append(ret.Fks, &orm.FkInfo{Name: fk.Name, Column: fk.Column, RefTable: fk.RefTable,
+			RefColumn: fk.RefColumn, OnDelete: fk.OnDelete, OnUpdate: fk.OnUpdate})
+	}
+	indexes := ret.Indexes[:0]
+	for _, idx := range ret.Indexes {
+		if !fkName[idx.Name] {
+			indexes = append(indexes, idx)
+		}
+	}
+	ret.Indexes = indexes
+	return
+}
+
+// EnsureWith 同Ensure, 并按参数修改/删除/重命名已有的字段及索引
+func (m *Model) EnsureWith(st interface{}, opt *orm.ArgEnsure) (err error) {
+	var (
+		p *orm.Plan
+	)
+	if opt == nil {
+		return m.Ensure(st)
+	}
+	if p, err = m.Plan(st); err != nil {
+		return
+	}
+	// rename: CHANGE兼容mariadb及mysql5.7
+	if opt.Rename && len(p.ColumnRenamed) > 0 {
+		for _, c := range p.ColumnRenamed {
+			if err = m.ensureWithExec(fmt.Sprintf("ALTER TABLE `%s` CHANGE `%s` `%s` %s",
+				m.TableName, c.Was, c.Name, c.Define)); err != nil {
+				return
+			}
+		}
+		if p, err = m.Plan(st); err != nil {
+			return
+		}
+	}
+	// drop
+	if opt.DropIndex {
+		for _, idx := range p.IndexExtra {
+			if idx.Name == "PRIMARY" {
+				continue
+			}
+			if err = m.ensureWithExec(fmt.Sprintf("ALTER TABLE `%s` DROP INDEX `%s`",
+				m.TableName, idx.Name)); err != nil {
+				return
+			}
+		}
+	}
+	if opt.DropColumn {
+		for _, c := range p.ColumnExtra {
+			if err = m.ensureWithExec(fmt.Sprintf("ALTER TABLE `%s` DROP COLUMN `%s`",
+				m.TableName, c.Name)); err != nil {
+				return
+			}
+		}
+	}
+	// alter
+	if opt.AlterColumn {
+		for _, c := range p.ColumnChanged {
+			if err = m.ensureWithExec(fmt.Sprintf("ALTER TABLE `%s` MODIFY `%s` %s",
+				m.TableName, c.Name, c.Define)); err != nil {
+				return
+			}
+		}
+	}
+	return m.Ensure(st)
+}
+
+// ensureWithExec 执行EnsureWith的语句
+func (m *Model) ensureWithExec(cmd string) (err error) {
+	if err = m.ensureExec(cmd); err != nil {
+		m.log.Errorf(`[ensure-with] %s err: %v`, cmd, err)
+		return
+	}
+	m.log.Infof(`[ensure-with] %s`, cmd)
 	return
 }
 
@@ -529,11 +920,31 @@ func (m *Model) Begin() (orm.Trans, error) {
 	return m.BeginWith(nil)
 }
 
+// BeginNested 在parent中以保存点开启子事务
+func (m *Model) BeginNested(parent orm.Trans) (ret orm.Trans, err error) {
+	if parent == nil {
+		return m.BeginWith(nil)
+	}
+	t, ok := parent.(*Trans)
+	if !ok || t == nil || t.db != m.DatabaseSQL {
+		return nil, orm.ErrTransInvalid
+	}
+	var c *Trans
+	if c, err = t.nested(); err != nil {
+		return
+	}
+	return c, nil
+}
+
 // BeginWith 事务
 func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 	var (
-		t = &Trans{}
+		t = &Trans{db: m.DatabaseSQL}
 	)
+	if arg != nil && (arg.Deferrable || len(arg.Lock) > 0) {
+		// 驱动不支持的参数, 不静默忽略
+		return t, orm.ErrTransOptionNotSupport
+	}
 
 	// 事务级别 需要在tx产生前执行 https://dev.mysql.com/doc/refman/5.5/en/set-transaction.html
 	// The statement is permitted within transactions, but does not affect the current ongoing transaction.
@@ -564,9 +975,26 @@ func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 		}
 	}
 
-	if t.Tx, err = m.DatabaseSQL.DB.Beginx(); err != nil {
-		return t, err
+	var opts *sql.TxOptions
+	if arg != nil && arg.ReadOnly {
+		opts = &sql.TxOptions{ReadOnly: true}
+	}
+	if arg != nil && arg.StatementTimeout > 0 {
+		// 单条语句超时为会话设置, 固定连接以便事务结束后恢复
+		if t.conn, err = m.DatabaseSQL.DB.Connx(m.getContext()); err != nil {
+			return t, orm.ContextErr(m.ctx, err)
+		}
+		t.Tx, err = t.conn.BeginTxx(m.getContext(), opts)
+	} else {
+		t.Tx, err = m.DatabaseSQL.DB.BeginTxx(m.getContext(), opts)
+	}
+	if err != nil {
+		if t.conn != nil {
+			_ = t.conn.Close()
+		}
+		return t, orm.ContextErr(m.ctx, err)
 	}
+	t.ctx = m.ctx
 	// 记录调用处
 	if pc, file, line, ok := runtime.Caller(2); ok {
 		// func
@@ -599,8 +1027,30 @@ func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 	//	println("tx_isolation:", d.A, d.B)
 	//}
 
+	// 单条语句超时: 会话级设置, 事务结束后在固定的连接上恢复
+	// mysql的max_execution_time只作用于只读的SELECT, 写语句不受限制; mariadb的max_statement_time作用于所有语句
+	if arg.StatementTimeout > 0 {
+		var set, reset string
+		if m.DatabaseSQL.Version() == DbVerMaria {
+			set = fmt.Sprintf("SET SESSION max_statement_time = %.3f", arg.StatementTimeout.Seconds())
+			reset = "SET SESSION max_statement_time = DEFAULT"
+		} else {
+			set = fmt.Sprintf("SET SESSION max_execution_time = %d", transMillisecond(arg.StatementTimeout))
+			reset = "SET SESSION max_execution_time = DEFAULT"
+		}
+		if _, err = t.Exec(set); err != nil {
+			_ = t.Rollback()
+			return
+		}
+		t.sessionReset = append(t.sessionReset, reset)
+	}
+
 	// 超时回滚
-	t.timer = time.NewTimer(orm.TransTimeout)
+	timeout := orm.TransTimeout
+	if arg.Timeout > 0 {
+		timeout = arg.Timeout
+	}
+	t.timer = time.NewTimer(timeout)
 	go func() {
 		defer func() {
 			if _err := recover(); _err != nil {
@@ -610,10 +1060,12 @@ func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 
 		<-t.timer.C
 		// timeout
-		if !t.isFinish {
+		if t.finish(transExpired) {
+			// 只有CAS成功的一方写入错误, 之后Error/Commit均返回超时
+			t.errSet(orm.ErrTransTimeout)
 			// log sql history
 			m.log.Errorf("[trans-timeout] tableName=%s sql=%s", m.TableName, t.debugReport())
-			_ = m.Rollback(t)
+			_ = t.rollback(orm.ErrTransTimeout)
 		}
 	}()
 
@@ -655,16 +1107,34 @@ func (m *Model) AutoTrans(t orm.Trans) (err error) {
 
 // Exec 执行语句
 func (m *Model) Exec(query string, args ...interface{}) (result orm.Result, err error) {
-	result, err = m.DatabaseSQL.DB.Exec(query, args...)
+	result, err = m.DatabaseSQL.DB.ExecContext(m.getContext(), query, args...)
+	err = orm.ContextErr(m.ctx, err)
 	return
 }
 
 // Exec 执行语句
 func (m *Model) Select(dest interface{}, query string, args ...interface{}) (err error) {
-	err = m.DatabaseSQL.DB.Select(dest, query, args...)
+	err = m.DatabaseSQL.DB.SelectContext(m.getContext(), dest, query, args...)
+	err = orm.ContextErr(m.ctx, err)
 	return
 }
 
+// WithContext 返回绑定上下文的model
+func (m *Model) WithContext(ctx context.Context) (ret orm.Model) {
+	r := m.Copy()
+	r.VirtualSQL = m.VirtualSQL
+	r.ctx = ctx
+	return r
+}
+
+// getContext 取上下文
+func (m *Model) getContext() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
 // With 设置日志级别
 func (m *Model) With(arg *orm.ArgModel) (ret orm.Model) {
 	r := m.Copy()
@@ -683,3 +1153,11 @@ func (m *Model) With(arg *orm.ArgModel) (ret orm.Model) {
 	}
 	return r
 }
+
+// transMillisecond 超时换算为毫秒, 不足1毫秒按1毫秒
+func transMillisecond(d time.Duration) int64 {
+	if ms := int64(d / time.Millisecond); ms > 0 {
+		return ms
+	}
+	return 1
+}
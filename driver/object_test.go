@@ -5,8 +5,12 @@ import (
 
 	"testing"
 
+	"context"
 	"database/sql/driver"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 )
 
 // User 用户
@@ -143,6 +147,1206 @@ func Test_ObjectsCreateUpdate(t *testing.T) {
 	t.Logf(`"%v" PASS %s`, db, orm.JSONMust(user0))
 }
 
+// 上下文取消
+func Test_ObjectsContext(t *testing.T) {
+	db := testGetDB()
+	var (
+		tbl0 = "test_object_context"
+		m0   = db.Model(tbl0).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+		err  error
+	)
+	if err = m0.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Ensure(&User{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err = m0.WithContext(ctx).Objects().Create(&User{Username: "tester0"}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	cancel()
+
+	// 已取消
+	_user := new(User)
+	if err = m0.Objects().WithContext(ctx).Filter(orm.M{"username": "tester0"}).One(_user); err != orm.ErrContextCanceled {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrContextCanceled, err)
+		return
+	}
+	if err = m0.WithContext(ctx).Objects().Create(&User{Username: "tester1"}); err != orm.ErrContextCanceled {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrContextCanceled, err)
+		return
+	}
+	if _, err = m0.Objects().WithContext(ctx).Count(); err != orm.ErrContextCanceled {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrContextCanceled, err)
+		return
+	}
+
+	// 有截止时间时写入
+	ctx, cancel = context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err = m0.WithContext(ctx).Objects().Create(&User{Username: "tester2"}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Objects().WithContext(ctx).Filter(orm.M{"username": "tester2"}).Update(map[string]interface{}{"amount": 2}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if n, _ := m0.Objects().Count(); n != 2 {
+		t.Fatalf(`"%v" expect 2 records, get %d`, db, n)
+		return
+	}
+	// 有截止时间时无搜索条件的统计及读取
+	var lis []*User
+	if n, _err := m0.Objects().WithContext(ctx).Count(); _err != nil || n != 2 {
+		t.Fatalf(`"%v" expect 2 records, get %d %v`, db, n, _err)
+		return
+	}
+	if err = m0.Objects().WithContext(ctx).Sort("username").All(&lis); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(lis) != 2 {
+		t.Fatalf(`"%v" expect 2 records, get %d`, db, len(lis))
+		return
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
+
+// 游标读取
+func Test_ObjectsIter(t *testing.T) {
+	db := testGetDB()
+	var (
+		tbl0 = "test_object_iter"
+		m0   = db.Model(tbl0).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+		err  error
+	)
+	if err = m0.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Ensure(&User{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	for i := 0; i < 5; i++ {
+		if err = m0.Objects().Create(&User{Username: fmt.Sprintf("tester%d", i), Amount: float64(i)}); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	// 与All结果一致
+	var (
+		lis  []*User
+		iter []string
+		user = new(User)
+		ob   = m0.Objects().Filter(orm.M{"amount$gte$": 1}).Sort("-amount").Skip(1).Limit(2)
+	)
+	if err = m0.Objects().Filter(orm.M{"amount$gte$": 1}).Sort("-amount").Skip(1).Limit(2).All(&lis); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = ob.Each(user, func() error {
+		iter = append(iter, user.Username)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if len(lis) != 2 || len(iter) != 2 || lis[0].Username != iter[0] || lis[1].Username != iter[1] {
+		t.Fatalf(`"%v" iter diff: %s %v`, db, orm.JSONMust(lis), iter)
+		return
+	}
+	if mt, _ := ob.Meta(); mt.Num != 2 {
+		t.Fatalf(`"%v" meta num: %d`, db, mt.Num)
+		return
+	}
+
+	// 回调中止
+	errStop := fmt.Errorf("stop")
+	if err = m0.Objects().Each(user, func() error {
+		return errStop
+	}); err != errStop {
+		t.Fatalf(`"%v" expect %v, get %v`, db, errStop, err)
+		return
+	}
+
+	// 事务中读取
+	if tx, _err := m0.Begin(); _err != nil {
+		t.Fatal(_err)
+		return
+	} else {
+		n := 0
+		err = m0.Objects().TEach(user, func() error {
+			n++
+			return nil
+		}, tx)
+		_ = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+			return
+		} else if n != 5 {
+			t.Fatalf(`"%v" trans iter num: %d`, db, n)
+			return
+		}
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
+
+// 批量新建
+func Test_ObjectsCreateMany(t *testing.T) {
+	db := testGetDB()
+	var (
+		tbl0 = "test_object_create_many"
+		m0   = db.Model(tbl0).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+		num  = 1000
+		lis  = make([]*User, num)
+		ob   = m0.Objects()
+		err  error
+	)
+	if err = m0.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Ensure(&User{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	for i := range lis {
+		lis[i] = &User{Username: fmt.Sprintf("tester%d", i), Amount: float64(i)}
+	}
+	if err = ob.CreateMany(lis); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if res, _ := ob.GetResult(); res == nil {
+		t.Fatalf(`"%v" result empty`, db)
+		return
+	} else if n, _err := res.RowsAffected(); _err != nil || n != int64(num) {
+		t.Fatalf(`"%v" rows affected %d %v`, db, n, _err)
+		return
+	} else {
+		t.Logf(`"%v" batch: %d`, db, len(res.(orm.ResultBatch)))
+	}
+	if n, _ := m0.Objects().Count(); n != num {
+		t.Fatalf(`"%v" expect %d records, get %d`, db, num, n)
+		return
+	}
+
+	// 占位符上限较小时分批插入(User有5列, 每批2条); 单条记录超出上限时报错
+	if restore := testBatchArgsMax(12); restore != nil {
+		more := []*User{{Username: "batch0"}, {Username: "batch1"}, {Username: "batch2"}, {Username: "batch3"}, {Username: "batch4"}}
+		ob = m0.Objects()
+		err = ob.CreateMany(more)
+		restore()
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+		if res, _ := ob.GetResult(); len(res.(orm.ResultBatch)) != 3 {
+			t.Fatalf(`"%v" batch: %d`, db, len(res.(orm.ResultBatch)))
+			return
+		}
+		restore = testBatchArgsMax(4)
+		err = m0.Objects().CreateMany([]*User{{Username: "batch5"}})
+		restore()
+		if err != orm.ErrCreateManyArgsMax {
+			t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrCreateManyArgsMax, err)
+			return
+		}
+		if err = m0.Objects().Filter(orm.M{"username$in$": []string{"batch0", "batch1", "batch2", "batch3", "batch4"}}).Delete(); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	// 非切片
+	if err = m0.Objects().CreateMany(lis[0]); err != orm.ErrCreateManyNotSlice {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrCreateManyNotSlice, err)
+		return
+	}
+
+	// 事务中插入, 回滚
+	if tx, _err := m0.Begin(); _err != nil {
+		t.Fatal(_err)
+		return
+	} else {
+		err = m0.Objects().TCreateMany([]User{{Username: "tester-tx"}}, tx)
+		_ = tx.Rollback()
+		if err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+	if n, _ := m0.Objects().Count(); n != num {
+		t.Fatalf(`"%v" expect %d records, get %d`, db, num, n)
+		return
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
+
+// 插入或更新
+func Test_ObjectsUpsert(t *testing.T) {
+	db := testGetDB()
+	var (
+		tbl0 = "test_object_upsert"
+		m0   = db.Model(tbl0).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+		err  error
+	)
+	if err = m0.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Ensure(&User{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// 不存在时插入, 存在时更新
+	for _, amount := range []float64{100, 200} {
+		if err = m0.Objects().Upsert(&User{Username: "tester0", Amount: amount}); err != nil {
+			t.Fatal(err)
+			return
+		}
+		_user := new(User)
+		if err = m0.Objects().Filter(orm.M{"username": "tester0"}).One(_user); err != nil {
+			t.Fatal(err)
+			return
+		} else if _user.Amount != amount {
+			t.Fatalf(`"%v" expect amount %v, get %v`, db, amount, _user.Amount)
+			return
+		}
+	}
+
+	// 指定冲突字段
+	if err = m0.Objects().Upsert(&User{Username: "tester0", Amount: 300}, "Username"); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Objects().Upsert(&User{Username: "tester0"}, "nonexistent"); err != orm.ErrUpsertKeyInvalid {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrUpsertKeyInvalid, err)
+		return
+	}
+	// mysql: 冲突字段需为主键或唯一索引
+	if TestName == orm.DriverNameMysql {
+		if err = m0.Objects().Upsert(&User{Username: "tester0"}, "amount"); err != orm.ErrUpsertKeyNotUnique {
+			t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrUpsertKeyNotUnique, err)
+			return
+		}
+	}
+
+	// 事务中
+	if tx, _err := m0.Begin(); _err != nil {
+		t.Fatal(_err)
+		return
+	} else {
+		if err = m0.Objects().TUpsert(&User{Username: "tester1", Amount: 1}, tx); err == nil {
+			err = m0.Objects().TUpsert(&User{Username: "tester0", Amount: 400}, tx)
+		}
+		tx.ErrorSet(err)
+		if err = m0.AutoTrans(tx); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+	var lis []*User
+	if err = m0.Objects().Sort("username").All(&lis); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(lis) != 2 || lis[0].Amount != 400 || lis[1].Amount != 1 {
+		t.Fatalf(`"%v" upsert result: %s`, db, orm.JSONMust(lis))
+		return
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
+
+// 测试字段选择
+func Test_ObjectsFields(t *testing.T) {
+	db := testGetDB()
+	var (
+		tbl0 = "test_object_fields"
+		m0   = db.Model(tbl0).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+		err  error
+	)
+	if err = m0.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Ensure(&User{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	for i := 0; i < 3; i++ {
+		if err = m0.Objects().Create(&User{
+			Username: fmt.Sprintf("tester%d", i),
+			Amount:   float64(i + 1),
+			Balance:  float64(i + 1),
+		}); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	// 只取指定字段, 其它字段为零值
+	var lis []*User
+	if err = m0.Objects().Fields("username", "amount").Sort("username").All(&lis); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(lis) != 3 || lis[2].Amount != 3 || lis[2].Balance != 0 {
+		t.Fatalf(`"%v" fields result: %s`, db, orm.JSONMust(lis))
+		return
+	}
+
+	// 排除字段
+	_user := new(User)
+	if err = m0.Objects().Omit("amount", "meta").Filter(orm.M{"username": "tester1"}).One(_user); err != nil {
+		t.Fatal(err)
+		return
+	} else if _user.Username != "tester1" || _user.Amount != 0 || _user.Balance != 2 {
+		t.Fatalf(`"%v" omit result: %s`, db, orm.JSONMust(_user))
+		return
+	}
+
+	// 读入map
+	var lisM []orm.M
+	if err = m0.Objects().Fields("username", "balance").Sort("username").All(&lisM); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(lisM) != 3 || len(lisM[0]) != 2 || lisM[0]["username"] != "tester0" {
+		t.Fatalf(`"%v" fields map result: %s`, db, orm.JSONMust(lisM))
+		return
+	}
+	oneM := orm.M{}
+	if err = m0.Objects().Fields("username").Filter(orm.M{"username": "tester2"}).One(&oneM); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(oneM) != 1 || oneM["username"] != "tester2" {
+		t.Fatalf(`"%v" fields map result: %s`, db, orm.JSONMust(oneM))
+		return
+	}
+
+	// 摘要
+	if mt, _err := m0.Objects().Fields("username").Omit("amount").Meta(); _err != nil {
+		t.Fatal(_err)
+		return
+	} else if mt.Field == nil || mt.Omit == nil {
+		t.Fatalf(`"%v" meta: %s`, db, orm.JSONMust(mt))
+		return
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
+
+// 测试聚合
+func Test_ObjectsAggregate(t *testing.T) {
+	db := testGetDB()
+	var (
+		tbl0 = "test_object_aggregate"
+		m0   = db.Model(tbl0).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+		err  error
+	)
+	if err = m0.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Ensure(&User{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	// 空表
+	if v, _err := m0.Objects().Sum("amount"); _err != nil || v != 0 {
+		t.Fatalf(`"%v" empty sum %v %v`, db, v, _err)
+		return
+	}
+	for i := 0; i < 6; i++ {
+		if err = m0.Objects().Create(&User{
+			Username: fmt.Sprintf("tester%d", i),
+			Amount:   float64(i + 1),
+			Balance:  float64(i % 2),
+		}); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	// 单值聚合
+	for _, c := range []struct {
+		fn     func(string) (float64, error)
+		expect float64
+	}{
+		{m0.Objects().Sum, 21},
+		{m0.Objects().Avg, 3.5},
+		{m0.Objects().Min, 1},
+		{m0.Objects().Max, 6},
+		{m0.Objects().Filter(orm.M{"balance": 1}).Sum, 12},
+	} {
+		if v, _err := c.fn("amount"); _err != nil {
+			t.Fatal(_err)
+			return
+		} else if v != c.expect {
+			t.Fatalf(`"%v" expect %v, get %v`, db, c.expect, v)
+			return
+		}
+	}
+	if _, err = m0.Objects().Sum("amount;drop"); err != orm.ErrAggregateInvalid {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrAggregateInvalid, err)
+		return
+	}
+
+	// 分组聚合
+	var lis []struct {
+		Balance float64 `db:"balance" bson:"balance"`
+		Total   float64 `db:"total" bson:"total"`
+		Num     int     `db:"num" bson:"num"`
+	}
+	if err = m0.Objects().Filter(orm.M{"amount$gt$": 1}).Sort("balance").Aggregate([]string{"balance"},
+		map[string]string{"total": "sum(amount)", "num": "count(*)"}, &lis); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(lis) != 2 || lis[0].Total != 8 || lis[0].Num != 2 || lis[1].Total != 12 || lis[1].Num != 3 {
+		t.Fatalf(`"%v" aggregate result: %s`, db, orm.JSONMust(lis))
+		return
+	}
+	// 按聚合别名排序及分页
+	for _, ob := range []orm.Objects{
+		m0.Objects().Filter(orm.M{"amount$gt$": 1}).Sort("-total").Limit(1),
+		m0.Objects().Filter(orm.M{"amount$gt$": 1}).Sort("total").Skip(1),
+	} {
+		lis = nil
+		if err = ob.Aggregate([]string{"balance"}, map[string]string{"total": "sum(amount)", "num": "count(*)"}, &lis); err != nil {
+			t.Fatal(err)
+			return
+		} else if len(lis) != 1 || lis[0].Total != 12 {
+			t.Fatalf(`"%v" aggregate page: %s`, db, orm.JSONMust(lis))
+			return
+		}
+	}
+	lis = nil
+	if err = m0.Objects().Limit(1).Aggregate([]string{"balance"}, map[string]string{"num": "count(*)"}, &lis); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(lis) != 1 {
+		t.Fatalf(`"%v" aggregate limit: %s`, db, orm.JSONMust(lis))
+		return
+	}
+	if err = m0.Objects().Sort("amount").Aggregate([]string{"balance"},
+		map[string]string{"total": "sum(amount)"}, &lis); err != orm.ErrAggregateSortInvalid {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrAggregateSortInvalid, err)
+		return
+	}
+	var lisM []orm.M
+	if err = m0.Objects().Aggregate(nil, map[string]string{"top": "max(amount)"}, &lisM); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(lisM) != 1 || len(lisM[0]) != 1 {
+		t.Fatalf(`"%v" aggregate result: %s`, db, orm.JSONMust(lisM))
+		return
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
+
+// 测试游标分页
+func Test_ObjectsAfter(t *testing.T) {
+	db := testGetDB()
+	var (
+		tbl0 = "test_object_after"
+		m0   = db.Model(tbl0).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+		err  error
+	)
+	if err = m0.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Ensure(&User{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	for i := 0; i < 7; i++ {
+		if err = m0.Objects().Create(&User{
+			Username: fmt.Sprintf("tester%d", i),
+			Amount:   float64(i / 2), // 有重复值, 依赖第二排序字段
+		}); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+
+	// 读一页, 返回用户名列表及摘要
+	page := func(token string) (names []string, mt *orm.Meta) {
+		var lis []*User
+		ob := m0.Objects().Sort("amount", "username").Limit(3).After(token)
+		if err = ob.All(&lis); err != nil {
+			t.Fatal(err)
+		}
+		if mt, err = ob.Meta(); err != nil {
+			t.Fatal(err)
+		}
+		for _, u := range lis {
+			names = append(names, u.Username)
+		}
+		return
+	}
+	expect := func(names []string, want ...int) {
+		if len(names) != len(want) {
+			t.Fatalf(`"%v" page %v want %v`, db, names, want)
+		}
+		for i, n := range want {
+			if names[i] != fmt.Sprintf("tester%d", n) {
+				t.Fatalf(`"%v" page %v want %v`, db, names, want)
+			}
+		}
+	}
+
+	names, mt := page("")
+	expect(names, 0, 1, 2)
+	if len(mt.Next) == 0 || len(mt.Prev) != 0 {
+		t.Fatalf(`"%v" meta: %s`, db, orm.JSONMust(mt))
+		return
+	}
+	names, mt = page(mt.Next)
+	expect(names, 3, 4, 5)
+	if len(mt.Next) == 0 || len(mt.Prev) == 0 {
+		t.Fatalf(`"%v" meta: %s`, db, orm.JSONMust(mt))
+		return
+	}
+	names, mt3 := page(mt.Next)
+	expect(names, 6)
+	if len(mt3.Next) != 0 || len(mt3.Prev) == 0 {
+		t.Fatalf(`"%v" meta: %s`, db, orm.JSONMust(mt3))
+		return
+	}
+	// 向前翻页
+	names, mt = page(mt3.Prev)
+	expect(names, 3, 4, 5)
+	names, mt = page(mt.Prev)
+	expect(names, 0, 1, 2)
+	if len(mt.Next) == 0 {
+		t.Fatalf(`"%v" meta: %s`, db, orm.JSONMust(mt))
+		return
+	}
+
+	// 按时间排序: 时间有重复值且带毫秒, 翻页不重复, 总数及搜索条件不含游标
+	type TimeUser struct {
+		Username string    `sorm:"primary;size(36)" json:"username"`
+		Birthday time.Time `sorm:"index" json:"birthday"`
+	}
+	var (
+		tbl1 = "test_object_after_time"
+		m1   = db.Model(tbl1).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+		at   = time.Date(2021, 1, 2, 3, 4, 5, 123000000, time.UTC)
+	)
+	if err = m1.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m1.Ensure(&TimeUser{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	for i := 0; i < 7; i++ {
+		if err = m1.Objects().Create(&TimeUser{
+			Username: fmt.Sprintf("tester%d", i),
+			Birthday: at.Add(time.Duration(i/2) * time.Millisecond),
+		}); err != nil {
+			t.Fatal(err)
+			return
+		}
+	}
+	var (
+		token string
+		seen  []string
+	)
+	for i := 0; i < 4; i++ {
+		var tl []*TimeUser
+		ob := m1.Objects().Filter(orm.M{"username$ne$": "none"}).Sort("-birthday", "username").Limit(2).After(token)
+		if err = ob.All(&tl); err != nil {
+			t.Fatal(err)
+		}
+		if mt, err = ob.Meta(); err != nil {
+			t.Fatal(err)
+		} else if k, _ := mt.Key.(orm.M); mt.Count != 7 || len(k) != 1 || k["username$ne$"] != "none" {
+			t.Fatalf(`"%v" meta: %s`, db, orm.JSONMust(mt))
+		}
+		for _, u := range tl {
+			seen = append(seen, u.Username)
+		}
+		token = mt.Next
+	}
+	expect(seen, 6, 4, 5, 2, 3, 0, 1)
+	if len(token) != 0 {
+		t.Fatalf(`"%v" next: %s`, db, token)
+		return
+	}
+
+	// 非法游标
+	var lis []*User
+	if err = m0.Objects().Sort("amount").After("invalid").All(&lis); err != orm.ErrCursorInvalid {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrCursorInvalid, err)
+		return
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
+
+// HookUser 带钩子的用户
+type HookUser struct {
+	Username string  `sorm:"primary;size(36)" json:"username"`
+	Amount   float64 `sorm:"decimal(20,8)" json:"amount"`
+	Found    bool    `sorm:"-" db:"-" bson:"-" json:"-"` // AfterFind时标记
+}
+
+var errHookAmount = errors.New("amount negative")
+var errHookLocked = errors.New("user locked")
+
+func (u *HookUser) BeforeCreate() error {
+	if u.Amount < 0 {
+		return errHookAmount
+	}
+	return nil
+}
+
+func (u *HookUser) BeforeUpdate() error {
+	return u.BeforeCreate()
+}
+
+func (u *HookUser) BeforeDelete() error {
+	if u.Username == "locked" {
+		return errHookLocked
+	}
+	return nil
+}
+
+func (u *HookUser) AfterFind() error {
+	u.Found = true
+	return nil
+}
+
+// 测试钩子
+func Test_ObjectsHook(t *testing.T) {
+	db := testGetDB()
+	var (
+		tbl0 = "test_object_hook"
+		m0   = db.Model(tbl0).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+		err  error
+	)
+	if err = m0.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Ensure(&HookUser{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// 钩子出错时中止
+	if err = m0.Objects().Create(&HookUser{Username: "tester0", Amount: -1}); err != errHookAmount {
+		t.Fatalf(`"%v" expect %v, get %v`, db, errHookAmount, err)
+		return
+	}
+	if err = m0.Objects().CreateMany([]HookUser{{Username: "tester0"}, {Username: "tester1", Amount: -1}}); err != errHookAmount {
+		t.Fatalf(`"%v" expect %v, get %v`, db, errHookAmount, err)
+		return
+	}
+	if n, _ := m0.Objects().Count(); n != 0 {
+		t.Fatalf(`"%v" expect 0 record, get %d`, db, n)
+		return
+	}
+	if err = m0.Objects().CreateMany([]HookUser{{Username: "tester0"}, {Username: "locked"}}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Objects().Filter(orm.M{"username": "tester0"}).Update(&HookUser{Username: "tester0", Amount: -1}); err != errHookAmount {
+		t.Fatalf(`"%v" expect %v, get %v`, db, errHookAmount, err)
+		return
+	}
+
+	// 读取后回调
+	_user := new(HookUser)
+	if err = m0.Objects().Filter(orm.M{"username": "tester0"}).One(_user); err != nil {
+		t.Fatal(err)
+		return
+	} else if !_user.Found || _user.Amount != 0 {
+		t.Fatalf(`"%v" after find: %v`, db, _user)
+		return
+	}
+	var lis []HookUser
+	if err = m0.Objects().All(&lis); err != nil {
+		t.Fatal(err)
+		return
+	} else if len(lis) != 2 || !lis[0].Found || !lis[1].Found {
+		t.Fatalf(`"%v" after find: %v`, db, lis)
+		return
+	}
+
+	// 删除前回调
+	if err = m0.Objects().Filter(orm.M{"username": "locked"}).Delete(&HookUser{Username: "locked"}); err != errHookLocked {
+		t.Fatalf(`"%v" expect %v, get %v`, db, errHookLocked, err)
+		return
+	}
+
+	// 事务中钩子出错, AutoTrans回滚
+	if tx, _err := m0.Begin(); _err != nil {
+		t.Fatal(_err)
+		return
+	} else {
+		if err = m0.Objects().TCreate(&HookUser{Username: "tester2"}, tx); err != nil {
+			t.Fatal(err)
+			return
+		}
+		if err = m0.Objects().TCreate(&HookUser{Username: "tester3", Amount: -1}, tx); err != errHookAmount {
+			t.Fatalf(`"%v" expect %v, get %v`, db, errHookAmount, err)
+			return
+		}
+		if tx.Error() != errHookAmount {
+			t.Fatalf(`"%v" trans error: %v`, db, tx.Error())
+			return
+		}
+		_ = m0.AutoTrans(tx)
+	}
+	if n, _ := m0.Objects().Count(); n != 2 {
+		t.Fatalf(`"%v" expect 2 record, get %d`, db, n)
+		return
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
+
+// StampUser 带自动时间字段的用户
+type StampUser struct {
+	Username   string    `sorm:"primary;size(36)" json:"username"`
+	Amount     float64   `sorm:"decimal(20,8)" json:"amount"`
+	CreateTime time.Time `sorm:"created" json:"createTime"`
+	UpdateTime time.Time `sorm:"updated" json:"updateTime"`
+}
+
+// 测试自动时间字段
+func Test_ObjectsTimeField(t *testing.T) {
+	db := testGetDB()
+	var (
+		tbl0 = "test_object_time_field"
+		m0   = db.Model(tbl0).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+		old  = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+		err  error
+	)
+	if err = m0.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Ensure(&StampUser{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+
+	// 插入时填充
+	u := &StampUser{Username: "tester0"}
+	if err = m0.Objects().Create(u); err != nil {
+		t.Fatal(err)
+		return
+	} else if u.CreateTime.IsZero() || u.UpdateTime.IsZero() {
+		t.Fatalf(`"%v" create: %s`, db, orm.JSONMust(u))
+		return
+	}
+	if err = m0.Objects().CreateMany([]StampUser{{Username: "tester1"}, {Username: "tester2", CreateTime: old}}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	get := func(name string) (ret *StampUser) {
+		ret = new(StampUser)
+		if err = m0.Objects().Filter(orm.M{"username": name}).One(ret); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if _u := get("tester1"); _u.CreateTime.Year() <= 2000 || _u.UpdateTime.Year() <= 2000 {
+		t.Fatalf(`"%v" create many: %s`, db, orm.JSONMust(_u))
+		return
+	}
+	if _u := get("tester2"); _u.CreateTime.Year() != 2000 {
+		t.Fatalf(`"%v" create many: %s`, db, orm.JSONMust(_u))
+		return
+	}
+
+	// 按结构体更新: created不变
+	if err = m0.Objects().Filter(orm.M{"username": "tester0"}).Update(&StampUser{Username: "tester0", Amount: 1}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if _u := get("tester0"); _u.Amount != 1 || _u.CreateTime.Unix() != u.CreateTime.Unix() || _u.UpdateTime.Year() <= 2000 {
+		t.Fatalf(`"%v" update: %s`, db, orm.JSONMust(_u))
+		return
+	}
+
+	// 按map更新: 指定时不覆盖, 未指定时刷新
+	if err = m0.Objects().Filter(orm.M{"username": "tester0"}).Update(map[string]interface{}{"updatetime": old}); err != nil {
+		t.Fatal(err)
+		return
+	} else if _u := get("tester0"); _u.UpdateTime.Year() != 2000 {
+		t.Fatalf(`"%v" update map: %s`, db, orm.JSONMust(_u))
+		return
+	}
+	if err = m0.Objects().Filter(orm.M{"username": "tester0"}).Update(map[string]interface{}{"amount": 2}); err != nil {
+		t.Fatal(err)
+		return
+	} else if _u := get("tester0"); _u.Amount != 2 || _u.UpdateTime.Year() <= 2000 {
+		t.Fatalf(`"%v" update map: %s`, db, orm.JSONMust(_u))
+		return
+	}
+
+	// upsert: created不变
+	if err = m0.Objects().Upsert(&StampUser{Username: "tester2", Amount: 3}); err != nil {
+		t.Fatal(err)
+		return
+	} else if _u := get("tester2"); _u.Amount != 3 || _u.CreateTime.Year() != 2000 || _u.UpdateTime.Year() <= 2000 {
+		t.Fatalf(`"%v" upsert: %s`, db, orm.JSONMust(_u))
+		return
+	}
+
+	// 新开的数据库对象按map更新, 由ArgModel.Struct登记updated字段
+	if err = m0.Objects().Filter(orm.M{"username": "tester1"}).Update(map[string]interface{}{"updatetime": old}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	db1, err := orm.New(TestName, testConn())
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer db1.Close()
+	m1 := db1.ModelWith(tbl0, &orm.ArgModel{Struct: &StampUser{}})
+	if err = m1.Objects().Filter(orm.M{"username": "tester1"}).Update(map[string]interface{}{"amount": 4}); err != nil {
+		t.Fatal(err)
+		return
+	} else if _u := get("tester1"); _u.Amount != 4 || _u.UpdateTime.Year() <= 2000 {
+		t.Fatalf(`"%v" update map: %s`, db, orm.JSONMust(_u))
+		return
+	}
+
+	// 未登记时由ModelInfoTable读取updated字段
+	if err = m0.Objects().Filter(orm.M{"username": "tester1"}).Update(map[string]interface{}{"updatetime": old}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	db2, err := orm.New(TestName, testConn())
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer db2.Close()
+	if err = db2.Model(tbl0).Objects().Filter(orm.M{"username": "tester1"}).Update(map[string]interface{}{"amount": 5}); err != nil {
+		t.Fatal(err)
+		return
+	} else if _u := get("tester1"); _u.Amount != 5 || _u.UpdateTime.Year() <= 2000 {
+		t.Fatalf(`"%v" update map: %s`, db, orm.JSONMust(_u))
+		return
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
+
+// SoftUser 以时间字段软删除的用户
+type SoftUser struct {
+	Username   string    `sorm:"primary;size(36)" json:"username"`
+	Amount     float64   `sorm:"decimal(20,8)" json:"amount"`
+	DeleteTime time.Time `sorm:"softdelete" json:"deleteTime"`
+}
+
+// FlagUser 以bool字段软删除的用户
+type FlagUser struct {
+	Username string  `sorm:"primary;size(36)" json:"username"`
+	Amount   float64 `sorm:"decimal(20,8)" json:"amount"`
+	Deleted  bool    `sorm:"softdelete" json:"deleted"`
+}
+
+// PtrUser 以可为NULL的时间字段软删除的用户
+type PtrUser struct {
+	Username   string     `sorm:"primary;size(36)" json:"username"`
+	Amount     float64    `sorm:"decimal(20,8)" json:"amount"`
+	DeleteTime *time.Time `sorm:"softdelete" json:"deleteTime"`
+}
+
+// 测试软删除
+func Test_ObjectsSoftDelete(t *testing.T) {
+	db := testGetDB()
+	for _, c := range []struct {
+		tbl  string
+		st   interface{}
+		recs interface{}
+	}{
+		{"test_object_soft_time", &SoftUser{}, []SoftUser{{Username: "tester0"}, {Username: "tester1", Amount: 1}, {Username: "tester2", Amount: 2}}},
+		{"test_object_soft_flag", &FlagUser{}, []FlagUser{{Username: "tester0"}, {Username: "tester1", Amount: 1}, {Username: "tester2", Amount: 2}}},
+		{"test_object_soft_ptr", &PtrUser{}, []PtrUser{{Username: "tester0"}, {Username: "tester1", Amount: 1}, {Username: "tester2", Amount: 2}}},
+	} {
+		var (
+			tbl0 = c.tbl
+			m0   = db.Model(tbl0).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+			err  error
+		)
+		if err = m0.Drop(); err != nil {
+			t.Fatal(err)
+			return
+		}
+		if err = m0.Ensure(c.st); err != nil {
+			t.Fatal(err)
+			return
+		}
+		if err = m0.Objects().CreateMany(c.recs); err != nil {
+			t.Fatal(err)
+			return
+		}
+		count := func(obs orm.Objects, expect int) {
+			if n, _err := obs.Count(); _err != nil {
+				t.Fatal(_err)
+			} else if n != expect {
+				t.Fatalf(`"%v" %s expect %d record, get %d`, db, tbl0, expect, n)
+			}
+		}
+
+		// 删除只做标记
+		if err = m0.Objects().Filter(orm.M{"username": "tester0"}).Delete(); err != nil {
+			t.Fatal(err)
+			return
+		}
+		count(m0.Objects(), 2)
+		count(m0.Objects().Filter(orm.M{"amount$gte$": 0}), 2)
+		count(m0.Objects().WithDeleted(), 3)
+		count(m0.Objects().OnlyDeleted(), 1)
+		if err = m0.Objects().Filter(orm.M{"username": "tester0"}).DeleteOne(); err != orm.ErrMatchNone {
+			t.Fatalf(`"%v" %s delete again: %v`, db, tbl0, err)
+			return
+		}
+		var lis []map[string]interface{}
+		if err = m0.Objects().Sort("username").All(&lis); err != nil {
+			t.Fatal(err)
+			return
+		} else if len(lis) != 2 || lis[0]["username"] != "tester1" {
+			t.Fatalf(`"%v" %s all: %v`, db, tbl0, lis)
+			return
+		}
+		if s, _ := m0.Objects().Sum("amount"); s != 3 {
+			t.Fatalf(`"%v" %s sum: %v`, db, tbl0, s)
+			return
+		}
+
+		// 恢复
+		if err = m0.Objects().Filter(orm.M{"username": "tester0"}).Restore(); err != nil {
+			t.Fatal(err)
+			return
+		}
+		count(m0.Objects(), 3)
+		count(m0.Objects().OnlyDeleted(), 0)
+
+		// 新开的数据库对象, 未Create/Ensure时由ArgModel.Struct登记
+		db1, _err := orm.New(TestName, testConn())
+		if _err != nil {
+			t.Fatal(_err)
+			return
+		}
+		m1 := db1.ModelWith(tbl0, &orm.ArgModel{Struct: c.st})
+		if err = m1.Objects().Filter(orm.M{"username": "tester1"}).Delete(); err != nil {
+			t.Fatal(err)
+			return
+		}
+		count(m1.Objects(), 2)
+		count(m1.Objects().WithDeleted(), 3)
+		count(db1.Model(tbl0).Objects(), 2)
+		_ = db1.Close()
+
+		// 新开的数据库对象, 未登记时由ModelInfoTable读取, 不物理删除
+		db2, _err := orm.New(TestName, testConn())
+		if _err != nil {
+			t.Fatal(_err)
+			return
+		}
+		m2 := db2.Model(tbl0)
+		count(m2.Objects(), 2)
+		if err = m2.Objects().Filter(orm.M{"username": "tester2"}).Delete(); err != nil {
+			t.Fatal(err)
+			return
+		}
+		count(m2.Objects(), 1)
+		count(m2.Objects().WithDeleted(), 3)
+		_ = db2.Close()
+	}
+
+	// 未定义softdelete字段
+	m1 := db.Model("test_object_soft_none")
+	if err := m1.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err := m1.Ensure(&User{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err := m1.Objects().Restore(); err != orm.ErrSoftDeleteUndefined {
+		t.Fatalf(`"%v" restore: %v`, db, err)
+		return
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
+
+// VerUser 带版本号的用户
+type VerUser struct {
+	Username string  `sorm:"primary;size(36)" json:"username"`
+	Amount   float64 `sorm:"decimal(20,8)" json:"amount"`
+	Version  int     `sorm:"version" json:"version"`
+}
+
+// PtrVerUser 版本号可为NULL的用户
+type PtrVerUser struct {
+	Username string  `sorm:"primary;size(36)" json:"username"`
+	Amount   float64 `sorm:"decimal(20,8)" json:"amount"`
+	Version  *int    `sorm:"version" json:"version"`
+}
+
+// 测试乐观锁
+func Test_ObjectsVersion(t *testing.T) {
+	db := testGetDB()
+	var (
+		tbl0 = "test_object_version"
+		m0   = db.Model(tbl0).With(&orm.ArgModel{LogLevel: orm.LevelInfo})
+		err  error
+	)
+	if err = m0.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Ensure(&VerUser{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m0.Objects().Create(&VerUser{Username: "tester0"}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	get := func() (ret *VerUser) {
+		ret = new(VerUser)
+		if err = m0.Objects().Filter(orm.M{"username": "tester0"}).One(ret); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	// 两次读取后先后更新, 后者冲突
+	a, b := get(), get()
+	a.Amount, b.Amount = 1, 2
+	if err = m0.Objects().Filter(orm.M{"username": "tester0"}).UpdateOne(a); err != nil {
+		t.Fatal(err)
+		return
+	} else if a.Version != 1 {
+		t.Fatalf(`"%v" version %d`, db, a.Version)
+		return
+	}
+	if err = m0.Objects().Filter(orm.M{"username": "tester0"}).UpdateOne(b); err != orm.ErrVersionConflict {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrVersionConflict, err)
+		return
+	} else if b.Version != 0 {
+		t.Fatalf(`"%v" version %d`, db, b.Version)
+		return
+	}
+	if _u := get(); _u.Amount != 1 || _u.Version != 1 {
+		t.Fatalf(`"%v" get: %s`, db, orm.JSONMust(_u))
+		return
+	}
+
+	// 重新读取后可更新
+	b = get()
+	b.Amount = 2
+	if err = m0.Objects().Filter(orm.M{"username": "tester0"}).Update(b); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if _u := get(); _u.Amount != 2 || _u.Version != 2 {
+		t.Fatalf(`"%v" get: %s`, db, orm.JSONMust(_u))
+		return
+	}
+
+	// 新开的数据库对象同样校验版本
+	db1, err := orm.New(TestName, testConn())
+	if err != nil {
+		t.Fatal(err)
+		return
+	}
+	defer db1.Close()
+	a.Amount = 3
+	if err = db1.Model(tbl0).Objects().Filter(orm.M{"username": "tester0"}).UpdateOne(a); err != orm.ErrVersionConflict {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrVersionConflict, err)
+		return
+	}
+
+	// upsert: 插入时保留记录的版本号, 冲突更新时在原值上加1而非覆盖
+	c := get()
+	if err = m0.Objects().Upsert(&VerUser{Username: "tester0", Amount: 5}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if _u := get(); _u.Amount != 5 || _u.Version != c.Version+1 {
+		t.Fatalf(`"%v" get: %s`, db, orm.JSONMust(_u))
+		return
+	}
+	c.Amount = 6
+	if err = m0.Objects().Filter(orm.M{"username": "tester0"}).UpdateOne(c); err != orm.ErrVersionConflict {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrVersionConflict, err)
+		return
+	}
+	if err = m0.Objects().Upsert(&VerUser{Username: "tester1", Version: 7}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if _u := new(VerUser); m0.Objects().Filter(orm.M{"username": "tester1"}).One(_u) != nil || _u.Version != 7 {
+		t.Fatalf(`"%v" get: %s`, db, orm.JSONMust(_u))
+		return
+	}
+
+	// 指针版本号, NULL视为0
+	m1 := db.Model("test_object_version_ptr")
+	if err = m1.Drop(); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m1.Ensure(&PtrVerUser{}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	if err = m1.Objects().Create(&PtrVerUser{Username: "tester0"}); err != nil {
+		t.Fatal(err)
+		return
+	}
+	p0, p1 := new(PtrVerUser), new(PtrVerUser)
+	if err = m1.Objects().Filter(orm.M{"username": "tester0"}).One(p0); err != nil {
+		t.Fatal(err)
+		return
+	} else if p0.Version != nil {
+		t.Fatalf(`"%v" version %v`, db, *p0.Version)
+		return
+	}
+	*p1 = *p0
+	p0.Amount, p1.Amount = 1, 2
+	if err = m1.Objects().Filter(orm.M{"username": "tester0"}).UpdateOne(p0); err != nil {
+		t.Fatal(err)
+		return
+	} else if p0.Version == nil || *p0.Version != 1 {
+		t.Fatalf(`"%v" version %v`, db, p0.Version)
+		return
+	}
+	if err = m1.Objects().Filter(orm.M{"username": "tester0"}).UpdateOne(p1); err != orm.ErrVersionConflict {
+		t.Fatalf(`"%v" expect %v, get %v`, db, orm.ErrVersionConflict, err)
+		return
+	}
+
+	t.Logf(`"%v" PASS`, db)
+}
+
 // 压测更新
 func Benchmark_ObjectsUpdate(b *testing.B) {
 	db := testGetDB()
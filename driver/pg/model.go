@@ -3,7 +3,9 @@ package pg
 import (
 	"github.com/suboat/sorm"
 	"github.com/suboat/sorm/log"
+	"github.com/suboat/sorm/songo"
 
+	"context"
 	"database/sql"
 	"fmt"
 	"runtime"
@@ -25,15 +27,21 @@ type Model struct {
 	ContigUpdate       string
 	AutoIncrementField string
 	//
-	log orm.Logger
+	plan *orm.Plan // 非nil时Ensure只记录语句, 不执行
+	log  orm.Logger
+	ctx  context.Context // nil: context.Background()
 }
 
 // SQLIndex is index info of SQL-like database
 type SQLIndex struct {
-	Key    []string
-	Unique bool
-	Kind   string // field type
-	Method string // 索引方法
+	Key     []string
+	Unique  bool
+	Kind    string   // field type
+	Method  string   // 索引方法
+	Name    string   // 索引名, 为空时由表名及字段生成
+	Expr    bool     // Key为表达式
+	Where   string   // 部分索引的条件
+	Include []string // 覆盖索引的附加字段
 }
 
 // Copy 全拷贝
@@ -44,6 +52,7 @@ func (m *Model) Copy() (r *Model) {
 	r.ContigInsert = m.ContigInsert
 	r.ContigUpdate = m.ContigUpdate
 	r.AutoIncrementField = m.AutoIncrementField
+	r.ctx = m.ctx
 	if m.log != nil {
 		if _log, ok := m.log.(*log.Logger); ok {
 			r.log = _log.Copy()
@@ -58,6 +67,24 @@ func (m *Model) Copy() (r *Model) {
 }
 
 // table name
+// info 所属数据库登记的表附加信息, 未登记时为nil
+func (m *Model) info() *orm.ModelInfo {
+	return m.DatabaseSQL.models.Get(m.TableName)
+}
+
+// infoLoad 表未登记时从orm.ModelInfoTable读取附加信息, 如重启后未经Ensure的表
+func (m *Model) infoLoad() (err error) {
+	if m.DatabaseSQL.models.Get(m.TableName) != nil {
+		return
+	}
+	var info *orm.ModelInfo
+	if info, err = orm.ModelInfoLoad(m.DatabaseSQL, m.TableName); err != nil {
+		return
+	}
+	m.DatabaseSQL.models.Init(m.TableName, info)
+	return
+}
+
 func (m *Model) String() (s string) {
 	s = m.TableName
 	return
@@ -72,6 +99,7 @@ func (m *Model) Objects() orm.Objects {
 func (m *Model) ObjectsWith(arg *orm.ArgObjects) orm.Objects {
 	ob := new(Objects)
 	ob.Model = m
+	ob.ctx = m.ctx
 	ob.count = -1
 	ob.nums = -1
 	if m.log != nil {
@@ -104,7 +132,8 @@ func (m *Model) GetTable() (ret string) {
 
 // Drop table
 func (m *Model) Drop() (err error) {
-	m.Result, err = m.DatabaseSQL.DB.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, m.TableName))
+	m.Result, err = m.DatabaseSQL.DB.ExecContext(m.getContext(), fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, m.TableName))
+	err = orm.ContextErr(m.ctx, err)
 	return
 }
 
@@ -116,6 +145,8 @@ func (m *Model) ContigParse(st interface{}) (err error) {
 	var (
 		columns      []string
 		values       []string
+		upColumns    []string // created字段不更新
+		upValues     []string
 		fieldInfoLis []*orm.FieldInfo
 	)
 	if fieldInfoLis, err = orm.StructModelInfo(st); err != nil {
@@ -125,10 +156,19 @@ func (m *Model) ContigParse(st interface{}) (err error) {
 		if f.Name != m.AutoIncrementField {
 			columns = append(columns, "\""+f.Name+"\"")
 			values = append(values, ":"+f.Name)
+			if !f.Created {
+				upColumns = append(upColumns, "\""+f.Name+"\"")
+				if f.Version {
+					upValues = append(upValues, "COALESCE(\""+f.Name+"\", 0)+1")
+				} else {
+					upValues = append(upValues, ":"+f.Name)
+				}
+			}
 		}
 	}
 	m.ContigInsert = fmt.Sprintf(`"%s" (%s) VALUES (%s)`, m.TableName, strings.Join(columns, ", "), strings.Join(values, ", "))
-	m.ContigUpdate = fmt.Sprintf(`"%s" SET (%s) = (%s)`, m.TableName, strings.Join(columns, ", "), strings.Join(values, ", "))
+	m.ContigUpdate = fmt.Sprintf(`"%s" SET (%s) = (%s)`, m.TableName, strings.Join(upColumns, ", "), strings.Join(upValues, ", "))
+	m.DatabaseSQL.models.Register(m.TableName, fieldInfoLis)
 	return
 }
 
@@ -137,6 +177,7 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 	var (
 		fieldInfoLis []*orm.FieldInfo
 		colCmdLis    = []string{}
+		commentLis   []string
 		primaryKey   = ""
 		primaryCmd   = ""
 		colCmd       = ""
@@ -239,6 +280,13 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		if f.DefaultVal == nil {
 			cmdDef = "NULL"
 		}
+		cmdDef = orm.ColumnDefine(f, cmdDef)
+		if m.plan != nil {
+			m.plan.ColumnExpect(f, strings.TrimPrefix(cmdAdd, fmt.Sprintf(`"%s" `, f.Name)), cmdDef)
+		}
+		if len(f.Check) > 0 {
+			cmdDef += fmt.Sprintf(` CHECK (%s)`, f.Check)
+		}
 		if tableExist == 1 {
 			if _, ok := fieldExist[f.Name]; ok {
 				// not modify
@@ -249,6 +297,11 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 			colCmd = fmt.Sprintf(`%s %s`, cmdAdd, cmdDef)
 		}
 		colCmdLis = append(colCmdLis, colCmd)
+		// comment: 只在新建字段时写入
+		if len(f.Comment) > 0 {
+			commentLis = append(commentLis, fmt.Sprintf(`COMMENT ON COLUMN "%s"."%s" IS '%s';`,
+				m.TableName, f.Name, strings.Replace(f.Comment, "'", "''", -1)))
+		}
 
 		/////////////
 		// old col
@@ -324,7 +377,7 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 
 	// exec
 	if len(colCmdLis) > 0 {
-		if m.Result, err = m.DatabaseSQL.DB.Exec(colCmd); err != nil {
+		if err = m.ensureExec(colCmd); err != nil {
 			m.log.Errorf(`[ensure-column] 
 %s err: %v`, colCmd, err)
 			return
@@ -333,6 +386,12 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		m.log.Infof(`[ensure-column] 
 %s`, colCmd)
 	}
+	for _, cmd := range commentLis {
+		if err = m.ensureExec(cmd); err != nil {
+			m.log.Errorf(`[ensure-column] %s err: %v`, cmd, err)
+			return
+		}
+	}
 
 	return
 }
@@ -358,8 +417,15 @@ END;$$;`, pkey, fmt.Sprintf(
 		`ALTER TABLE "%s" ADD CONSTRAINT "%s" PRIMARY KEY(%s);`,
 		m.TableName, pkey, strings.Join(keys, ", ")))
 
+	// 预览: 新表的主键已在CREATE TABLE中
+	if m.plan != nil {
+		if m.plan.IndexExpect(pkey, key, true) || !m.plan.TableExist {
+			return
+		}
+	}
+
 	// run
-	if m.Result, err = m.DatabaseSQL.DB.Exec(cmd); err != nil {
+	if err = m.ensureExec(cmd); err != nil {
 		m.log.Errorf(`[ensure-primary] "%s" err: %v`, cmd, err)
 		return
 	}
@@ -372,6 +438,10 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 		indexLis       []*SQLIndex // index and unique
 		indexMethod    = ""        // both index and unique method
 		indexFiledType = ""        // field type
+		indexName      = ""        // 指定的索引名
+		indexWhere     = ""        // 部分索引的条件
+		indexInclude   []string    // 覆盖索引的附加字段
+		indexUnique    = false     // Key及Expr为唯一索引
 	)
 
 	// filed type
@@ -386,18 +456,49 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 		}
 	}
 
+	// name, unique, include, where
+	if s, ok := indexMap["Name"].(string); ok {
+		indexName = s
+	}
+	if v, ok := indexMap["Unique"].(bool); ok {
+		indexUnique = v
+	}
+	if v, ok := indexMap["Include"].([]string); ok {
+		indexInclude = v
+	}
+	switch w := indexMap["Where"].(type) {
+	case orm.M:
+		indexWhere, err = orm.IndexWhere(driverName, w)
+	case map[string]interface{}:
+		indexWhere, err = orm.IndexWhere(driverName, w)
+	}
+	if err != nil {
+		return
+	}
+
 	// keys
 	if i, ok := indexMap["Key"]; ok {
 		if v, ok := i.([]string); ok {
 			indexLis = append(indexLis, &SQLIndex{
 				Key:    v,
-				Unique: false,
+				Unique: indexUnique,
 				Kind:   indexFiledType,
 				Method: indexMethod,
 			})
 		}
 	}
 
+	// expression
+	if v, ok := indexMap["Expr"].([]string); ok {
+		indexLis = append(indexLis, &SQLIndex{
+			Key:    v,
+			Unique: indexUnique,
+			Kind:   indexFiledType,
+			Method: indexMethod,
+			Expr:   true,
+		})
+	}
+
 	// unique
 	if i, ok := indexMap["Unique"]; ok {
 		if v, ok := i.([]string); ok {
@@ -409,6 +510,12 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 			})
 		}
 	}
+	for _, index := range indexLis {
+		index.Where, index.Include = indexWhere, indexInclude
+	}
+	if len(indexName) > 0 && len(indexLis) == 1 {
+		indexLis[0].Name = indexName
+	}
 
 	//
 	for _, index := range indexLis {
@@ -420,16 +527,39 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 			}
 			var keys []string
 			for _, k := range index.Key {
+				if index.Expr {
+					keys = append(keys, "("+k+")")
+					continue
+				}
+				k, desc := orm.IndexKey(k)
 				k = strings.ToLower(k)
-				keys = append(keys, "\""+k+"\"")
+				if desc {
+					keys = append(keys, "\""+k+"\""+" DESC")
+				} else {
+					keys = append(keys, "\""+k+"\"")
+				}
 			}
 			//if len(index.Method) == 0 {
 			//	index.Method = "USING btree" // 默认 btree
 			//}
-			indexCmd := fmt.Sprintf("CREATE %s IF NOT EXISTS \"%s_%s\" ON \"%s\" %s (%s);", indexType,
-				m.TableName, strings.Join(index.Key, "_"), m.TableName, index.Method, strings.Join(keys, ", "))
+			indexKey := index.Name
+			if len(indexKey) == 0 {
+				indexKey = orm.IndexName(m.TableName, index.Key)
+			}
+			indexCmd := fmt.Sprintf("CREATE %s IF NOT EXISTS \"%s\" ON \"%s\" %s (%s)", indexType,
+				indexKey, m.TableName, index.Method, strings.Join(keys, ", "))
+			if len(index.Include) > 0 {
+				indexCmd += fmt.Sprintf(` INCLUDE ("%s")`, strings.Join(index.Include, `", "`))
+			}
+			if len(index.Where) > 0 {
+				indexCmd += " WHERE " + index.Where
+			}
+			indexCmd += ";"
+			if m.plan != nil && m.plan.IndexExpect(indexKey, index.Key, index.Unique) {
+				continue
+			}
 
-			if m.Result, err = m.DatabaseSQL.DB.Exec(indexCmd); err != nil {
+			if err = m.ensureExec(indexCmd); err != nil {
 				m.log.Errorf(`[ensure-index] %s, type: %s, err: %v`, indexCmd, indexType, err)
 				return
 
@@ -441,8 +571,68 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 	return
 }
 
+// EnsureForeignKey 确认外键
+func (m *Model) EnsureForeignKey(f *orm.FieldInfo) (err error) {
+	var (
+		fkey = fmt.Sprintf(`%s_%s_fkey`, m.TableName, f.Name)
+		cmd  string
+	)
+	cmd = fmt.Sprintf(`DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = '%s') THEN
+        %s
+    END IF;
+END;$$;`, fkey, fmt.Sprintf(
+		`ALTER TABLE "%s" ADD CONSTRAINT "%s" FOREIGN KEY ("%s") REFERENCES "%s" ("%s")%s;`,
+		m.TableName, fkey, f.Name, f.Fk.Table, f.Fk.Column, f.Fk.Action()))
+	if m.plan != nil && m.plan.FkExpect(fkey, f) {
+		return
+	}
+
+	// run
+	if err = m.ensureExec(cmd); err != nil {
+		m.log.Errorf(`[ensure-fk] "%s" err: %v`, cmd, err)
+		return
+	}
+	return
+}
+
+// EnsureTextIndex 确认全文索引: 按songo.TextConfig分词的gin表达式索引, 与$text$的查询一致
+func (m *Model) EnsureTextIndex(f *orm.FieldInfo) (err error) {
+	var (
+		indexKey = fmt.Sprintf(`%s_%s_text`, m.TableName, f.Name)
+		cmd      = fmt.Sprintf(`CREATE INDEX IF NOT EXISTS "%s" ON "%s" USING gin (to_tsvector('%s', "%s"));`,
+			indexKey, m.TableName, songo.TextConfig, f.Name)
+	)
+	if m.plan != nil && m.plan.IndexExpect(indexKey, []string{f.Name}, false) {
+		return
+	}
+
+	// run
+	if err = m.ensureExec(cmd); err != nil {
+		m.log.Errorf(`[ensure-text] "%s" err: %v`, cmd, err)
+		return
+	}
+	m.log.Debug(cmd)
+	return
+}
+
 // Ensure is sync table with struct
 func (m *Model) Ensure(st interface{}) (err error) {
+	if err = m.ensure(st); err != nil {
+		return
+	}
+	// contig parse and cache
+	m.ContigInsert = ""
+	m.ContigUpdate = ""
+	if err = m.ContigParse(st); err != nil {
+		return
+	}
+	return orm.ModelInfoSave(m.DatabaseSQL, m.TableName, m.info())
+}
+
+// ensure 同步字段及索引
+func (m *Model) ensure(st interface{}) (err error) {
 	// syncDB
 	if err = m.EnsureColumn(st); err != nil {
 		return
@@ -480,12 +670,253 @@ func (m *Model) Ensure(st interface{}) (err error) {
 			return
 		}
 	}
-	// contig parse and cache
-	m.ContigInsert = ""
-	m.ContigUpdate = ""
-	if err = m.ContigParse(st); err != nil {
+	// text index
+	for _, f := range fieldInfoLis {
+		if !f.IndexText {
+			continue
+		}
+		if err = m.EnsureTextIndex(f); err != nil {
+			return
+		}
+	}
+	// foreign key
+	for _, f := range fieldInfoLis {
+		if f.Fk == nil {
+			continue
+		}
+		if err = m.EnsureForeignKey(f); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ensureExec 执行Ensure的语句, 预览时只记录
+func (m *Model) ensureExec(cmd string) (err error) {
+	if m.plan != nil {
+		m.plan.DDL = append(m.plan.DDL, cmd)
 		return
 	}
+	m.Result, err = m.DatabaseSQL.DB.Exec(cmd)
+	return
+}
+
+// Plan 对比struct与库中的表结构, 返回Ensure将执行的语句, 不执行
+func (m *Model) Plan(st interface{}) (ret *orm.Plan, err error) {
+	var (
+		mp   = m.Copy()
+		info *orm.TableInfo
+	)
+	ret = orm.NewPlan(m.TableName)
+	if info, err = m.Inspect(); err == orm.ErrSyncTableNotExist {
+		err = nil
+	} else if err != nil {
+		return
+	} else {
+		ret.TableExist = true
+		ret.TableActual(info)
+	}
+	// 按Ensure的流程记录语句
+	mp.plan = ret
+	if err = mp.ensure(st); err != nil {
+		return
+	}
+	ret.Diff()
+	return
+}
+
+// Inspect 读取库中表的字段及索引
+func (m *Model) Inspect() (ret *orm.TableInfo, err error) {
+	var (
+		tableExist = 0
+		primary    = make(map[string]bool)
+	)
+	if err = m.DatabaseSQL.DB.Get(&tableExist,
+		`SELECT count(*) FROM information_schema.tables WHERE table_name=$1`, m.TableName); err != nil {
+		m.log.Errorf("[inspect] check table exist err: %v", err)
+		return
+	} else if tableExist == 0 {
+		err = orm.ErrSyncTableNotExist
+		return
+	}
+	ret = &orm.TableInfo{Name: m.TableName}
+	// indexes
+	var indexLis []struct {
+		Name    string `db:"name"`
+		Unique  bool   `db:"is_unique"`
+		Primary bool   `db:"is_primary"`
+		Column  string `db:"column_name"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&indexLis, `SELECT i.relname AS name, ix.indisunique AS is_unique,
+ix.indisprimary AS is_primary, COALESCE(a.attname, pg_get_indexdef(ix.indexrelid, k.ord::int, true)) AS column_name
+FROM pg_index ix
+JOIN pg_class t ON t.oid = ix.indrelid
+JOIN pg_class i ON i.oid = ix.indexrelid
+CROSS JOIN LATERAL unnest(ix.indkey) WITH ORDINALITY AS k(attnum, ord)
+LEFT JOIN pg_attribute a ON a.attrelid = t.oid AND a.attnum = k.attnum
+WHERE t.relname = $1 ORDER BY i.relname, k.ord`, m.TableName); err != nil {
+		m.log.Errorf("[inspect] select index err: %v", err)
+		return
+	}
+	for i := 0; i < len(indexLis); {
+		idx := &orm.IndexInfo{Name: indexLis[i].Name, Unique: indexLis[i].Unique, Primary: indexLis[i].Primary}
+		for ; i < len(indexLis) && indexLis[i].Name == idx.Name; i++ {
+			col := indexLis[i].Column
+			if strings.HasPrefix(col, "to_tsvector(") {
+				// 全文索引的表达式, 如to_tsvector('simple'::regconfig, title)
+				idx.Text = true
+				col = strings.Trim(col[strings.LastIndex(col, ",")+1:len(col)-1], ` "`)
+			}
+			idx.Key = append(idx.Key, col)
+			if idx.Primary {
+				primary[indexLis[i].Column] = true
+			}
+		}
+		ret.Indexes = append(ret.Indexes, idx)
+	}
+	// columns
+	var columnLis []struct {
+		Name      string         `db:"column_name"`
+		Kind      string         `db:"data_type"`
+		Size      sql.NullInt64  `db:"character_maximum_length"`
+		Precision sql.NullInt64  `db:"numeric_precision"`
+		Scale     sql.NullInt64  `db:"numeric_scale"`
+		Null      string         `db:"is_nullable"`
+		Default   sql.NullString `db:"column_default"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&columnLis, `SELECT column_name, data_type, character_maximum_length,
+numeric_precision, numeric_scale, is_nullable, column_default FROM information_schema.columns
+WHERE table_name=$1 ORDER BY ordinal_position`, m.TableName); err != nil {
+		m.log.Errorf("[inspect] select column err: %v", err)
+		return
+	}
+	for _, c := range columnLis {
+		kind := c.Kind
+		if c.Size.Valid {
+			kind = fmt.Sprintf("%s(%d)", kind, c.Size.Int64)
+		} else if kind == "numeric" && c.Precision.Valid {
+			kind = fmt.Sprintf("%s(%d,%d)", kind, c.Precision.Int64, c.Scale.Int64)
+		}
+		ret.Columns = append(ret.Columns, &orm.ColumnInfo{
+			Name:    c.Name,
+			Kind:    kind,
+			Null:    c.Null == "YES",
+			Default: c.Default.String,
+			Primary: primary[c.Name],
+			Serial:  strings.HasPrefix(c.Default.String, "nextval("),
+		})
+	}
+	// foreign keys
+	var fkLis []struct {
+		Name      string `db:"name"`
+		Column    string `db:"column_name"`
+		RefTable  string `db:"ref_table"`
+		RefColumn string `db:"ref_column"`
+		OnDelete  string `db:"delete_rule"`
+		OnUpdate  string `db:"update_rule"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&fkLis, `SELECT c.conname AS name, a.attname AS column_name,
+rt.relname AS ref_table, ra.attname AS ref_column,
+CASE c.confdeltype WHEN 'c' THEN 'CASCADE' WHEN 'r' THEN 'RESTRICT' WHEN 'n' THEN 'SET NULL'
+WHEN 'd' THEN 'SET DEFAULT' ELSE 'NO ACTION' END AS delete_rule,
+CASE c.confupdtype WHEN 'c' THEN 'CASCADE' WHEN 'r' THEN 'RESTRICT' WHEN 'n' THEN 'SET NULL'
+WHEN 'd' THEN 'SET DEFAULT' ELSE 'NO ACTION' END AS update_rule FROM pg_constraint c
+JOIN pg_class t ON t.oid = c.conrelid
+JOIN pg_class rt ON rt.oid = c.confrelid
+JOIN pg_attribute a ON a.attrelid = c.conrelid AND a.attnum = c.conkey[1]
+JOIN pg_attribute ra ON ra.attrelid = c.confrelid AND ra.attnum = c.confkey[1]
+WHERE c.contype = 'f' AND t.relname = $1 ORDER BY c.conname`, m.TableName); err != nil {
+		m.log.Errorf("[inspect] select fk err: %v", err)
+		return
+	}
+	for _, fk := range fkLis {
+		ret.Fks = append(ret.Fks, &orm.FkInfo{Name: fk.Name, Column: fk.Column, RefTable: fk.RefTable,
+			RefColumn: fk.RefColumn, OnDelete: fk.OnDelete, OnUpdate: fk.OnUpdate})
+	}
+	return
+}
+
+// EnsureWith 同Ensure, 并按参数修改/删除/重命名已有的字段及索引
+func (m *Model) EnsureWith(st interface{}, opt *orm.ArgEnsure) (err error) {
+	var (
+		p *orm.Plan
+	)
+	if opt == nil {
+		return m.Ensure(st)
+	}
+	if p, err = m.Plan(st); err != nil {
+		return
+	}
+	// rename
+	if opt.Rename && len(p.ColumnRenamed) > 0 {
+		for _, c := range p.ColumnRenamed {
+			if err = m.ensureWithExec(fmt.Sprintf(`ALTER TABLE "%s" RENAME COLUMN "%s" TO "%s";`,
+				m.TableName, c.Was, c.Name)); err != nil {
+				return
+			}
+		}
+		if p, err = m.Plan(st); err != nil {
+			return
+		}
+	}
+	// drop
+	if opt.DropIndex {
+		for _, idx := range p.IndexExtra {
+			if err = m.ensureWithExec(fmt.Sprintf(`DROP INDEX IF EXISTS "%s";`, idx.Name)); err != nil {
+				return
+			}
+		}
+	}
+	if opt.DropColumn {
+		for _, c := range p.ColumnExtra {
+			if err = m.ensureWithExec(fmt.Sprintf(`ALTER TABLE "%s" DROP COLUMN "%s";`,
+				m.TableName, c.Name)); err != nil {
+				return
+			}
+		}
+	}
+	// alter
+	if opt.AlterColumn {
+		for _, c := range p.ColumnChanged {
+			var cmdLis []string
+			for _, d := range c.Diff {
+				switch d {
+				case orm.PlanDiffKind:
+					kind := c.Kind
+					switch kind {
+					case "serial":
+						kind = "integer"
+					case "bigserial":
+						kind = "bigint"
+					}
+					cmdLis = append(cmdLis, fmt.Sprintf(`ALTER COLUMN "%s" TYPE %s USING "%s"::%s`,
+						c.Name, kind, c.Name, kind))
+				case orm.PlanDiffNull:
+					if c.Null {
+						cmdLis = append(cmdLis, fmt.Sprintf(`ALTER COLUMN "%s" DROP NOT NULL`, c.Name))
+					} else {
+						cmdLis = append(cmdLis, fmt.Sprintf(`ALTER COLUMN "%s" SET NOT NULL`, c.Name))
+					}
+				case orm.PlanDiffDefault:
+					cmdLis = append(cmdLis, fmt.Sprintf(`ALTER COLUMN "%s" SET DEFAULT %s`, c.Name, c.DefaultSQL))
+				}
+			}
+			if err = m.ensureWithExec(fmt.Sprintf(`ALTER TABLE "%s" %s;`,
+				m.TableName, strings.Join(cmdLis, ", "))); err != nil {
+				return
+			}
+		}
+	}
+	return m.Ensure(st)
+}
+
+// ensureWithExec 执行EnsureWith的语句
+func (m *Model) ensureWithExec(cmd string) (err error) {
+	if err = m.ensureExec(cmd); err != nil {
+		m.log.Errorf(`[ensure-with] %s err: %v`, cmd, err)
+		return
+	}
+	m.log.Infof(`[ensure-with] %s`, cmd)
 	return
 }
 
@@ -494,14 +925,39 @@ func (m *Model) Begin() (orm.Trans, error) {
 	return m.BeginWith(nil)
 }
 
+// BeginNested 在parent中以保存点开启子事务
+func (m *Model) BeginNested(parent orm.Trans) (ret orm.Trans, err error) {
+	if parent == nil {
+		return m.BeginWith(nil)
+	}
+	t, ok := parent.(*Trans)
+	if !ok || t == nil || t.db != m.DatabaseSQL {
+		return nil, orm.ErrTransInvalid
+	}
+	var c *Trans
+	if c, err = t.nested(); err != nil {
+		return
+	}
+	return c, nil
+}
+
 // BeginWith 事务
 func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 	var (
-		t = &Trans{}
+		t = &Trans{db: m.DatabaseSQL}
 	)
-	if t.Tx, err = m.DatabaseSQL.DB.Beginx(); err != nil {
-		return t, err
+	if arg != nil && len(arg.Lock) > 0 {
+		// 驱动不支持的参数, 不静默忽略
+		return t, orm.ErrTransOptionNotSupport
 	}
+	var opts *sql.TxOptions
+	if arg != nil && arg.ReadOnly {
+		opts = &sql.TxOptions{ReadOnly: true}
+	}
+	if t.Tx, err = m.DatabaseSQL.DB.BeginTxx(m.getContext(), opts); err != nil {
+		return t, orm.ContextErr(m.ctx, err)
+	}
+	t.ctx = m.ctx
 
 	// 记录调用处
 	if pc, file, line, ok := runtime.Caller(2); ok {
@@ -552,8 +1008,28 @@ func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 		}
 	}
 
+	// 等待安全的快照
+	if arg.Deferrable {
+		if _, err = t.Exec("set transaction deferrable"); err != nil {
+			_ = t.Rollback()
+			return
+		}
+	}
+
+	// 单条语句超时
+	if arg.StatementTimeout > 0 {
+		if _, err = t.Exec(fmt.Sprintf("SET LOCAL statement_timeout = %d", transMillisecond(arg.StatementTimeout))); err != nil {
+			_ = t.Rollback()
+			return
+		}
+	}
+
 	// 超时回滚
-	t.timer = time.NewTimer(orm.TransTimeout)
+	timeout := orm.TransTimeout
+	if arg.Timeout > 0 {
+		timeout = arg.Timeout
+	}
+	t.timer = time.NewTimer(timeout)
 	go func() {
 		defer func() {
 			if _err := recover(); _err != nil {
@@ -563,10 +1039,12 @@ func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 
 		<-t.timer.C
 		// timeout
-		if !t.isFinish {
+		if t.finish(transExpired) {
+			// 只有CAS成功的一方写入错误, 之后Error/Commit均返回超时
+			t.errSet(orm.ErrTransTimeout)
 			// log sql history
 			m.log.Errorf("[trans-timeout] tableName=%s sql=%s", m.TableName, t.debugReport())
-			_ = m.Rollback(t)
+			_ = t.rollback(orm.ErrTransTimeout)
 		}
 	}()
 
@@ -607,16 +1085,34 @@ func (m *Model) AutoTrans(t orm.Trans) (err error) {
 
 // Exec 执行语句
 func (m *Model) Exec(query string, args ...interface{}) (result orm.Result, err error) {
-	result, err = m.DatabaseSQL.DB.Exec(query, args...)
+	result, err = m.DatabaseSQL.DB.ExecContext(m.getContext(), query, args...)
+	err = orm.ContextErr(m.ctx, err)
 	return
 }
 
 // Exec 执行语句
 func (m *Model) Select(dest interface{}, query string, args ...interface{}) (err error) {
-	err = m.DatabaseSQL.DB.Select(dest, query, args...)
+	err = m.DatabaseSQL.DB.SelectContext(m.getContext(), dest, query, args...)
+	err = orm.ContextErr(m.ctx, err)
 	return
 }
 
+// WithContext 返回绑定上下文的model
+func (m *Model) WithContext(ctx context.Context) (ret orm.Model) {
+	r := m.Copy()
+	r.VirtualSQL = m.VirtualSQL
+	r.ctx = ctx
+	return r
+}
+
+// getContext 取上下文
+func (m *Model) getContext() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
 // With 设置日志级别
 func (m *Model) With(arg *orm.ArgModel) (ret orm.Model) {
 	r := m.Copy()
@@ -630,3 +1126,11 @@ func (m *Model) With(arg *orm.ArgModel) (ret orm.Model) {
 	}
 	return r
 }
+
+// transMillisecond 超时换算为毫秒, 不足1毫秒按1毫秒
+func transMillisecond(d time.Duration) int64 {
+	if ms := int64(d / time.Millisecond); ms > 0 {
+		return ms
+	}
+	return 1
+}
@@ -1,10 +1,12 @@
 package pg
 
 import (
+	"github.com/jmoiron/sqlx"
 	"github.com/suboat/sorm"
 	"github.com/suboat/sorm/log"
 	"github.com/suboat/sorm/songo"
 
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
@@ -17,6 +19,7 @@ type Objects struct {
 	Model  *Model
 	Result orm.Result
 	log    orm.Logger
+	ctx    context.Context // nil: context.Background()
 
 	// query and meta
 	skip  int //
@@ -27,13 +30,25 @@ type Objects struct {
 	// filter
 	queryM orm.M    // store filter regular
 	sorts  []string // sort
+	fields []string // select fields
+	omit   []string // omit fields
 	group  []string // group
+	scope  int      // soft delete scope: orm.SoftDelete*
+
+	// cursor: keyset pagination
+	cursor     *orm.Cursor // cursor from After
+	cursorM    orm.M       // cursor filter, only for All/Iter, not in Count and Meta.Key
+	cursorErr  error       // invalid cursor, returned when query
+	cursorNext string      // cursor of next page
+	cursorPrev string      // cursor of prev page
 
 	// cache: Query
 	cacheQueryClean  bool          // if true, update cacheQuery* mandatorily next time
 	cacheQueryExist  bool          // if true, query cache exist
 	cacheQueryWhere  string        // sql contig after "where"
 	cacheQueryValues []interface{} // cache query value list
+	cacheAllWhere    string        // where of All/Iter, with cursor filter
+	cacheAllValues   []interface{} // value list of cacheAllWhere
 	cacheQueryLimit  string        // sql contig from "limit"
 	cacheQueryOrder  string        // sql contig from "order by"
 	cacheQueryGroup  string        // sql contig from "group by"
@@ -41,10 +56,11 @@ type Objects struct {
 
 //
 type execer interface {
-	Exec(query string, args ...interface{}) (sql.Result, error)
-	NamedExec(query string, arg interface{}) (sql.Result, error)
-	Get(dest interface{}, query string, args ...interface{}) error
-	Select(dest interface{}, query string, args ...interface{}) error
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	NamedExecContext(ctx context.Context, query string, arg interface{}) (sql.Result, error)
+	GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error
+	QueryxContext(ctx context.Context, query string, args ...interface{}) (*sqlx.Rows, error)
 }
 
 // GetResult 取结果
@@ -89,7 +105,9 @@ func (ob *Objects) Skip(n int) orm.Objects {
 // Sort 用字段排序
 func (ob *Objects) Sort(fields ...string) orm.Objects {
 	for _, s := range fields {
-		s = songo.SafeField(s)
+		if strings.TrimLeft(s, "+-") != songo.TagValText {
+			s = songo.SafeField(s)
+		}
 		order := `ASC`
 		if len(s) == 0 {
 			continue
@@ -104,10 +122,14 @@ func (ob *Objects) Sort(fields ...string) orm.Objects {
 		if len(s) == 0 {
 			continue
 		}
+		field := PubFieldWrap(s)
+		if s == songo.TagValText {
+			field = s // 按相关度排序, 生成语句时替换为相关度表达式
+		}
 		if len(ob.cacheQueryOrder) == 0 {
-			ob.cacheQueryOrder = fmt.Sprintf(`ORDER BY %s %s`, PubFieldWrap(s), order)
+			ob.cacheQueryOrder = fmt.Sprintf(`ORDER BY %s %s`, field, order)
 		} else {
-			ob.cacheQueryOrder += fmt.Sprintf(`,%s %s`, PubFieldWrap(s), order)
+			ob.cacheQueryOrder += fmt.Sprintf(`,%s %s`, field, order)
 		}
 	}
 	ob.sorts = append(ob.sorts, fields...)
@@ -128,6 +150,134 @@ func (ob *Objects) Group(fields ...string) orm.Objects {
 	return ob
 }
 
+// Fields 只取指定字段
+func (ob *Objects) Fields(fields ...string) orm.Objects {
+	for _, s := range fields {
+		if s = songo.SafeField(s); len(s) > 0 {
+			ob.fields = append(ob.fields, s)
+		}
+	}
+	return ob
+}
+
+// Omit 排除字段
+func (ob *Objects) Omit(fields ...string) orm.Objects {
+	for _, s := range fields {
+		if s = songo.SafeField(s); len(s) > 0 {
+			ob.omit = append(ob.omit, s)
+		}
+	}
+	return ob
+}
+
+// After 游标分页, 需在Filter及Sort之后调用
+func (ob *Objects) After(token string) orm.Objects {
+	if len(token) == 0 {
+		return ob
+	}
+	var (
+		c   *orm.Cursor
+		m   orm.M
+		err error
+	)
+	if c, err = orm.CursorDecode(token); err == nil {
+		m, err = orm.CursorFilter(ob.sorts, c)
+	}
+	if err != nil {
+		ob.cursorErr = err
+		return ob
+	}
+	ob.cursor, ob.cursorM = c, m
+	if c.Prev {
+		// 向前翻页: 反向排序读取, 读取后再翻转
+		sorts := ob.sorts
+		ob.sorts, ob.cacheQueryOrder = nil, ""
+		ob.Sort(orm.CursorSortsReverse(sorts)...)
+		ob.sorts = sorts
+	}
+	return ob
+}
+
+// WithDeleted 包含已软删除的记录
+func (ob *Objects) WithDeleted() orm.Objects {
+	return ob.scopeSet(orm.SoftDeleteWith)
+}
+
+// OnlyDeleted 只取已软删除的记录
+func (ob *Objects) OnlyDeleted() orm.Objects {
+	return ob.scopeSet(orm.SoftDeleteOnly)
+}
+
+// scopeSet 设置软删除的搜索范围
+func (ob *Objects) scopeSet(scope int) orm.Objects {
+	ob.scope = scope
+	ob.cacheQueryClean = true
+	ob.count = -1
+	return ob
+}
+
+// query 搜索条件, 按搜索范围追加软删除条件
+func (ob *Objects) query() orm.M {
+	return orm.SoftDeleteQuery(ob.Model.info(), ob.queryM, ob.scope)
+}
+
+// orderSQL 排序语句及参数, 按相关度排序时将$text$替换为相关度表达式, 其参数追加在where的参数之后
+func (ob *Objects) orderSQL(where []interface{}) (order string, args []interface{}) {
+	order, args = ob.cacheQueryOrder, where
+	if !strings.Contains(order, songo.TagValText) {
+		return
+	}
+	k, v, _ := songo.TextField(ob.query())
+	args = append(append([]interface{}{}, where...), v)
+	order = strings.Replace(order, songo.TagValText, fmt.Sprintf(`ts_rank(to_tsvector('%s', "%s"), plainto_tsquery('%s', $%d))`,
+		songo.TextConfig, k, songo.TextConfig, len(args)), -1)
+	return
+}
+
+// selectFields 按Fields/Omit取查询字段, 未设置时返回def
+func (ob *Objects) selectFields(result interface{}, def string) string {
+	lis := orm.FieldsSelect(ob.fields, ob.omit, result)
+	if len(lis) == 0 {
+		return def
+	}
+	return `"` + strings.Join(lis, `","`) + `"`
+}
+
+// selectDo 查询至result, result为map时逐行读取
+func (ob *Objects) selectDo(ex execer, one bool, result interface{}, sqlCmd string, args ...interface{}) (err error) {
+	if !orm.IsMapDest(result) {
+		if one {
+			return ex.GetContext(ob.getContext(), result, sqlCmd, args...)
+		}
+		return ex.SelectContext(ob.getContext(), result, sqlCmd, args...)
+	}
+	var rows *sqlx.Rows
+	if rows, err = ex.QueryxContext(ob.getContext(), sqlCmd, args...); err != nil {
+		return
+	}
+	defer rows.Close()
+	_, err = orm.ScanMaps(rows, result)
+	return
+}
+
+// hook 调用记录上的钩子, 在事务中出错时标记事务回滚
+func (ob *Objects) hook(ex interface{}, name string, record interface{}) (err error) {
+	if err = orm.HookCall(name, record); err != nil {
+		ob.log.Warnf(`[sql-hook] %s err: %v`, name, err)
+		if t, ok := ex.(orm.Trans); ok {
+			t.ErrorSet(err)
+		}
+	}
+	return
+}
+
+// hookAfter 操作成功后调用钩子, 用于defer
+func (ob *Objects) hookAfter(ex interface{}, name string, record interface{}, err *error) {
+	if *err == nil {
+		*err = ob.hook(ex, name, record)
+	}
+}
+
 // Meta 信息
 func (ob *Objects) Meta() (mt *orm.Meta, err error) {
 	// may the 'limit' operating front, recount cache.
@@ -175,6 +325,15 @@ func (ob *Objects) Meta() (mt *orm.Meta, err error) {
 	if ob.group != nil {
 		mt.Group = ob.group
 	}
+	// field
+	if ob.fields != nil {
+		mt.Field = ob.fields
+	}
+	if ob.omit != nil {
+		mt.Omit = ob.omit
+	}
+	// cursor
+	mt.Next, mt.Prev = ob.cursorNext, ob.cursorPrev
 	return
 }
 
@@ -185,12 +344,9 @@ func (ob *Objects) All(result interface{}) (err error) {
 
 // TAll 在事务中获取
 func (ob *Objects) TAll(result interface{}, _t orm.Trans) (err error) {
-	if _t == nil {
-		return orm.ErrTransEmpty
-	}
-	t := _t.(*Trans)
-	if t == nil {
-		return orm.ErrTransInvalid
+	var t *Trans
+	if t, err = ob.trans(_t); err != nil {
+		return err
 	}
 	return ob.all(t, result)
 }
@@ -198,52 +354,80 @@ func (ob *Objects) TAll(result interface{}, _t orm.Trans) (err error) {
 // group-by https://stackoverflow.com/questions/1769361/postgresql-group-by-different-from-mysql
 // https://stackoverflow.com/questions/17673457/converting-select-distinct-on-queries-from-postgresql-to-mysql
 func (ob *Objects) all(ex execer, result interface{}) (err error) {
+	defer ob.ctxErr(&err)
+	defer ob.hookAfter(ex, orm.HookAfterFind, result, &err)
+	var (
+		sqlCmd string
+		args   []interface{}
+	)
+	if sqlCmd, args, err = ob.allSQL(result); err != nil {
+		return
+	}
+	if err = ob.selectDo(ex, false, result, sqlCmd, args...); err != nil {
+		ob.log.Errorf(`[sql-all] %s VAL: %v err: %v`, sqlCmd, args, err)
+	}
+
+	// count
+	if err == nil {
+		v := reflect.Indirect(reflect.ValueOf(result))
+		if v.Kind() == reflect.Slice {
+			ob.nums = v.Len()
+		}
+		// cursor
+		if len(ob.sorts) > 0 {
+			if ob.cursor != nil && ob.cursor.Prev {
+				orm.SliceReverse(result)
+			}
+			ob.cursorNext, ob.cursorPrev = orm.CursorTokens(ob.sorts, result, ob.limit, ob.cursor)
+		}
+		// debug
+		ob.log.Debugf(`[sql-all] %s`, sqlCmd)
+	}
+
+	return
+}
+
+// allSQL 生成all及游标的查询语句, result为切片或单条记录
+func (ob *Objects) allSQL(result interface{}) (sqlCmd string, args []interface{}, err error) {
 	if err = ob.updateQuery(); err != nil {
 		return
 	}
 	if ob.limit == 0 {
 		ob.cacheQueryLimit = fmt.Sprintf(`OFFSET %d`, ob.skip)
 	}
+	// 游标条件只用于读取, 不计入Count及Meta.Key
+	ob.cacheAllWhere, ob.cacheAllValues = ob.cacheQueryWhere, ob.cacheQueryValues
+	if ob.cursorM != nil {
+		if ob.cacheAllWhere, ob.cacheAllValues, err = orm.QueryAnd(ob.query(), ob.cursorM).SQL(driverName, 0); err != nil {
+			return
+		}
+	}
 	//
 	var (
-		sqlCmd string
 		fields = "*"
 	)
 	if ob.Model.DatabaseSQL.Unsafe == false {
-		fields = strings.Join(PubFieldWrapByDest(result), ",")
+		if _fields := PubFieldWrapByDest(result); len(_fields) > 0 {
+			fields = strings.Join(_fields, ",")
+		}
 	}
+	fields = ob.selectFields(result, fields)
 	if len(ob.group) > 0 {
 		// group在postgres下的实现
 		fields = fmt.Sprintf(`DISTINCT ON (%s) %s`, strings.Join(PubFieldWrapAll(ob.group), ","), fields)
 	}
-	if len(ob.cacheQueryWhere) == 0 {
+	if len(ob.cacheAllWhere) == 0 {
 		// select all
 		sqlCmd = fmt.Sprintf(`SELECT %s FROM %s %s %s`,
 			fields, ob.Model.GetTable(), ob.cacheQueryOrder, ob.cacheQueryLimit)
-		sqlCmd = strings.ReplaceAll(sqlCmd, "  ", " ")
-		if err = ex.Select(result, sqlCmd); err != nil {
-			ob.log.Errorf(`[sql-all] %s err: %v`, sqlCmd, err)
-		}
 	} else {
 		// select query
+		var order string
+		order, args = ob.orderSQL(ob.cacheAllValues)
 		sqlCmd = fmt.Sprintf(`SELECT %s FROM %s WHERE %s %s %s`,
-			fields, ob.Model.GetTable(), ob.cacheQueryWhere, ob.cacheQueryOrder, ob.cacheQueryLimit)
-		sqlCmd = strings.ReplaceAll(sqlCmd, "  ", " ")
-		if err = ex.Select(result, sqlCmd, ob.cacheQueryValues...); err != nil {
-			ob.log.Errorf(`[sql-all] %s VAL: %v err: %v`, sqlCmd, ob.cacheQueryValues, err)
-		}
-	}
-
-	// count
-	if err == nil {
-		v := reflect.Indirect(reflect.ValueOf(result))
-		if v.Kind() == reflect.Slice {
-			ob.nums = v.Len()
-		}
-		// debug
-		ob.log.Debugf(`[sql-all] %s`, sqlCmd)
+			fields, ob.Model.GetTable(), ob.cacheAllWhere, order, ob.cacheQueryLimit)
 	}
-
+	sqlCmd = strings.ReplaceAll(sqlCmd, "  ", " ")
 	return
 }
 
@@ -255,18 +439,16 @@ func (ob *Objects) Count() (num int, err error) {
 
 // TCount is Count in transaction
 func (ob *Objects) TCount(_t orm.Trans) (num int, err error) {
-	if _t == nil {
-		return 0, orm.ErrTransEmpty
-	}
-	t := _t.(*Trans)
-	if t == nil {
-		return 0, orm.ErrTransInvalid
+	var t *Trans
+	if t, err = ob.trans(_t); err != nil {
+		return 0, err
 	}
 	return ob.countDo(t)
 }
 
 // Count
 func (ob *Objects) countDo(ex execer) (num int, err error) {
+	defer ob.ctxErr(&err)
 	if ob.count > -1 {
 		num = ob.count
 		return
@@ -287,7 +469,7 @@ func (ob *Objects) countDo(ex execer) (num int, err error) {
 	}
 	sqlCmd = fmt.Sprintf(`SELECT count(%s) FROM %s %s`, fields, ob.Model.GetTable(), where)
 	sqlCmd = strings.ReplaceAll(sqlCmd, "  ", " ")
-	if err = ex.Get(&num, sqlCmd, ob.cacheQueryValues...); err != nil {
+	if err = ex.GetContext(ob.getContext(), &num, sqlCmd, ob.cacheQueryValues...); err != nil {
 		ob.log.Errorf(`[sql-count] %s VAL: %v err: %v`, sqlCmd, ob.cacheQueryValues, err)
 	}
 
@@ -306,6 +488,8 @@ func (ob *Objects) countDo(ex execer) (num int, err error) {
 
 // TOne fetch one to (in tx)
 func (ob *Objects) TOne(result interface{}, _t orm.Trans) (err error) {
+	defer ob.ctxErr(&err)
+	defer ob.hookAfter(_t, orm.HookAfterFind, result, &err)
 	if _, err = ob.TCount(_t); err != nil {
 		return
 	}
@@ -315,24 +499,25 @@ func (ob *Objects) TOne(result interface{}, _t orm.Trans) (err error) {
 			field  = "*"
 		)
 		if ob.Model.DatabaseSQL.Unsafe == false {
-			field = strings.Join(PubFieldWrapByDest(result), ",")
+			if _fields := PubFieldWrapByDest(result); len(_fields) > 0 {
+				field = strings.Join(_fields, ",")
+			}
 		}
+		field = ob.selectFields(result, field)
 		//
-		if _t == nil {
-			return orm.ErrTransEmpty
-		}
-		t := _t.(*Trans)
-		if t == nil {
-			return orm.ErrTransInvalid
+		var t *Trans
+		if t, err = ob.trans(_t); err != nil {
+			return err
 		}
 		//
+		order, args := ob.orderSQL(ob.cacheQueryValues)
 		sqlCmd = fmt.Sprintf(`SELECT %s FROM %s WHERE %s %s %s`,
-			field, ob.Model.GetTable(), ob.cacheQueryWhere, ob.cacheQueryOrder, ob.cacheQueryLimit)
-		err = t.Get(result, sqlCmd, ob.cacheQueryValues...)
+			field, ob.Model.GetTable(), ob.cacheQueryWhere, order, ob.cacheQueryLimit)
+		err = ob.selectDo(t, true, result, sqlCmd, args...)
 		if err != nil {
-			ob.log.Errorf(`[sql-one-t] %s VAL: %v err: %v`, sqlCmd, ob.cacheQueryValues, err)
+			ob.log.Errorf(`[sql-one-t] %s VAL: %v err: %v`, sqlCmd, args, err)
 		} else {
-			ob.log.Debugf(`[sql-one-t] %s VAL: %v`, sqlCmd, ob.cacheQueryValues)
+			ob.log.Debugf(`[sql-one-t] %s VAL: %v`, sqlCmd, args)
 		}
 	} else if ob.count == 0 {
 		err = orm.ErrMatchNone
@@ -345,6 +530,8 @@ func (ob *Objects) TOne(result interface{}, _t orm.Trans) (err error) {
 // One 取一条记录
 // pg issue: missing destination name https://github.com/jmoiron/sqlx/issues/143
 func (ob *Objects) One(result interface{}) (err error) {
+	defer ob.ctxErr(&err)
+	defer ob.hookAfter(ob.Model.DatabaseSQL.DB, orm.HookAfterFind, result, &err)
 	if _, err = ob.Count(); err != nil {
 		return
 	}
@@ -354,16 +541,20 @@ func (ob *Objects) One(result interface{}) (err error) {
 			field  = "*"
 		)
 		if ob.Model.DatabaseSQL.Unsafe == false {
-			field = strings.Join(PubFieldWrapByDest(result), ",")
+			if _fields := PubFieldWrapByDest(result); len(_fields) > 0 {
+				field = strings.Join(_fields, ",")
+			}
 		}
+		field = ob.selectFields(result, field)
+		order, args := ob.orderSQL(ob.cacheQueryValues)
 		sqlCmd = fmt.Sprintf(`SELECT %s FROM %s WHERE %s %s %s`,
-			field, ob.Model.GetTable(), ob.cacheQueryWhere, ob.cacheQueryOrder, ob.cacheQueryLimit)
+			field, ob.Model.GetTable(), ob.cacheQueryWhere, order, ob.cacheQueryLimit)
 		sqlCmd = strings.ReplaceAll(sqlCmd, "  ", " ")
-		err = ob.Model.DatabaseSQL.DB.Get(result, sqlCmd, ob.cacheQueryValues...)
+		err = ob.selectDo(ob.Model.DatabaseSQL.DB, true, result, sqlCmd, args...)
 		if err != nil {
-			ob.log.Errorf(`[sql-one] %s VAL: %v err: %v`, sqlCmd, ob.cacheQueryValues, err)
+			ob.log.Errorf(`[sql-one] %s VAL: %v err: %v`, sqlCmd, args, err)
 		} else {
-			ob.log.Debugf(`[sql-one] %s VAL: %v`, sqlCmd, ob.cacheQueryValues)
+			ob.log.Debugf(`[sql-one] %s VAL: %v`, sqlCmd, args)
 		}
 	} else if ob.count == 0 {
 		err = orm.ErrMatchNone
@@ -373,6 +564,115 @@ func (ob *Objects) One(result interface{}) (err error) {
 	return
 }
 
+// CreateMany 批量新建记录
+func (ob *Objects) CreateMany(st interface{}) (err error) {
+	return ob.createMany(ob.Model.DatabaseSQL.DB, st)
+}
+
+// createMany 按占位符上限分批插入, 每批的结果记入ob.Result
+func (ob *Objects) createMany(ex execer, st interface{}) (err error) {
+	defer ob.ctxErr(&err)
+	if err = ob.hook(ex, orm.HookBeforeCreate, st); err != nil {
+		return
+	}
+	defer ob.hookAfter(ex, orm.HookAfterCreate, st, &err)
+	orm.TimeFill(st, true)
+	v := reflect.Indirect(reflect.ValueOf(st))
+	if v.Kind() != reflect.Slice {
+		return orm.ErrCreateManyNotSlice
+	}
+	var (
+		n   = v.Len()
+		res = orm.ResultBatch{}
+	)
+	ob.Result = res
+	if n == 0 {
+		return
+	}
+	if err = ob.Model.ContigParse(v.Index(0).Interface()); err != nil {
+		return
+	}
+
+	var (
+		sqlCmd = fmt.Sprintf(`INSERT INTO %s`, ob.Model.ContigInsert)
+		cols   = strings.Count(ob.Model.ContigInsert[strings.LastIndex(ob.Model.ContigInsert, "VALUES"):], ":")
+		size   = n
+	)
+	if cols > 0 {
+		if size = CfgBatchArgsMax / cols; size < 1 {
+			return orm.ErrCreateManyArgsMax
+		}
+	}
+	for i := 0; i < n; i += size {
+		j := i + size
+		if j > n {
+			j = n
+		}
+		var r sql.Result
+		if r, err = ex.NamedExecContext(ob.getContext(), sqlCmd, v.Slice(i, j).Interface()); err != nil {
+			ob.log.Errorf(`[sql-create-many] %s ROWS: %d-%d err: %v`, sqlCmd, i, j, err)
+			return
+		}
+		res = append(res, r)
+		ob.Result = res
+		ob.log.Debugf(`[sql-create-many] %s ROWS: %d-%d`, sqlCmd, i, j)
+	}
+	return
+}
+
+// Upsert 插入或更新, 冲突字段默认取主键或唯一索引
+func (ob *Objects) Upsert(record interface{}, keys ...string) (err error) {
+	return ob.upsert(ob.Model.DatabaseSQL.DB, record, keys)
+}
+
+// TUpsert 事务中插入或更新
+func (ob *Objects) TUpsert(record interface{}, _t orm.Trans, keys ...string) (err error) {
+	var t *Trans
+	if t, err = ob.trans(_t); err != nil {
+		return err
+	}
+	err = ob.upsert(t, record, keys)
+	_ = t.DebugPush(`[upsert]`)
+	return
+}
+
+// upsert INSERT ... ON CONFLICT ... DO UPDATE
+func (ob *Objects) upsert(ex execer, record interface{}, keys []string) (err error) {
+	defer ob.ctxErr(&err)
+	orm.TimeFill(record, true)
+	var (
+		conflict, columns, update []string
+		version                   string
+		values, setLis            []string
+		action                    = "DO NOTHING"
+	)
+	if conflict, columns, update, version, err = orm.UpsertFields(record, keys, ob.Model.AutoIncrementField); err != nil {
+		return
+	}
+	for _, c := range columns {
+		values = append(values, ":"+c)
+	}
+	for _, c := range update {
+		setLis = append(setLis, fmt.Sprintf(`"%s" = EXCLUDED."%s"`, c, c))
+	}
+	if len(version) > 0 && len(setLis) > 0 {
+		// 乐观锁: 冲突更新时版本号在原值上加1
+		setLis = append(setLis, fmt.Sprintf(`"%s" = COALESCE("%s"."%s", 0) + 1`, version, ob.Model.TableName, version))
+	}
+	if len(setLis) > 0 {
+		action = "DO UPDATE SET " + strings.Join(setLis, ", ")
+	}
+	sqlCmd := fmt.Sprintf(`INSERT INTO "%s" (%s) VALUES (%s) ON CONFLICT (%s) %s`, ob.Model.TableName,
+		strings.Join(PubFieldWrapAll(columns), ", "), strings.Join(values, ", "),
+		strings.Join(PubFieldWrapAll(conflict), ", "), action)
+	if ob.Result, err = ex.NamedExecContext(ob.getContext(), sqlCmd, record); err != nil {
+		ob.log.Errorf(`[sql-upsert] %s VAL: %v err: %v`, sqlCmd, record, err)
+	} else {
+		ob.log.Debugf(`[sql-upsert] %s VAL: %v`, sqlCmd, record)
+	}
+	return
+}
+
 // Create 新建记录
 func (ob *Objects) Create(insert interface{}) (err error) {
 	return ob.create(ob.Model.DatabaseSQL.DB, insert)
@@ -380,11 +680,17 @@ func (ob *Objects) Create(insert interface{}) (err error) {
 
 //
 func (ob *Objects) create(ex execer, insert interface{}) (err error) {
+	defer ob.ctxErr(&err)
+	if err = ob.hook(ex, orm.HookBeforeCreate, insert); err != nil {
+		return
+	}
+	defer ob.hookAfter(ex, orm.HookAfterCreate, insert, &err)
+	orm.TimeFill(insert, true)
 	if err = ob.Model.ContigParse(insert); err != nil {
 		return
 	}
 	sqlCmd := fmt.Sprintf(`INSERT INTO %s;`, ob.Model.ContigInsert)
-	ob.Result, err = ex.NamedExec(sqlCmd, insert)
+	ob.Result, err = ex.NamedExecContext(ob.getContext(), sqlCmd, insert)
 	if err != nil {
 		ob.log.Errorf(`[sql-create] %s VAL: %v err: %v`, sqlCmd, insert, err)
 	} else {
@@ -400,6 +706,12 @@ func (ob *Objects) Update(record interface{}) (err error) {
 
 //
 func (ob *Objects) update(ex execer, record interface{}) (err error) {
+	defer ob.ctxErr(&err)
+	if err = ob.hook(ex, orm.HookBeforeUpdate, record); err != nil {
+		return
+	}
+	defer ob.hookAfter(ex, orm.HookAfterUpdate, record, &err)
+	orm.TimeFill(record, false)
 	if err = ob.updateQuery(); err != nil {
 		return
 	}
@@ -408,6 +720,7 @@ func (ob *Objects) update(ex execer, record interface{}) (err error) {
 	if reVal.Kind() == reflect.Map {
 		// map: update set
 		if m, ok := record.(map[string]interface{}); ok {
+			m = orm.TimeFillMap(ob.Model.info(), m)
 			// toLower
 			_m := map[string]interface{}{}
 			for _k, _v := range m {
@@ -457,7 +770,7 @@ func (ob *Objects) update(ex execer, record interface{}) (err error) {
 
 			if len(ob.cacheQueryWhere) == 0 {
 				// update one or more or nil
-				if ob.Result, err = ex.NamedExec(query, m); err != nil {
+				if ob.Result, err = ex.NamedExecContext(ob.getContext(), query, m); err != nil {
 					ob.log.Errorf(`[sql-update] %s VAL: %v err: %v`, query, m, err)
 				} else {
 					ob.log.Debugf(`[sql-update] %s VAL: %v`, query, m)
@@ -491,12 +804,12 @@ func (ob *Objects) update(ex execer, record interface{}) (err error) {
 				}
 
 				// TODO: performance
-				if queryWhere, _, err = ob.queryM.SQL(driverName, len(args)); err != nil {
+				if queryWhere, _, err = ob.query().SQL(driverName, len(args)); err != nil {
 					return
 				}
 				query = ob.Model.DatabaseSQL.DB.Rebind(query + ` WHERE ` + queryWhere)
 				args = append(args, ob.cacheQueryValues...)
-				if ob.Result, err = ex.Exec(query, args...); err != nil {
+				if ob.Result, err = ex.ExecContext(ob.getContext(), query, args...); err != nil {
 					ob.log.Errorf(`[sql-update] %s VAL: %v err: %v`, query, args, err)
 				} else {
 					ob.log.Debugf(`[sql-update] %s VAL: %v`, query, args)
@@ -507,58 +820,88 @@ func (ob *Objects) update(ex execer, record interface{}) (err error) {
 			return
 		}
 	} else {
-		// struct: overwrite
-		sqlCmd := `UPDATE ` + ob.Model.ContigUpdate
-		if len(ob.cacheQueryWhere) == 0 {
-			ob.Result, err = ex.NamedExec(sqlCmd, record)
+		// struct: overwrite; 未经Ensure/Create的表先解析语句
+		if err = ob.Model.ContigParse(record); err != nil {
+			return
+		}
+		var (
+			sqlCmd  = `UPDATE ` + ob.Model.ContigUpdate
+			where   = ob.query()
+			version bool // 乐观锁: 追加版本条件
+		)
+		where, version = orm.VersionQuery(ob.Model.info(), where, record)
+		if len(where) == 0 {
+			ob.Result, err = ex.NamedExecContext(ob.getContext(), sqlCmd, record)
 		} else {
 			var (
-				query      string
-				queryWhere string
-				args       []interface{}
+				query       string
+				queryWhere  string
+				queryValues []interface{}
+				args        []interface{}
 			)
 			if query, args, err = ob.Model.DatabaseSQL.DB.BindNamed(`UPDATE `+ob.Model.ContigUpdate, record); err != nil {
 				return
 			}
 			// use special where contig
 			// TODO: performance
-			if queryWhere, _, err = ob.queryM.SQL(driverName, len(args)); err != nil {
+			if queryWhere, queryValues, err = where.SQL(driverName, len(args)); err != nil {
 				return
 			}
 			sqlCmd = ob.Model.DatabaseSQL.DB.Rebind(query + ` WHERE ` + queryWhere)
-			args = append(args, ob.cacheQueryValues...)
+			args = append(args, queryValues...)
 			// exec
-			if ob.Result, err = ex.Exec(sqlCmd, args...); err != nil {
+			if ob.Result, err = ex.ExecContext(ob.getContext(), sqlCmd, args...); err != nil {
 				ob.log.Errorf(`[sql-update] %s VAL: %v err: %v`, sqlCmd, args, err)
 			}
 		}
 		ob.log.Debugf(`[sql-update] %s`, sqlCmd)
+		if version && err == nil {
+			var n int64
+			if n, err = ob.Result.RowsAffected(); err == nil && n == 0 {
+				err = orm.ErrVersionConflict
+			} else if err == nil {
+				orm.VersionAdd(ob.Model.info(), record, 1)
+			}
+		}
 	}
 
 	return
 }
 
 // Delete 删除
-func (ob *Objects) Delete() error {
-	return ob.delete(ob.Model.DatabaseSQL.DB)
+func (ob *Objects) Delete(record ...interface{}) error {
+	return ob.delete(ob.Model.DatabaseSQL.DB, record)
 }
 
 // DeleteOne 删除一条记录
-func (ob *Objects) DeleteOne() (err error) {
+func (ob *Objects) DeleteOne(record ...interface{}) (err error) {
 	if _, err = ob.Count(); err != nil {
 		return
 	}
 	if ob.count == 0 {
 		err = orm.ErrMatchNone
 	} else if ob.count == 1 {
-		err = ob.delete(ob.Model.DatabaseSQL.DB)
+		err = ob.delete(ob.Model.DatabaseSQL.DB, record)
 	} else {
 		err = orm.ErrMatchMultiple
 	}
 	return
 }
 
-func (ob *Objects) delete(ex execer) (err error) {
+func (ob *Objects) delete(ex execer, record []interface{}) (err error) {
+	defer ob.ctxErr(&err)
+	for _, r := range record {
+		if err = ob.hook(ex, orm.HookBeforeDelete, r); err != nil {
+			return
+		}
+	}
+	if err = ob.Model.infoLoad(); err != nil {
+		return
+	}
+	if set, ok := orm.SoftDeleteSet(ob.Model.info(), true); ok {
+		// 软删除: 只标记不删除
+		return ob.update(ex, set)
+	}
 	if err = ob.updateQuery(); err != nil {
 		return
 	}
@@ -566,12 +909,12 @@ func (ob *Objects) delete(ex execer) (err error) {
 	if len(ob.cacheQueryWhere) == 0 {
 		// delete all record
 		sqlCmd = fmt.Sprintf(`DELETE FROM %s`, ob.Model.GetTable())
-		if ob.Result, err = ex.Exec(sqlCmd); err != nil {
+		if ob.Result, err = ex.ExecContext(ob.getContext(), sqlCmd); err != nil {
 			ob.log.Errorf(`[sql-delete] %s err: %v`, sqlCmd, err)
 		}
 	} else {
 		sqlCmd = fmt.Sprintf(`DELETE FROM %s WHERE %s`, ob.Model.GetTable(), ob.cacheQueryWhere)
-		if ob.Result, err = ex.Exec(sqlCmd, ob.cacheQueryValues...); err != nil {
+		if ob.Result, err = ex.ExecContext(ob.getContext(), sqlCmd, ob.cacheQueryValues...); err != nil {
 			ob.log.Errorf(`[sql-delete] %s VAL: %v err: %v`, sqlCmd, ob.cacheQueryValues, err)
 		}
 	}
@@ -595,35 +938,29 @@ func (ob *Objects) UpdateOne(record interface{}) (err error) {
 }
 
 // TDelete 事务中删除
-func (ob *Objects) TDelete(_t orm.Trans) (err error) {
-	if _t == nil {
-		return orm.ErrTransEmpty
+func (ob *Objects) TDelete(_t orm.Trans, record ...interface{}) (err error) {
+	var t *Trans
+	if t, err = ob.trans(_t); err != nil {
+		return err
 	}
-	t := _t.(*Trans)
-	if t == nil {
-		return orm.ErrTransInvalid
-	}
-	err = ob.delete(t)
+	err = ob.delete(t, record)
 	_ = t.DebugPush(`[delete]` + ob.cacheQueryWhere)
 	return
 }
 
 // TDeleteOne 事务中删除
-func (ob *Objects) TDeleteOne(_t orm.Trans) (err error) {
+func (ob *Objects) TDeleteOne(_t orm.Trans, record ...interface{}) (err error) {
 	if _, err = ob.Count(); err != nil {
 		return
 	}
-	if _t == nil {
-		return orm.ErrTransEmpty
-	}
-	t := _t.(*Trans)
-	if t == nil {
-		return orm.ErrTransInvalid
+	var t *Trans
+	if t, err = ob.trans(_t); err != nil {
+		return err
 	}
 	if ob.count == 0 {
 		err = orm.ErrMatchNone
 	} else if ob.count == 1 {
-		err = ob.delete(t)
+		err = ob.delete(t, record)
 	} else {
 		err = orm.ErrMatchMultiple
 	}
@@ -631,28 +968,61 @@ func (ob *Objects) TDeleteOne(_t orm.Trans) (err error) {
 	return
 }
 
+// Restore 恢复已软删除的记录
+func (ob *Objects) Restore() error {
+	return ob.restore(ob.Model.DatabaseSQL.DB)
+}
+
+// TRestore 事务中恢复已软删除的记录
+func (ob *Objects) TRestore(_t orm.Trans) (err error) {
+	var t *Trans
+	if t, err = ob.trans(_t); err != nil {
+		return err
+	}
+	err = ob.restore(t)
+	_ = t.DebugPush(`[restore]` + ob.cacheQueryWhere)
+	return
+}
+
+func (ob *Objects) restore(ex execer) (err error) {
+	if err = ob.Model.infoLoad(); err != nil {
+		return
+	}
+	set, ok := orm.SoftDeleteSet(ob.Model.info(), false)
+	if !ok {
+		return orm.ErrSoftDeleteUndefined
+	}
+	ob.scopeSet(orm.SoftDeleteOnly)
+	return ob.update(ex, set)
+}
+
 // TCreate 事务中创建
 func (ob *Objects) TCreate(insert interface{}, _t orm.Trans) (err error) {
-	if _t == nil {
-		return orm.ErrTransEmpty
-	}
-	t := _t.(*Trans)
-	if t == nil {
-		return orm.ErrTransInvalid
+	var t *Trans
+	if t, err = ob.trans(_t); err != nil {
+		return err
 	}
 	err = ob.create(t, insert)
 	_ = t.DebugPush(`[create]` + ob.cacheQueryWhere)
 	return
 }
 
+// TCreateMany 事务中批量创建
+func (ob *Objects) TCreateMany(st interface{}, _t orm.Trans) (err error) {
+	var t *Trans
+	if t, err = ob.trans(_t); err != nil {
+		return err
+	}
+	err = ob.createMany(t, st)
+	_ = t.DebugPush(`[createMany]`)
+	return
+}
+
 // TUpdate 事务中更新
 func (ob *Objects) TUpdate(record interface{}, _t orm.Trans) (err error) {
-	if _t == nil {
-		return orm.ErrTransEmpty
-	}
-	t := _t.(*Trans)
-	if t == nil {
-		return orm.ErrTransInvalid
+	var t *Trans
+	if t, err = ob.trans(_t); err != nil {
+		return err
 	}
 	err = ob.update(t, record)
 	_ = t.DebugPush(`[update]` + ob.cacheQueryWhere)
@@ -664,10 +1034,10 @@ func (ob *Objects) TUpdateOne(record interface{}, _t orm.Trans) (err error) {
 	if _, err = ob.Count(); err != nil {
 		return
 	}
-	if _t == nil {
-		return orm.ErrTransEmpty
+	var t *Trans
+	if t, err = ob.trans(_t); err != nil {
+		return
 	}
-	t := _t.(*Trans)
 	if ob.count == 0 {
 		err = orm.ErrMatchNone
 	} else if ob.count == 1 {
@@ -679,12 +1049,37 @@ func (ob *Objects) TUpdateOne(record interface{}, _t orm.Trans) (err error) {
 	return
 }
 
+// trans 取出事务, 非本库开启的事务返回ErrTransInvalid
+func (ob *Objects) trans(_t orm.Trans) (t *Trans, err error) {
+	if _t == nil {
+		return nil, orm.ErrTransEmpty
+	}
+	var ok bool
+	if t, ok = _t.(*Trans); !ok || t == nil || t.db != ob.Model.DatabaseSQL {
+		return nil, orm.ErrTransInvalid
+	}
+	return
+}
+
 // build in method
 // update query cache
 func (ob *Objects) updateQuery() (err error) {
+	if ob.cursorErr != nil {
+		return ob.cursorErr
+	}
+	if err = ob.Model.infoLoad(); err != nil {
+		return
+	}
 	//log.Warn("ob.cacheQueryValues ", ob.cacheQueryWhere, " ", ob.cacheQueryValues)
+	// 按相关度排序需有全文搜索条件
+	if strings.Contains(ob.cacheQueryOrder, songo.TagValText) {
+		if _, _, ok := songo.TextField(ob.query()); !ok {
+			return orm.ErrIndexTextSortInvalid
+		}
+	}
 	// query all
-	if ob.queryM == nil {
+	query := ob.query()
+	if query == nil {
 		ob.cacheQueryValues = nil
 		ob.cacheQueryWhere = ""
 		return
@@ -694,7 +1089,7 @@ func (ob *Objects) updateQuery() (err error) {
 		return
 	}
 	// update
-	if ob.cacheQueryWhere, ob.cacheQueryValues, err = ob.queryM.SQL(driverName, 0); err != nil {
+	if ob.cacheQueryWhere, ob.cacheQueryValues, err = query.SQL(driverName, 0); err != nil {
 		return
 	}
 	//log.Warn("ob.cacheQueryValues ", ob.cacheQueryWhere, " ", ob.cacheQueryValues)
@@ -704,7 +1099,12 @@ func (ob *Objects) updateQuery() (err error) {
 }
 
 // TLockUpdate row lock
-func (ob *Objects) TLockUpdate(t orm.Trans) (err error) {
+func (ob *Objects) TLockUpdate(_t orm.Trans) (err error) {
+	defer ob.ctxErr(&err)
+	var t *Trans
+	if t, err = ob.trans(_t); err != nil {
+		return
+	}
 	if err = ob.updateQuery(); err != nil {
 		return
 	}
@@ -717,7 +1117,7 @@ func (ob *Objects) TLockUpdate(t orm.Trans) (err error) {
 	}
 	sqlCmd = fmt.Sprintf(`SELECT * FROM %s WHERE %s FOR UPDATE`, ob.Model.GetTable(), ob.cacheQueryWhere)
 
-	if ob.Result, err = t.Exec(sqlCmd, ob.cacheQueryValues...); err != nil {
+	if ob.Result, err = t.ExecContext(ob.getContext(), sqlCmd, ob.cacheQueryValues...); err != nil {
 		ob.log.Errorf(`[sql-lock-t] %s VAL: %v err: %v`, sqlCmd, ob.cacheQueryValues, err)
 	} else {
 		ob.log.Debugf(`[sql-lock-t] %s`, sqlCmd)
@@ -742,6 +1142,26 @@ func (ob *Objects) Copy() (ret *Objects) {
 	return
 }
 
+// WithContext 绑定上下文
+func (ob *Objects) WithContext(ctx context.Context) (ret orm.Objects) {
+	r := ob.Copy()
+	r.ctx = ctx
+	return r
+}
+
+// getContext 取上下文
+func (ob *Objects) getContext() context.Context {
+	if ob.ctx == nil {
+		return context.Background()
+	}
+	return ob.ctx
+}
+
+// ctxErr 上下文取消或超时时统一错误
+func (ob *Objects) ctxErr(err *error) {
+	*err = orm.ContextErr(ob.ctx, *err)
+}
+
 // With 设置日志级别
 func (ob *Objects) With(arg *orm.ArgObjects) (ret orm.Objects) {
 	r := ob.Copy()
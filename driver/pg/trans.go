@@ -2,10 +2,16 @@ package pg
 
 import (
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/suboat/sorm"
 
+	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -13,37 +19,76 @@ import (
 type Trans struct {
 	Tx      *sqlx.Tx
 	TxError error
+	// 开启事务的数据库
+	db *DatabaseSQL
+	// context of begin
+	ctx context.Context
 	// promise
 	promise []func(error)
-	// already commit or rollback
-	isFinish bool
-	// sql history, for debug
+	// 事务状态, 超时回滚在另一goroutine, 需原子读写
+	state int32
+	// 超时回滚时在另一goroutine写入TxError, 内部读写需加锁
+	errLock sync.Mutex
+	// sql history, for debug; 超时时在另一goroutine读取
 	debugInfo []string
+	debugLock sync.Mutex
 	// trans expired
 	timer *time.Timer
+	// nested: 父事务及对应的保存点
+	parent    *Trans
+	savepoint string
+	// 已生成的保存点数目, 仅在最外层事务中计数
+	savepointNum int
 }
 
-func (t *Trans) Error() error {
-	return t.TxError
+func (t *Trans) Error() (err error) {
+	if err = t.errGet(); err == nil && atomic.LoadInt32(&t.state) == transExpired {
+		err = orm.ErrTransTimeout
+	}
+	return
 }
 
 // ErrorSet 设置错误
 func (t *Trans) ErrorSet(err error) {
+	t.errSet(err)
+}
+
+// errGet 加锁读取TxError
+func (t *Trans) errGet() error {
+	t.errLock.Lock()
+	defer t.errLock.Unlock()
+	return t.TxError
+}
+
+// errSet 加锁写入TxError
+func (t *Trans) errSet(err error) {
+	t.errLock.Lock()
 	t.TxError = err
+	t.errLock.Unlock()
 }
 
 // Commit 事务提交
 func (t *Trans) Commit() (err error) {
-	if t.isFinish {
+	if t.parent != nil {
+		if !t.finished() {
+			err = t.commitNested()
+		}
+		return
+	}
+	if !t.finish(transFinished) {
+		// 已超时回滚
+		if atomic.LoadInt32(&t.state) == transExpired {
+			err = orm.ErrTransTimeout
+		}
 		return
 	}
-	if t.TxError != nil {
+	if txErr := t.errGet(); txErr != nil {
 		if err = t.Tx.Rollback(); err != nil {
 			return
 		}
-		err = t.TxError
+		err = txErr
 	} else if err = t.Tx.Commit(); err != nil {
-		t.TxError = err
+		t.errSet(err)
 	}
 
 	// promise
@@ -53,36 +98,70 @@ func (t *Trans) Commit() (err error) {
 		}
 	}
 
-	t.isFinish = true
 	t.timerReset()
 	return
 }
 
 // Rollback 回滚事务
 func (t *Trans) Rollback() (err error) {
-	if t.isFinish {
+	if t.parent != nil {
+		if !t.finished() {
+			err = t.rollback(t.errGet())
+			atomic.StoreInt32(&t.state, transFinished)
+		}
 		return
 	}
+	if t.finish(transFinished) {
+		err = t.rollback(t.errGet())
+	}
+	return
+}
 
-	// ignore t.TxError
-
+// rollback 回滚并执行绑定的函数, pErr为绑定函数收到的错误; 由Rollback及超时回滚调用
+func (t *Trans) rollback(pErr error) (err error) {
 	// promise
 	if t.promise != nil {
-		var pErr error
-		if pErr = t.TxError; pErr == nil {
+		if pErr == nil {
 			pErr = orm.ErrTransRollbackUndefined
 		}
 		for _, fn := range t.promise {
 			fn(pErr)
 		}
 	}
-	err = t.Tx.Rollback()
+	if t.parent != nil {
+		err = t.rollbackNested()
+	} else {
+		err = t.Tx.Rollback()
+	}
 
-	t.isFinish = true
 	t.timerReset()
 	return
 }
 
+// Savepoint 设置保存点
+func (t *Trans) Savepoint(name string) (err error) {
+	if err = t.errGet(); err != nil {
+		return
+	}
+	return t.savepointExec("SAVEPOINT %s", name)
+}
+
+// RollbackTo 回滚至保存点, 成功后清除事务中积累的错误
+func (t *Trans) RollbackTo(name string) (err error) {
+	if err = t.savepointExec("ROLLBACK TO SAVEPOINT %s", name); err == nil {
+		t.errSet(nil)
+	}
+	return
+}
+
+// Release 释放保存点
+func (t *Trans) Release(name string) (err error) {
+	if err = t.errGet(); err != nil {
+		return
+	}
+	return t.savepointExec("RELEASE SAVEPOINT %s", name)
+}
+
 // Promise 返回已绑定
 func (t *Trans) Promise() []func(error) {
 	return t.promise
@@ -103,59 +182,90 @@ func (t *Trans) PromiseAdd(fns ...func(error)) (err error) {
 
 // Exec 执行
 func (t *Trans) Exec(query string, args ...interface{}) (result sql.Result, err error) {
-	if t.TxError != nil {
-		err = t.TxError
+	return t.ExecContext(t.getContext(), query, args...)
+}
+
+// ExecContext 带上下文执行
+func (t *Trans) ExecContext(ctx context.Context, query string, args ...interface{}) (result sql.Result, err error) {
+	if err = t.Error(); err != nil {
 		return
 	}
-	if result, err = t.Tx.Exec(query, args...); err != nil {
-		t.TxError = err
+	if result, err = t.Tx.ExecContext(ctx, query, args...); err != nil {
+		t.errSet(orm.ContextErr(ctx, err))
 	}
 	return
 }
 
 // Get 在事务中获取
 func (t *Trans) Get(dest interface{}, query string, args ...interface{}) (err error) {
-	if t.TxError != nil {
-		err = t.TxError
+	return t.GetContext(t.getContext(), dest, query, args...)
+}
+
+// GetContext 带上下文在事务中获取
+func (t *Trans) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	if err = t.Error(); err != nil {
 		return
 	}
-	if err = t.Tx.Unsafe().Get(dest, query, args...); err != nil {
-		t.TxError = err
+	if err = t.Tx.Unsafe().GetContext(ctx, dest, query, args...); err != nil {
+		t.errSet(orm.ContextErr(ctx, err))
 	}
 	return
 }
 
 // Select 在事务中查询
 func (t *Trans) Select(dest interface{}, query string, args ...interface{}) (err error) {
-	if t.TxError != nil {
-		err = t.TxError
+	return t.SelectContext(t.getContext(), dest, query, args...)
+}
+
+// SelectContext 带上下文在事务中查询
+func (t *Trans) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) (err error) {
+	if err = t.Error(); err != nil {
+		return
+	}
+	if err = t.Tx.SelectContext(ctx, dest, query, args...); err != nil {
+		t.errSet(orm.ContextErr(ctx, err))
+	}
+	return
+}
+
+// QueryxContext 带上下文在事务中查询, 返回游标
+func (t *Trans) QueryxContext(ctx context.Context, query string, args ...interface{}) (rows *sqlx.Rows, err error) {
+	if err = t.Error(); err != nil {
 		return
 	}
-	if err = t.Tx.Select(dest, query, args...); err != nil {
-		t.TxError = err
+	if rows, err = t.Tx.QueryxContext(ctx, query, args...); err != nil {
+		t.errSet(orm.ContextErr(ctx, err))
 	}
 	return
 }
 
 // NamedExec 用结构体字段依赖执行
 func (t *Trans) NamedExec(query string, arg interface{}) (result sql.Result, err error) {
-	if t.TxError != nil {
-		err = t.TxError
+	return t.NamedExecContext(t.getContext(), query, arg)
+}
+
+// NamedExecContext 带上下文用结构体字段依赖执行
+func (t *Trans) NamedExecContext(ctx context.Context, query string, arg interface{}) (result sql.Result, err error) {
+	if err = t.Error(); err != nil {
 		return
 	}
-	if result, err = t.Tx.NamedExec(query, arg); err != nil {
-		t.TxError = err
+	if result, err = t.Tx.NamedExecContext(ctx, query, arg); err != nil {
+		t.errSet(orm.ContextErr(ctx, err))
 	}
 	return
 }
 
 // DebugPush 记录调试信息
 func (t *Trans) DebugPush(info ...string) (err error) {
+	t.debugLock.Lock()
+	defer t.debugLock.Unlock()
 	t.debugInfo = append(t.debugInfo, info...)
 	return
 }
 
 func (t *Trans) debugReport() (s string) {
+	t.debugLock.Lock()
+	defer t.debugLock.Unlock()
 	if t.debugInfo == nil {
 		return
 	}
@@ -163,8 +273,110 @@ func (t *Trans) debugReport() (s string) {
 	return
 }
 
+func (t *Trans) getContext() context.Context {
+	if t.ctx == nil {
+		return context.Background()
+	}
+	return t.ctx
+}
+
+// 事务状态
+const (
+	transActive   int32 = iota // 进行中
+	transFinished              // 已提交或回滚
+	transExpired               // 已超时回滚
+)
+
+// finish 标记事务结束, 已结束时返回false; 提交/回滚与超时回滚只有一方成功
+func (t *Trans) finish(state int32) bool {
+	return atomic.CompareAndSwapInt32(&t.state, transActive, state)
+}
+
+// finished 事务已结束
+func (t *Trans) finished() bool {
+	return atomic.LoadInt32(&t.state) != transActive
+}
+
 func (t *Trans) timerReset() {
-	if t.timer != nil && t.isFinish {
+	if t.timer != nil && t.finished() {
 		t.timer.Reset(0 * time.Second)
 	}
 }
+
+func (t *Trans) savepointExec(format, name string) (err error) {
+	if t.finished() {
+		return orm.ErrTransFinished
+	}
+	if err = orm.SavepointCheck(name); err != nil {
+		return
+	}
+	if _, err = t.Tx.ExecContext(t.getContext(), fmt.Sprintf(format, name)); err != nil {
+		t.errSet(orm.ContextErr(t.ctx, err))
+	}
+	return
+}
+
+// nested 以保存点开启子事务, 子事务与父事务共用连接
+func (t *Trans) nested() (c *Trans, err error) {
+	if err = t.Error(); err != nil {
+		return nil, err
+	}
+	root := t
+	for root.parent != nil {
+		root = root.parent
+	}
+	root.savepointNum++
+	c = &Trans{Tx: t.Tx, db: t.db, ctx: t.ctx, parent: t, savepoint: fmt.Sprintf("sorm_sp_%d", root.savepointNum)}
+	if err = c.Savepoint(c.savepoint); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// commitNested 子事务无错误时释放保存点, 绑定的函数移交父事务; 有错误时回滚至保存点
+func (t *Trans) commitNested() (err error) {
+	if err = t.errGet(); err != nil {
+		if _err := t.rollbackNested(); _err != nil {
+			err = _err
+		}
+	} else if err = t.Release(t.savepoint); err == nil {
+		_ = t.parent.PromiseAdd(t.promise...)
+		t.promise = nil
+	}
+
+	// promise
+	for _, fn := range t.promise {
+		fn(err)
+	}
+
+	atomic.StoreInt32(&t.state, transFinished)
+	return
+}
+
+// rollbackNested 回滚至保存点, 父事务可继续执行
+func (t *Trans) rollbackNested() (err error) {
+	if err = t.savepointExec("ROLLBACK TO SAVEPOINT %s", t.savepoint); err == nil {
+		err = t.savepointExec("RELEASE SAVEPOINT %s", t.savepoint)
+	}
+	return
+}
+
+// retryable 序列化失败及死锁可重新执行事务
+func retryable(err error) bool {
+	var e *pq.Error
+	if errors.As(err, &e) {
+		// serialization_failure, deadlock_detected
+		return e.Code == "40001" || e.Code == "40P01"
+	}
+	return false
+}
+
+// duplicateKey 主键或唯一索引冲突
+func duplicateKey(err error) bool {
+	var e *pq.Error
+	if errors.As(err, &e) {
+		// unique_violation
+		return e.Code == "23505"
+	}
+	return false
+}
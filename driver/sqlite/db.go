@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/url"
 	"strings"
+	"sync"
 
 	"github.com/jmoiron/sqlx"
 	_ "github.com/mattn/go-sqlite3" // 驱动包
@@ -17,8 +18,12 @@ import (
 var (
 	// MaxOpenConns 默认最大链接数
 	MaxOpenConns = 50
+	// MaxOpenConnsTxLock 事务加锁时另开连接池的最大链接数; 同一时间只有一个写事务, 无需多开
+	MaxOpenConnsTxLock = 2
 	// CfgDbUnsafe false:数据库严格映射到结构体
 	CfgDbUnsafe = false // true: sqlx.Unsafe 防止报错 https://github.com/jmoiron/sqlx/blob/master/sqlx.go#L601
+	// CfgBatchArgsMax 批量插入时单条语句的占位符上限
+	CfgBatchArgsMax = 999
 	// 针对数据库
 	driverName = orm.DriverNameSQLite //
 )
@@ -48,6 +53,10 @@ type DatabaseSQL struct {
 	Unsafe  bool
 	DB      *sqlx.DB
 	log     orm.Logger
+	models  orm.ModelInfoMap // 表名->附加信息
+	// 按开启事务时的锁另开的连接池
+	txLock map[string]*sqlx.DB
+	lock   sync.Mutex
 }
 
 //
@@ -80,7 +89,6 @@ func (arg *ArgConn) Init() (err error) {
 		if len(arg.Database) == 0 {
 			arg.Database = "sqlite.db"
 		}
-		break
 	case orm.DriverNameMongo:
 		break
 	default:
@@ -101,6 +109,13 @@ func (arg *ArgConn) String() (s string) {
 			arg.User, arg.Password, arg.Host, arg.Port, arg.Database, arg.Params.Encode())
 	case orm.DriverNameSQLite:
 		s = arg.Database
+		if len(arg.Params) > 0 {
+			if strings.Contains(s, "?") {
+				s += "&" + arg.Params.Encode()
+			} else {
+				s += "?" + arg.Params.Encode()
+			}
+		}
 	default:
 		panic("unknown database core")
 	}
@@ -151,12 +166,53 @@ func (db *DatabaseSQL) Reset() (err error) {
 
 // Close 断开数据库连接
 func (db *DatabaseSQL) Close() (err error) {
+	db.lock.Lock()
+	for k, v := range db.txLock {
+		_ = v.Close()
+		delete(db.txLock, k)
+	}
+	db.lock.Unlock()
 	if db.DB == nil {
 		return
 	}
 	return db.DB.Close()
 }
 
+// txDB 取开启事务时加锁的连接池; go-sqlite3只能由连接参数_txlock指定, 非默认的锁另开连接池
+// 每种锁各一个, 链接数上限为MaxOpenConnsTxLock, Close时一并关闭
+func (db *DatabaseSQL) txDB(lock string) (ret *sqlx.DB, err error) {
+	switch lock {
+	case orm.TransDeferred:
+		return db.DB, nil
+	case orm.TransImmediate, orm.TransExclusive:
+	default:
+		return nil, orm.ErrTransLockUnknown
+	}
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if ret = db.txLock[lock]; ret == nil {
+		arg := *db.ArgConn
+		arg.Params = url.Values{}
+		for k, v := range db.ArgConn.Params {
+			arg.Params[k] = v
+		}
+		arg.Params.Set("_txlock", lock)
+		if ret, err = sqlx.Connect(arg.Driver, arg.String()); err != nil {
+			return
+		}
+		ret.SetMaxOpenConns(MaxOpenConnsTxLock)
+		if db.txLock == nil {
+			db.txLock = make(map[string]*sqlx.DB)
+		}
+		db.txLock[lock] = ret
+	}
+	// ModelWith可能在之后才开启Unsafe, 取用时与主连接池保持一致
+	if db.Unsafe {
+		ret = ret.Unsafe()
+	}
+	return
+}
+
 // Model 获取table
 func (db *DatabaseSQL) Model(s string) orm.Model {
 	return db.ModelWith(s, nil)
@@ -186,9 +242,26 @@ func (db *DatabaseSQL) ModelWith(s string, arg *orm.ArgModel) orm.Model {
 			}
 		}
 	}
+	if arg != nil && arg.Struct != nil {
+		if lis, _err := orm.StructModelInfo(arg.Struct); _err != nil {
+			m.log.Errorf("model %s struct: %v", s, _err)
+		} else {
+			db.models.Register(s, lis)
+		}
+	}
 	return m
 }
 
+// Begin 开启事务, 可用于本库的任意model
+func (db *DatabaseSQL) Begin() (orm.Trans, error) {
+	return db.Model("").BeginWith(nil)
+}
+
+// BeginWith 指定参数开启事务, 可用于本库的任意model
+func (db *DatabaseSQL) BeginWith(arg *orm.ArgTrans) (orm.Trans, error) {
+	return db.Model("").BeginWith(arg)
+}
+
 // NewDb 新键连接
 func NewDb(arg string) (ret orm.Database, err error) {
 	var (
@@ -220,12 +293,18 @@ func NewDb(arg string) (ret orm.Database, err error) {
 	return
 }
 
+// parseSQL 未指定表名, $text$退化为like
+func parseSQL(m map[string]interface{}, prefix int) (string, []interface{}, error) {
+	return songo.ParseSqlite(m, prefix, "")
+}
+
 // register
 func init() {
 	orm.RegisterDriver(driverName, NewDb)
+	orm.HookDuplicateKey[driverName] = duplicateKey
 	// 用songo作为解析驱动
 	orm.HookParseSafe = songo.ParseSafe             //
-	orm.HookParseSQL[driverName] = songo.ParseMysql //
+	orm.HookParseSQL[driverName] = parseSQL         //
 	// default log
 	if orm.Log == nil {
 		orm.SetLog(log.Log)
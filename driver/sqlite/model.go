@@ -4,6 +4,7 @@ import (
 	"github.com/suboat/sorm"
 	"github.com/suboat/sorm/log"
 
+	"context"
 	"database/sql"
 	"fmt"
 	"runtime"
@@ -25,15 +26,21 @@ type Model struct {
 	ContigUpdate       string
 	AutoIncrementField string
 	//
-	log orm.Logger
+	plan *orm.Plan // 非nil时Ensure只记录语句, 不执行
+	log  orm.Logger
+	ctx  context.Context // nil: context.Background()
 }
 
 // SQLIndex is index info of SQL-like database
 type SQLIndex struct {
-	Key    []string
-	Unique bool
-	Kind   string // field type
-	Method string // 索引方法
+	Key     []string
+	Unique  bool
+	Kind    string   // field type
+	Method  string   // 索引方法
+	Name    string   // 索引名, 为空时由表名及字段生成
+	Expr    bool     // Key为表达式
+	Where   string   // 部分索引的条件
+	Include []string // 覆盖索引的附加字段
 }
 
 // Copy 全拷贝
@@ -44,6 +51,7 @@ func (m *Model) Copy() (r *Model) {
 	r.ContigInsert = m.ContigInsert
 	r.ContigUpdate = m.ContigUpdate
 	r.AutoIncrementField = m.AutoIncrementField
+	r.ctx = m.ctx
 	if m.log != nil {
 		if _log, ok := m.log.(*log.Logger); ok {
 			r.log = _log.Copy()
@@ -58,6 +66,24 @@ func (m *Model) Copy() (r *Model) {
 }
 
 // table name
+// info 所属数据库登记的表附加信息, 未登记时为nil
+func (m *Model) info() *orm.ModelInfo {
+	return m.DatabaseSQL.models.Get(m.TableName)
+}
+
+// infoLoad 表未登记时从orm.ModelInfoTable读取附加信息, 如重启后未经Ensure的表
+func (m *Model) infoLoad() (err error) {
+	if m.DatabaseSQL.models.Get(m.TableName) != nil {
+		return
+	}
+	var info *orm.ModelInfo
+	if info, err = orm.ModelInfoLoad(m.DatabaseSQL, m.TableName); err != nil {
+		return
+	}
+	m.DatabaseSQL.models.Init(m.TableName, info)
+	return
+}
+
 func (m *Model) String() (s string) {
 	s = m.TableName
 	return
@@ -72,6 +98,7 @@ func (m *Model) Objects() orm.Objects {
 func (m *Model) ObjectsWith(arg *orm.ArgObjects) orm.Objects {
 	ob := new(Objects)
 	ob.Model = m
+	ob.ctx = m.ctx
 	ob.count = -1
 	ob.nums = -1
 	if m.log != nil {
@@ -104,7 +131,20 @@ func (m *Model) GetTable() (ret string) {
 
 // Drop table
 func (m *Model) Drop() (err error) {
-	m.Result, err = m.DatabaseSQL.DB.Exec(fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, m.TableName))
+	defer func() { err = orm.ContextErr(m.ctx, err) }()
+	// 全文索引的影子表不随表删除
+	var ftsLis []string
+	if err = m.DatabaseSQL.DB.SelectContext(m.getContext(), &ftsLis,
+		`SELECT name FROM sqlite_master WHERE type = 'table' AND sql LIKE ?`,
+		fmt.Sprintf(`CREATE VIRTUAL TABLE %%content='%s')`, m.TableName)); err != nil {
+		return
+	}
+	for _, fts := range ftsLis {
+		if _, err = m.DatabaseSQL.DB.ExecContext(m.getContext(), fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, fts)); err != nil {
+			return
+		}
+	}
+	m.Result, err = m.DatabaseSQL.DB.ExecContext(m.getContext(), fmt.Sprintf(`DROP TABLE IF EXISTS "%s"`, m.TableName))
 	return
 }
 
@@ -116,6 +156,8 @@ func (m *Model) ContigParse(st interface{}) (err error) {
 	var (
 		columns      []string
 		values       []string
+		upColumns    []string // created字段不更新
+		upValues     []string
 		fieldInfoLis []*orm.FieldInfo
 	)
 	if fieldInfoLis, err = orm.StructModelInfo(st); err != nil {
@@ -125,10 +167,19 @@ func (m *Model) ContigParse(st interface{}) (err error) {
 		if f.Name != m.AutoIncrementField {
 			columns = append(columns, "\""+f.Name+"\"")
 			values = append(values, ":"+f.Name)
+			if !f.Created {
+				upColumns = append(upColumns, "\""+f.Name+"\"")
+				if f.Version {
+					upValues = append(upValues, "COALESCE(\""+f.Name+"\", 0)+1")
+				} else {
+					upValues = append(upValues, ":"+f.Name)
+				}
+			}
 		}
 	}
 	m.ContigInsert = fmt.Sprintf(`"%s" (%s) VALUES (%s)`, m.TableName, strings.Join(columns, ", "), strings.Join(values, ", "))
-	m.ContigUpdate = fmt.Sprintf(`"%s" SET (%s) = (%s)`, m.TableName, strings.Join(columns, ", "), strings.Join(values, ", "))
+	m.ContigUpdate = fmt.Sprintf(`"%s" SET (%s) = (%s)`, m.TableName, strings.Join(upColumns, ", "), strings.Join(upValues, ", "))
+	m.DatabaseSQL.models.Register(m.TableName, fieldInfoLis)
 	return
 }
 
@@ -139,6 +190,7 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		colCmdLis    = []string{}
 		primaryKey   = ""
 		primaryCmd   = ""
+		fkCmdLis     []string
 		colCmd       = ""
 		tableExist   = 0
 		fieldExist   = make(map[string]bool)
@@ -159,6 +211,7 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		query = "SELECT * FROM " + m.TableName
 		if rows, _err := m.DatabaseSQL.DB.Query(query); _err == nil {
 			columnLis, _ = rows.Columns()
+			_ = rows.Close()
 		} else {
 			err = _err
 			m.log.Errorf("[ensure-column] select column err: %v", err)
@@ -214,6 +267,11 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 				primaryCmd = fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(keys, ", "))
 			}
 		}
+		// fk: 只能在建表时定义
+		if f.Fk != nil {
+			fkCmdLis = append(fkCmdLis, fmt.Sprintf(`CONSTRAINT "%s_%s_fkey" FOREIGN KEY ("%s") REFERENCES "%s" ("%s")%s`,
+				m.TableName, f.Name, f.Name, f.Fk.Table, f.Fk.Column, f.Fk.Action()))
+		}
 
 		//
 		var (
@@ -247,6 +305,17 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		if f.DefaultVal == nil {
 			cmdDef = `NULL`
 		}
+		cmdDef = orm.ColumnDefine(f, cmdDef)
+		if m.plan != nil {
+			m.plan.ColumnExpect(f, strings.TrimPrefix(cmdAdd, fmt.Sprintf(`"%s" `, f.Name)), cmdDef)
+		}
+		if len(f.Check) > 0 {
+			cmdDef += fmt.Sprintf(` CHECK (%s)`, f.Check)
+		}
+		if len(f.Comment) > 0 {
+			// sqlite无字段注释, 以注释形式保留在建表语句中
+			cmdDef += fmt.Sprintf(` /* %s */`, strings.Replace(f.Comment, "*/", "* /", -1))
+		}
 		//
 		if tableExist == 1 {
 			// add
@@ -335,12 +404,13 @@ func (m *Model) EnsureColumn(st interface{}) (err error) {
 		if len(primaryCmd) > 0 {
 			colCmdLis = append(colCmdLis, primaryCmd)
 		}
+		colCmdLis = append(colCmdLis, fkCmdLis...)
 		colCmd = fmt.Sprintf("CREATE TABLE \"%s\" (\n%s\n);", m.TableName, strings.Join(colCmdLis, ",\n"))
 	}
 
 	// exec
 	if len(colCmdLis) > 0 {
-		if m.Result, err = m.DatabaseSQL.DB.Exec(colCmd); err != nil {
+		if err = m.ensureExec(colCmd); err != nil {
 			m.log.Errorf(`[ensure-column] 
 %s err: %v`, colCmd, err)
 			return
@@ -389,6 +459,10 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 		indexLis       []*SQLIndex // index and unique
 		indexMethod    = ""        // both index and unique method
 		indexFiledType = ""        // field type
+		indexName      = ""        // 指定的索引名
+		indexWhere     = ""        // 部分索引的条件
+		indexInclude   []string    // 覆盖索引的附加字段
+		indexUnique    = false     // Key及Expr为唯一索引
 	)
 
 	// filed type
@@ -403,18 +477,49 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 		}
 	}
 
+	// name, unique, include, where
+	if s, ok := indexMap["Name"].(string); ok {
+		indexName = s
+	}
+	if v, ok := indexMap["Unique"].(bool); ok {
+		indexUnique = v
+	}
+	if v, ok := indexMap["Include"].([]string); ok {
+		indexInclude = v
+	}
+	switch w := indexMap["Where"].(type) {
+	case orm.M:
+		indexWhere, err = orm.IndexWhere(driverName, w)
+	case map[string]interface{}:
+		indexWhere, err = orm.IndexWhere(driverName, w)
+	}
+	if err != nil {
+		return
+	}
+
 	// keys
 	if i, ok := indexMap["Key"]; ok {
 		if v, ok := i.([]string); ok {
 			indexLis = append(indexLis, &SQLIndex{
 				Key:    v,
-				Unique: false,
+				Unique: indexUnique,
 				Kind:   indexFiledType,
 				Method: indexMethod,
 			})
 		}
 	}
 
+	// expression
+	if v, ok := indexMap["Expr"].([]string); ok {
+		indexLis = append(indexLis, &SQLIndex{
+			Key:    v,
+			Unique: indexUnique,
+			Kind:   indexFiledType,
+			Method: indexMethod,
+			Expr:   true,
+		})
+	}
+
 	// unique
 	if i, ok := indexMap["Unique"]; ok {
 		if v, ok := i.([]string); ok {
@@ -426,6 +531,12 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 			})
 		}
 	}
+	for _, index := range indexLis {
+		index.Where, index.Include = indexWhere, indexInclude
+	}
+	if len(indexName) > 0 && len(indexLis) == 1 {
+		indexLis[0].Name = indexName
+	}
 
 	//
 	for _, index := range indexLis {
@@ -437,16 +548,36 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 			}
 			var keys []string
 			for _, k := range index.Key {
+				if index.Expr {
+					keys = append(keys, "("+k+")")
+					continue
+				}
+				k, desc := orm.IndexKey(k)
 				k = strings.ToLower(k)
-				keys = append(keys, "\""+k+"\"")
+				if desc {
+					keys = append(keys, "\""+k+"\""+" DESC")
+				} else {
+					keys = append(keys, "\""+k+"\"")
+				}
 			}
 			//if len(index.Method) == 0 {
 			//	index.Method = "USING btree" // 默认 btree
 			//}
-			indexCmd := fmt.Sprintf("CREATE %s IF NOT EXISTS \"%s_%s\" ON \"%s\" %s (%s);", indexType,
-				m.TableName, strings.Join(index.Key, "_"), m.TableName, index.Method, strings.Join(keys, ", "))
+			indexKey := index.Name
+			if len(indexKey) == 0 {
+				indexKey = orm.IndexName(m.TableName, index.Key)
+			}
+			indexCmd := fmt.Sprintf("CREATE %s IF NOT EXISTS \"%s\" ON \"%s\" %s (%s)", indexType,
+				indexKey, m.TableName, index.Method, strings.Join(keys, ", "))
+			if len(index.Where) > 0 {
+				indexCmd += " WHERE " + index.Where
+			}
+			indexCmd += ";"
+			if m.plan != nil && m.plan.IndexExpect(indexKey, index.Key, index.Unique) {
+				continue
+			}
 
-			if m.Result, err = m.DatabaseSQL.DB.Exec(indexCmd); err != nil {
+			if err = m.ensureExec(indexCmd); err != nil {
 				m.log.Errorf(`[ensure-index] %s, err: %v`, indexCmd, err)
 				return
 
@@ -458,8 +589,85 @@ func (m *Model) EnsureIndex(indexMap orm.Index) (err error) {
 	return
 }
 
+// EnsureForeignKey 确认外键(sqlite只能在建表时定义外键, 见EnsureColumn; 已有的表需EnsureWith重建)
+func (m *Model) EnsureForeignKey(f *orm.FieldInfo) (err error) {
+	var (
+		fkey = fmt.Sprintf(`%s_%s_fkey`, m.TableName, f.Name)
+	)
+	if m.plan != nil {
+		m.plan.FkExpect(fkey, f)
+		return
+	}
+	var exist = 0
+	if err = m.DatabaseSQL.DB.Get(&exist, `SELECT COUNT(*) FROM pragma_foreign_key_list(?)
+WHERE "from" = ? AND "table" = ? AND "to" = ?`, m.TableName, f.Name, f.Fk.Table, f.Fk.Column); err != nil {
+		m.log.Errorf("[ensure-fk] check fk exist err: %v", err)
+		return
+	} else if exist == 0 {
+		m.log.Warnf(`[ensure-fk] "%s" not created: sqlite can not add foreign key to exist table, use EnsureWith`, fkey)
+	}
+	return
+}
+
+// EnsureTextIndex 确认全文索引: 以本表为content的FTS5影子表, 由触发器同步; 需以sqlite_fts5标签编译go-sqlite3
+func (m *Model) EnsureTextIndex(f *orm.FieldInfo) (err error) {
+	var (
+		fts    = fmt.Sprintf(`%s_%s_fts`, m.TableName, f.Name)
+		exist  = 0
+		cmdLis = []string{
+			fmt.Sprintf(`CREATE VIRTUAL TABLE IF NOT EXISTS "%s" USING fts5("%s", content='%s')`,
+				fts, f.Name, m.TableName),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS "%s_ai" AFTER INSERT ON "%s" BEGIN
+INSERT INTO "%s"(rowid, "%s") VALUES (new.rowid, new."%s"); END`, fts, m.TableName, fts, f.Name, f.Name),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS "%s_ad" AFTER DELETE ON "%s" BEGIN
+INSERT INTO "%s"("%s", rowid, "%s") VALUES ('delete', old.rowid, old."%s"); END`, fts, m.TableName, fts, fts, f.Name, f.Name),
+			fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS "%s_au" AFTER UPDATE ON "%s" BEGIN
+INSERT INTO "%s"("%s", rowid, "%s") VALUES ('delete', old.rowid, old."%s");
+INSERT INTO "%s"(rowid, "%s") VALUES (new.rowid, new."%s"); END`,
+				fts, m.TableName, fts, fts, f.Name, f.Name, fts, f.Name, f.Name),
+			// 索引已有的记录
+			fmt.Sprintf(`INSERT INTO "%s"("%s") VALUES ('rebuild')`, fts, fts),
+		}
+	)
+	if m.plan != nil && m.plan.IndexExpect(fts, []string{f.Name}, false) {
+		return
+	}
+	// 触发器随表删除, 重建表后需重新创建并重建索引
+	if err = m.DatabaseSQL.DB.Get(&exist,
+		`SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name=?`, fts+"_ai"); err != nil {
+		m.log.Errorf("[ensure-text] check trigger exist err: %v", err)
+		return
+	} else if exist > 0 {
+		return
+	}
+
+	// run
+	for _, cmd := range cmdLis {
+		if err = m.ensureExec(cmd); err != nil {
+			m.log.Errorf(`[ensure-text] "%s" err: %v`, cmd, err)
+			return
+		}
+		m.log.Debug(cmd)
+	}
+	return
+}
+
 // Ensure is sync table with struct
 func (m *Model) Ensure(st interface{}) (err error) {
+	if err = m.ensure(st); err != nil {
+		return
+	}
+	// contig parse and cache
+	m.ContigInsert = ""
+	m.ContigUpdate = ""
+	if err = m.ContigParse(st); err != nil {
+		return
+	}
+	return orm.ModelInfoSave(m.DatabaseSQL, m.TableName, m.info())
+}
+
+// ensure 同步字段及索引
+func (m *Model) ensure(st interface{}) (err error) {
 	// syncDB
 	if err = m.EnsureColumn(st); err != nil {
 		return
@@ -497,12 +705,340 @@ func (m *Model) Ensure(st interface{}) (err error) {
 			return
 		}
 	}
-	// contig parse and cache
-	m.ContigInsert = ""
-	m.ContigUpdate = ""
-	if err = m.ContigParse(st); err != nil {
+	// text index
+	for _, f := range fieldInfoLis {
+		if !f.IndexText {
+			continue
+		}
+		if err = m.EnsureTextIndex(f); err != nil {
+			return
+		}
+	}
+	// foreign key
+	for _, f := range fieldInfoLis {
+		if f.Fk == nil {
+			continue
+		}
+		if err = m.EnsureForeignKey(f); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// ensureExec 执行Ensure的语句, 预览时只记录
+func (m *Model) ensureExec(cmd string) (err error) {
+	if m.plan != nil {
+		m.plan.DDL = append(m.plan.DDL, cmd)
+		return
+	}
+	m.Result, err = m.DatabaseSQL.DB.Exec(cmd)
+	return
+}
+
+// Plan 对比struct与库中的表结构, 返回Ensure将执行的语句, 不执行
+func (m *Model) Plan(st interface{}) (ret *orm.Plan, err error) {
+	var (
+		mp   = m.Copy()
+		info *orm.TableInfo
+	)
+	ret = orm.NewPlan(m.TableName)
+	if info, err = m.Inspect(); err == orm.ErrSyncTableNotExist {
+		err = nil
+	} else if err != nil {
+		return
+	} else {
+		ret.TableExist = true
+		ret.TableActual(info)
+	}
+	// 按Ensure的流程记录语句
+	mp.plan = ret
+	if err = mp.ensure(st); err != nil {
+		return
+	}
+	ret.Diff()
+	return
+}
+
+// Inspect 读取库中表的字段及索引; 只取CREATE INDEX创建的索引, 主键见字段
+func (m *Model) Inspect() (ret *orm.TableInfo, err error) {
+	var (
+		tableExist = 0
+		pkCount    = 0
+	)
+	if err = m.DatabaseSQL.DB.Get(&tableExist,
+		`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name=?`, m.TableName); err != nil {
+		m.log.Errorf("[inspect] check table exist err: %v", err)
+		return
+	} else if tableExist == 0 {
+		err = orm.ErrSyncTableNotExist
+		return
+	}
+	ret = &orm.TableInfo{Name: m.TableName}
+	// columns
+	var columnLis []struct {
+		Name    string         `db:"name"`
+		Kind    string         `db:"type"`
+		NotNull int            `db:"notnull"`
+		Default sql.NullString `db:"dflt_value"`
+		Pk      int            `db:"pk"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&columnLis,
+		`SELECT name, type, "notnull", dflt_value, pk FROM pragma_table_info(?) ORDER BY cid`, m.TableName); err != nil {
+		m.log.Errorf("[inspect] select column err: %v", err)
+		return
+	}
+	for _, c := range columnLis {
+		if c.Pk > 0 {
+			pkCount++
+		}
+	}
+	for _, c := range columnLis {
+		ret.Columns = append(ret.Columns, &orm.ColumnInfo{
+			Name:    c.Name,
+			Kind:    c.Kind,
+			Null:    c.NotNull == 0 && c.Pk == 0, // sqlite的主键字段未声明NOT NULL时也显示可空
+			Default: c.Default.String,
+			Primary: c.Pk > 0,
+			Serial:  c.Pk > 0 && pkCount == 1 && strings.ToUpper(c.Kind) == "INTEGER", // rowid别名
+		})
+	}
+	// indexes
+	var indexLis []struct {
+		Name   string `db:"name"`
+		Unique int    `db:"unique"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&indexLis,
+		`SELECT name, "unique" FROM pragma_index_list(?) WHERE origin = 'c' ORDER BY name`, m.TableName); err != nil {
+		m.log.Errorf("[inspect] select index err: %v", err)
 		return
 	}
+	for _, idx := range indexLis {
+		var keys []sql.NullString
+		if err = m.DatabaseSQL.DB.Select(&keys,
+			`SELECT name FROM pragma_index_info(?) ORDER BY seqno`, idx.Name); err != nil {
+			m.log.Errorf("[inspect] select index key err: %v", err)
+			return
+		}
+		var (
+			keyLis  = make([]string, len(keys))
+			exprLis []string
+		)
+		for i, k := range keys {
+			if !k.Valid {
+				// 表达式字段无名称, 由建索引的语句取得
+				if exprLis == nil {
+					var indexSQL string
+					if err = m.DatabaseSQL.DB.Get(&indexSQL,
+						`SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?`, idx.Name); err != nil {
+						m.log.Errorf("[inspect] select index sql err: %v", err)
+						return
+					}
+					exprLis = indexExprSplit(indexSQL)
+				}
+				if i < len(exprLis) {
+					keyLis[i] = exprLis[i]
+				}
+				continue
+			}
+			keyLis[i] = k.String
+		}
+		ret.Indexes = append(ret.Indexes, &orm.IndexInfo{Name: idx.Name, Key: keyLis, Unique: idx.Unique == 1})
+	}
+	// 全文索引: FTS5影子表, 按EnsureTextIndex的规则命名
+	for _, c := range columnLis {
+		var (
+			fts   = fmt.Sprintf(`%s_%s_fts`, m.TableName, c.Name)
+			exist = 0
+		)
+		if err = m.DatabaseSQL.DB.Get(&exist,
+			`SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name=?`, fts); err != nil {
+			m.log.Errorf("[inspect] check fts exist err: %v", err)
+			return
+		} else if exist > 0 {
+			ret.Indexes = append(ret.Indexes, &orm.IndexInfo{Name: fts, Key: []string{c.Name}, Text: true})
+		}
+	}
+	// foreign keys: sqlite的外键无名称, 按Ensure的规则命名
+	var fkLis []struct {
+		Column    string         `db:"from"`
+		RefTable  string         `db:"table"`
+		RefColumn sql.NullString `db:"to"`
+		OnDelete  string         `db:"on_delete"`
+		OnUpdate  string         `db:"on_update"`
+	}
+	if err = m.DatabaseSQL.DB.Select(&fkLis,
+		`SELECT "from", "table", "to", on_delete, on_update FROM pragma_foreign_key_list(?) ORDER BY id, seq`,
+		m.TableName); err != nil {
+		m.log.Errorf("[inspect] select fk err: %v", err)
+		return
+	}
+	for _, fk := range fkLis {
+		ret.Fks = append(ret.Fks, &orm.FkInfo{Name: fmt.Sprintf(`%s_%s_fkey`, m.TableName, fk.Column),
+			Column: fk.Column, RefTable: fk.RefTable, RefColumn: fk.RefColumn.String,
+			OnDelete: fk.OnDelete, OnUpdate: fk.OnUpdate})
+	}
+	return
+}
+
+// indexExprSplit 取出建索引语句中的各字段, 如CREATE INDEX "i" ON "t" ((lower(a)), "b" DESC) -> lower(a), "b" DESC
+func indexExprSplit(indexSQL string) (lis []string) {
+	var (
+		depth = 0
+		begin = -1
+	)
+	if i := strings.Index(strings.ToUpper(indexSQL), " ON "); i > 0 {
+		begin = strings.Index(indexSQL[i:], "(")
+		if begin < 0 {
+			return
+		}
+		begin += i + 1
+	} else {
+		return
+	}
+	for i, start := begin, begin; i < len(indexSQL); i++ {
+		switch indexSQL[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			fallthrough
+		case ',':
+			if depth > 0 {
+				continue
+			}
+			k := strings.TrimSpace(indexSQL[start:i])
+			if strings.HasPrefix(k, "(") && strings.HasSuffix(k, ")") {
+				k = k[1 : len(k)-1]
+			}
+			lis = append(lis, k)
+			if indexSQL[i] == ')' {
+				return
+			}
+			start = i + 1
+		}
+	}
+	return
+}
+
+// EnsureWith 同Ensure, 并按参数修改/删除/重命名已有的字段及索引
+func (m *Model) EnsureWith(st interface{}, opt *orm.ArgEnsure) (err error) {
+	var (
+		p *orm.Plan
+	)
+	if opt == nil {
+		return m.Ensure(st)
+	}
+	if p, err = m.Plan(st); err != nil {
+		return
+	}
+	// rename: sqlite 3.25+
+	if opt.Rename && len(p.ColumnRenamed) > 0 {
+		for _, c := range p.ColumnRenamed {
+			if err = m.ensureWithExec(fmt.Sprintf(`ALTER TABLE "%s" RENAME COLUMN "%s" TO "%s";`,
+				m.TableName, c.Was, c.Name)); err != nil {
+				return
+			}
+		}
+		if p, err = m.Plan(st); err != nil {
+			return
+		}
+	}
+	// drop: 先删索引, 有索引的字段不可删除; sqlite 3.35+
+	if opt.DropIndex {
+		for _, idx := range p.IndexExtra {
+			if err = m.ensureWithExec(fmt.Sprintf(`DROP INDEX IF EXISTS "%s";`, idx.Name)); err != nil {
+				return
+			}
+		}
+	}
+	if opt.DropColumn {
+		for _, c := range p.ColumnExtra {
+			if err = m.ensureWithExec(fmt.Sprintf(`ALTER TABLE "%s" DROP COLUMN "%s";`,
+				m.TableName, c.Name)); err != nil {
+				return
+			}
+		}
+	}
+	// alter: 已有表补外键也需重建
+	if opt.AlterColumn && (len(p.ColumnChanged) > 0 || len(p.FkMissing) > 0) {
+		if err = m.rebuild(st, p); err != nil {
+			return
+		}
+	}
+	return m.Ensure(st)
+}
+
+// rebuild sqlite不支持修改字段及添加外键, 按struct建新表, 复制数据后替换旧表; 索引由随后的Ensure重建
+func (m *Model) rebuild(st interface{}, p *orm.Plan) (err error) {
+	var (
+		mt           = m.Copy()
+		skip         = make(map[string]bool)
+		fieldInfoLis []*orm.FieldInfo
+		colLis       []string
+	)
+	mt.TableName = m.TableName + "_sorm_rebuild"
+	mt.AutoIncrementField = ""
+	for _, c := range p.ColumnMissing {
+		skip[c.Name] = true
+	}
+	for _, c := range p.ColumnRenamed {
+		skip[c.Name] = true
+	}
+	if err = mt.Drop(); err != nil {
+		return
+	}
+	if err = mt.EnsureColumn(st); err != nil {
+		return
+	}
+	if fieldInfoLis, err = orm.StructModelInfo(st); err != nil {
+		return
+	}
+	for _, f := range fieldInfoLis {
+		if !skip[f.Name] {
+			colLis = append(colLis, "\""+f.Name+"\"")
+		}
+	}
+	// 替换时关闭外键约束, 避免DROP TABLE级联删除引用表的数据; PRAGMA只对当前连接生效, 结束后恢复
+	var (
+		ctx  = m.getContext()
+		conn *sql.Conn
+		fkOn = 0
+	)
+	if conn, err = m.DatabaseSQL.DB.Conn(ctx); err != nil {
+		return
+	}
+	defer conn.Close()
+	if err = conn.QueryRowContext(ctx, `PRAGMA foreign_keys;`).Scan(&fkOn); err != nil {
+		return
+	}
+	defer conn.ExecContext(ctx, fmt.Sprintf(`PRAGMA foreign_keys = %d;`, fkOn))
+	for _, cmd := range []string{
+		`PRAGMA foreign_keys = OFF;`,
+		fmt.Sprintf(`INSERT INTO "%s" (%s) SELECT %s FROM "%s";`,
+			mt.TableName, strings.Join(colLis, ", "), strings.Join(colLis, ", "), m.TableName),
+		fmt.Sprintf(`DROP TABLE "%s";`, m.TableName),
+		fmt.Sprintf(`ALTER TABLE "%s" RENAME TO "%s";`, mt.TableName, m.TableName),
+	} {
+		if _, err = conn.ExecContext(ctx, cmd); err != nil {
+			m.log.Errorf(`[ensure-with] %s err: %v`, cmd, err)
+			return
+		}
+		m.log.Infof(`[ensure-with] %s`, cmd)
+	}
+	return
+}
+
+// ensureWithExec 执行EnsureWith的语句
+func (m *Model) ensureWithExec(cmd string) (err error) {
+	if err = m.ensureExec(cmd); err != nil {
+		m.log.Errorf(`[ensure-with] %s err: %v`, cmd, err)
+		return
+	}
+	m.log.Infof(`[ensure-with] %s`, cmd)
 	return
 }
 
@@ -511,14 +1047,53 @@ func (m *Model) Begin() (orm.Trans, error) {
 	return m.BeginWith(nil)
 }
 
+// BeginNested 在parent中以保存点开启子事务
+func (m *Model) BeginNested(parent orm.Trans) (ret orm.Trans, err error) {
+	if parent == nil {
+		return m.BeginWith(nil)
+	}
+	t, ok := parent.(*Trans)
+	if !ok || t == nil || t.db != m.DatabaseSQL {
+		return nil, orm.ErrTransInvalid
+	}
+	var c *Trans
+	if c, err = t.nested(); err != nil {
+		return
+	}
+	return c, nil
+}
+
 // BeginWith 事务
 func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 	var (
-		t = &Trans{}
+		t = &Trans{db: m.DatabaseSQL}
 	)
-	if t.Tx, err = m.DatabaseSQL.DB.Beginx(); err != nil {
-		return t, err
+	if arg != nil && arg.Deferrable {
+		// 驱动不支持的参数, 不静默忽略
+		return t, orm.ErrTransOptionNotSupport
+	}
+	ex := m.DatabaseSQL.DB
+	if arg != nil && len(arg.Lock) > 0 {
+		if ex, err = m.DatabaseSQL.txDB(arg.Lock); err != nil {
+			return t, err
+		}
+	}
+	if arg != nil && arg.ReadOnly {
+		// 只读为连接级设置, 固定连接以便事务结束后恢复
+		if t.conn, err = ex.Connx(m.getContext()); err != nil {
+			return t, orm.ContextErr(m.ctx, err)
+		}
+		t.Tx, err = t.conn.BeginTxx(m.getContext(), nil)
+	} else {
+		t.Tx, err = ex.BeginTxx(m.getContext(), nil)
+	}
+	if err != nil {
+		if t.conn != nil {
+			_ = t.conn.Close()
+		}
+		return t, orm.ContextErr(m.ctx, err)
 	}
+	t.ctx = m.ctx
 
 	// 记录调用处
 	if pc, file, line, ok := runtime.Caller(2); ok {
@@ -569,8 +1144,21 @@ func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 		}
 	}
 
+	// 只读: 连接级设置, 事务结束后在固定的连接上恢复
+	if arg.ReadOnly {
+		if _, err = t.Exec("PRAGMA query_only = ON"); err != nil {
+			_ = t.Rollback()
+			return
+		}
+		t.sessionReset = append(t.sessionReset, "PRAGMA query_only = OFF")
+	}
+
 	// 超时回滚
-	t.timer = time.NewTimer(orm.TransTimeout)
+	timeout := orm.TransTimeout
+	if arg.Timeout > 0 {
+		timeout = arg.Timeout
+	}
+	t.timer = time.NewTimer(timeout)
 	go func() {
 		defer func() {
 			if _err := recover(); _err != nil {
@@ -580,10 +1168,12 @@ func (m *Model) BeginWith(arg *orm.ArgTrans) (ret orm.Trans, err error) {
 
 		<-t.timer.C
 		// timeout
-		if !t.isFinish {
+		if t.finish(transExpired) {
+			// 只有CAS成功的一方写入错误, 之后Error/Commit均返回超时
+			t.errSet(orm.ErrTransTimeout)
 			// log sql history
 			m.log.Errorf("[trans-timeout] tableName=%s sql=%s", m.TableName, t.debugReport())
-			_ = m.Rollback(t)
+			_ = t.rollback(orm.ErrTransTimeout)
 		}
 	}()
 
@@ -624,16 +1214,34 @@ func (m *Model) AutoTrans(t orm.Trans) (err error) {
 
 // Exec 执行语句
 func (m *Model) Exec(query string, args ...interface{}) (result orm.Result, err error) {
-	result, err = m.DatabaseSQL.DB.Exec(query, args...)
+	result, err = m.DatabaseSQL.DB.ExecContext(m.getContext(), query, args...)
+	err = orm.ContextErr(m.ctx, err)
 	return
 }
 
 // Exec 执行语句
 func (m *Model) Select(dest interface{}, query string, args ...interface{}) (err error) {
-	err = m.DatabaseSQL.DB.Select(dest, query, args...)
+	err = m.DatabaseSQL.DB.SelectContext(m.getContext(), dest, query, args...)
+	err = orm.ContextErr(m.ctx, err)
 	return
 }
 
+// WithContext 返回绑定上下文的model
+func (m *Model) WithContext(ctx context.Context) (ret orm.Model) {
+	r := m.Copy()
+	r.VirtualSQL = m.VirtualSQL
+	r.ctx = ctx
+	return r
+}
+
+// getContext 取上下文
+func (m *Model) getContext() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
+
 // With 设置日志级别
 func (m *Model) With(arg *orm.ArgModel) (ret orm.Model) {
 	r := m.Copy()
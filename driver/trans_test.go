@@ -6,9 +6,11 @@ import (
 
 	"testing"
 
+	"context"
 	"database/sql/driver"
 	"encoding/json"
 	"math/rand"
+	"reflect"
 	"time"
 )
 
@@ -319,3 +321,385 @@ func Benchmark_Trans(b *testing.B) {
 	// log
 	b.Logf(`SUCCESS %d/%d`, numAll, b.N)
 }
+
+// 以保存点嵌套事务
+func Test_TransNested(t *testing.T) {
+	type nestedFlow struct {
+		UID  string `sorm:"primary;size(36)" json:"uid"`
+		Name string `sorm:"size(36)" json:"name"`
+	}
+	var (
+		db       = testGetDB()
+		m        = db.Model("test_trans_nested")
+		tx       orm.Trans
+		child    orm.Trans
+		promised error
+		num      int
+		err      error
+	)
+	if db.DriverName() == orm.DriverNameMongo {
+		t.Skip("mongo not support savepoint")
+	}
+	if err = m.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Ensure(&nestedFlow{}); err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = m.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.Rollback(tx)
+	if err = m.Objects().TCreate(&nestedFlow{UID: "a", Name: "outer"}, tx); err != nil {
+		t.Fatal(err)
+	}
+
+	// 子事务回滚, 外层事务不受影响
+	if child, err = m.BeginNested(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Objects().TCreate(&nestedFlow{UID: "b", Name: "rollback"}, child); err != nil {
+		t.Fatal(err)
+	}
+	if err = child.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 子事务出错, 提交时回滚至保存点并返回错误
+	if child, err = m.BeginNested(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Objects().TCreate(&nestedFlow{UID: "c", Name: "dup"}, child); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Objects().TCreate(&nestedFlow{UID: "a", Name: "dup"}, child); err == nil {
+		t.Fatal("duplicate primary key expected")
+	}
+	if err = child.Commit(); err == nil {
+		t.Fatal("nested commit with error expected")
+	} else if tx.Error() != nil {
+		t.Fatalf("outer trans broken: %v", tx.Error())
+	}
+
+	// 子事务提交, 绑定的函数随外层事务触发
+	if child, err = m.BeginNested(tx); err != nil {
+		t.Fatal(err)
+	}
+	_ = child.PromiseAdd(func(err error) { promised = err })
+	if err = m.Objects().TCreate(&nestedFlow{UID: "d", Name: "inner"}, child); err != nil {
+		t.Fatal(err)
+	}
+	if err = child.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 外层事务直接使用保存点
+	if err = tx.Savepoint("before_e"); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Objects().TCreate(&nestedFlow{UID: "e", Name: "undo"}, tx); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.RollbackTo("before_e"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Release("before_e"); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Savepoint("bad name;"); err != orm.ErrTransSavepointInvalid {
+		t.Fatalf("invalid savepoint: %v", err)
+	}
+
+	promised = orm.ErrTransEmpty
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	} else if promised != nil {
+		t.Fatalf("promise after commit: %v", promised)
+	}
+	if num, err = m.Objects().Count(); err != nil {
+		t.Fatal(err)
+	} else if num != 2 {
+		t.Fatalf(`"%v" nested count %d`, db, num)
+	}
+}
+
+// 数据库级事务, 跨多个model
+func Test_TransDatabase(t *testing.T) {
+	type dbFlow struct {
+		UID  string `sorm:"primary;size(36)" json:"uid"`
+		Name string `sorm:"size(36)" json:"name"`
+	}
+	var (
+		db  = testGetDB()
+		m0  = db.Model("test_trans_db0")
+		m1  = db.Model("test_trans_db1")
+		tx  orm.Trans
+		lis []*dbFlow
+		num int
+		err error
+	)
+	if db.DriverName() == orm.DriverNameMongo {
+		t.Skip("mongo not support trans")
+	}
+	for _, m := range []orm.Model{m0, m1} {
+		if err = m.Drop(); err != nil {
+			t.Fatal(err)
+		}
+		if err = m.Ensure(&dbFlow{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 回滚后两表均无记录
+	if tx, err = db.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	if err = m0.Objects().TCreate(&dbFlow{UID: "a", Name: "m0"}, tx); err != nil {
+		t.Fatal(err)
+	}
+	if err = m1.Objects().TCreate(&dbFlow{UID: "a", Name: "m1"}, tx); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []orm.Model{m0, m1} {
+		if num, err = m.Objects().Count(); err != nil {
+			t.Fatal(err)
+		} else if num != 0 {
+			t.Fatalf(`"%v" count after rollback %d`, db, num)
+		}
+	}
+
+	// 提交
+	if tx, err = db.BeginWith(&orm.ArgTrans{Level: orm.TransSerializable}); err != nil {
+		t.Fatal(err)
+	}
+	if err = m0.Objects().TCreate(&dbFlow{UID: "b", Name: "m0"}, tx); err != nil {
+		t.Fatal(err)
+	}
+	if err = m1.Objects().Filter(orm.M{"uid": "b"}).TAll(&lis, tx); err != nil {
+		t.Fatal(err)
+	}
+	if err = m1.Objects().TCreate(&dbFlow{UID: "b", Name: "m1"}, tx); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []orm.Model{m0, m1} {
+		if num, err = m.Objects().Count(); err != nil {
+			t.Fatal(err)
+		} else if num != 1 {
+			t.Fatalf(`"%v" count after commit %d`, db, num)
+		}
+	}
+
+	// 非本库的事务
+	foreign := &mongo.Trans{}
+	if err = m0.Objects().TAll(&lis, foreign); err != orm.ErrTransInvalid {
+		t.Fatalf("foreign trans: %v", err)
+	}
+	if err = m0.Objects().Filter(orm.M{"uid": "b"}).TUpdate(orm.M{"name": "x"}, foreign); err != orm.ErrTransInvalid {
+		t.Fatalf("foreign trans: %v", err)
+	}
+	if _, err = m0.BeginNested(foreign); err != orm.ErrTransInvalid {
+		t.Fatalf("foreign trans: %v", err)
+	}
+	if err = m0.Objects().TIter(foreign).Err(); err != orm.ErrTransInvalid {
+		t.Fatalf("foreign trans: %v", err)
+	}
+	if err = m0.Objects().Filter(orm.M{"uid": "b"}).TLockUpdate(foreign); err != orm.ErrTransInvalid {
+		t.Fatalf("foreign trans: %v", err)
+	}
+}
+
+// 事务超时回滚
+func Test_TransTimeout(t *testing.T) {
+	type timeoutFlow struct {
+		UID string `sorm:"primary;size(36)" json:"uid"`
+	}
+	var (
+		db  = testGetDB()
+		m   = db.Model("test_trans_timeout")
+		tx  orm.Trans
+		num int
+		err error
+	)
+	if db.DriverName() == orm.DriverNameMongo {
+		t.Skip("mongo not support trans")
+	}
+	if err = m.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Ensure(&timeoutFlow{}); err != nil {
+		t.Fatal(err)
+	}
+	if tx, err = m.BeginWith(&orm.ArgTrans{Timeout: time.Millisecond * 50}); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Objects().TCreate(&timeoutFlow{UID: "a"}, tx); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Millisecond * 200)
+	if err = m.Objects().TCreate(&timeoutFlow{UID: "b"}, tx); err != orm.ErrTransTimeout {
+		t.Fatalf("create after timeout: %v", err)
+	}
+	// 超时回滚后导出的TxError同样为超时错误
+	if v := reflect.Indirect(reflect.ValueOf(tx)).FieldByName("TxError"); !v.IsValid() || v.Interface() != orm.ErrTransTimeout {
+		t.Fatalf("tx error after timeout: %v", v)
+	}
+	if err = tx.Commit(); err != orm.ErrTransTimeout {
+		t.Fatalf("commit after timeout: %v", err)
+	}
+	if num, err = m.Objects().Count(); err != nil {
+		t.Fatal(err)
+	} else if num != 0 {
+		t.Fatalf(`"%v" count after timeout %d`, db, num)
+	}
+
+	// 单条语句超时
+	if db.DriverName() != orm.DriverNamePostgres {
+		return
+	}
+	if tx, err = db.BeginWith(&orm.ArgTrans{StatementTimeout: time.Millisecond * 50}); err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	if _, err = tx.Exec("SELECT pg_sleep(1)"); err == nil {
+		t.Fatal("statement timeout expected")
+	}
+}
+
+// RunInTrans提交及回滚
+func Test_TransRun(t *testing.T) {
+	type runFlow struct {
+		UID string `sorm:"primary;size(36)" json:"uid"`
+	}
+	var (
+		db  = testGetDB()
+		m   = db.Model("test_trans_run")
+		num int
+		err error
+	)
+	if db.DriverName() == orm.DriverNameMongo {
+		t.Skip("mongo not support trans")
+	}
+	if err = m.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Ensure(&runFlow{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = orm.RunInTrans(db, nil, func(tx orm.Trans) error {
+		return m.Objects().TCreate(&runFlow{UID: "a"}, tx)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err = orm.RunInTrans(m, &orm.ArgRetry{Trans: &orm.ArgTrans{Level: orm.TransSerializable}}, func(tx orm.Trans) error {
+		if err := m.Objects().TCreate(&runFlow{UID: "b"}, tx); err != nil {
+			return err
+		}
+		return orm.ErrMatchExist
+	}); err != orm.ErrMatchExist {
+		t.Fatalf("run in trans: %v", err)
+	}
+	if num, err = m.Objects().Count(); err != nil {
+		t.Fatal(err)
+	} else if num != 1 {
+		t.Fatalf(`"%v" count %d`, db, num)
+	}
+}
+
+// 只读事务及开启事务时的锁
+func Test_TransReadOnly(t *testing.T) {
+	type readFlow struct {
+		UID string `sorm:"primary;size(36)" json:"uid"`
+	}
+	var (
+		db  = testGetDB()
+		m   = db.Model("test_trans_read")
+		tx  orm.Trans
+		num int
+		err error
+	)
+	if db.DriverName() == orm.DriverNameMongo || db.DriverName() == orm.DriverNameMsSql {
+		t.Skip("read-only trans not supported")
+	}
+	if err = m.Drop(); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Ensure(&readFlow{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 只读事务中不能写入
+	// Deferrable仅postgres支持
+	if db.DriverName() != orm.DriverNamePostgres {
+		if _, err = db.BeginWith(&orm.ArgTrans{ReadOnly: true, Deferrable: true}); err != orm.ErrTransOptionNotSupport {
+			t.Fatalf("deferrable: %v", err)
+		}
+	}
+	if tx, err = db.BeginWith(&orm.ArgTrans{ReadOnly: true, Level: orm.TransSerializable,
+		Deferrable: db.DriverName() == orm.DriverNamePostgres}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Objects().TCount(tx); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Objects().TCreate(&readFlow{UID: "a"}, tx); err == nil {
+		t.Fatal("write in read-only trans")
+	}
+	if err = tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	// 结束后连接恢复可写, 上下文已取消时也恢复
+	if db.DriverName() == orm.DriverNameSQLite {
+		queryOnly := func() {
+			var lis []int
+			if err = m.Select(&lis, "PRAGMA query_only"); err != nil {
+				t.Fatal(err)
+			} else if len(lis) != 1 || lis[0] != 0 {
+				t.Fatalf("query_only not restored: %v", lis)
+			}
+		}
+		queryOnly()
+		ctx, cancel := context.WithCancel(context.Background())
+		if tx, err = m.WithContext(ctx).BeginWith(&orm.ArgTrans{ReadOnly: true}); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+		_ = tx.Rollback()
+		queryOnly()
+	}
+	for _, uid := range []string{"b", "c"} {
+		if err = m.Objects().Create(&readFlow{UID: uid}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// sqlite开启时即取写锁, 其他驱动不支持
+	if db.DriverName() != orm.DriverNameSQLite {
+		if _, err = m.BeginWith(&orm.ArgTrans{Lock: orm.TransImmediate}); err != orm.ErrTransOptionNotSupport {
+			t.Fatalf("lock: %v", err)
+		}
+		return
+	}
+	if tx, err = m.BeginWith(&orm.ArgTrans{Lock: orm.TransImmediate}); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Objects().TCreate(&readFlow{UID: "d"}, tx); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if num, err = m.Objects().Count(); err != nil {
+		t.Fatal(err)
+	} else if num != 3 {
+		t.Fatalf(`"%v" count %d`, db, num)
+	}
+	if _, err = m.BeginWith(&orm.ArgTrans{Lock: "unknown"}); err != orm.ErrTransLockUnknown {
+		t.Fatalf("unknown lock: %v", err)
+	}
+}
@@ -12,9 +12,14 @@ var (
 	// hook
 	ErrHookFuncUndefined error = errors.New("hook function undefined") // 函数未定义
 	// sync
-	ErrSyncEmbedPointNil error = errors.New("sync embed field ponitor nil") // 内嵌指针结构未初始化
+	ErrSyncEmbedPointNil  error = errors.New("sync embed field ponitor nil")    // 内嵌指针结构未初始化
+	ErrSyncTableNotExist  error = errors.New("sync table not exist")            // 表不存在
+	ErrSyncFkInvalid      error = errors.New("sync foreign key tag invalid")    // 外键标签有误
+	ErrSyncTagKindInvalid error = errors.New("sync tag not support field type") // 标签不支持该字段类型, 如version用于非整数
 	// model:exec/select
 	ErrNotImplementMethod error = errors.New("method is not implemented") // 方法未实现
+	// context
+	ErrContextCanceled error = errors.New("context canceled or deadline exceeded") // 上下文已取消或超时
 	// model:trans
 	ErrTransNotSupport        error = errors.New("driver not support trans")                // 驱动不支持事物: 如mongodb
 	ErrTransNotSupportMethod  error = errors.New("driver not support this method of trans") // 驱动不支持事物: 如mongodb
@@ -23,14 +28,41 @@ var (
 	ErrTransRollbackUndefined error = errors.New("option: rollback-error undefined")        // 事物要回滚，但未指明错误
 	ErrTransLockWholeTable    error = errors.New("trans lock whole table")                  // 没有where语句的lock，不允许
 	ErrTransLevelUnknown      error = errors.New("trans level unknown")                     // 事物级别未知
+	ErrTransLockUnknown       error = errors.New("trans lock unknown")                      // 事物开启时的锁未知
+	ErrTransFinished          error = errors.New("trans already finished")                  // 事物已提交或回滚
+	ErrTransTimeout           error = errors.New("trans timeout and rolled back")           // 事物超时, 已自动回滚
+	ErrTransSavepointInvalid  error = errors.New("trans savepoint name invalid")            // 保存点名称非法
+	ErrTransOptionNotSupport  error = errors.New("driver not support this option of trans") // 驱动不支持的事务参数: 如mysql的Deferrable
 	// query
 	ErrMatchNone     error = errors.New("match none")     // 无匹配记录
 	ErrMatchExist    error = errors.New("match exist")    // 记录已存在
 	ErrMatchMultiple error = errors.New("match multiple") // 期望搜到一条记录，但是返回多条
+	ErrCursorInvalid error = errors.New("cursor invalid") // 游标无法解析或与排序字段不匹配
+	// create
+	ErrCreateManyNotSlice error = errors.New("create many params not slice")       // 批量插入的参数需为切片
+	ErrCreateManyArgsMax  error = errors.New("create many row exceeds args limit") // 单条记录的字段数超出批量插入的占位符上限
+	// upsert
+	ErrUpsertKeyEmpty     error = errors.New("upsert conflict keys empty")      // 未指定冲突字段, 且结构体无主键或唯一索引
+	ErrUpsertKeyInvalid   error = errors.New("upsert conflict key invalid")     // 冲突字段不是结构体可插入的字段
+	ErrUpsertKeyNotUnique error = errors.New("upsert conflict keys not unique") // mysql: 冲突字段需恰为主键或唯一索引
+	// aggregate
+	ErrAggregateInvalid     error = errors.New("aggregate params invalid")     // 聚合参数错误, 格式如{"total": "sum(amount)"}
+	ErrAggregateSortInvalid error = errors.New("aggregate sort field invalid") // 分组聚合只能按分组字段或聚合别名排序
+	// soft delete
+	ErrSoftDeleteUndefined error = errors.New("soft delete field undefined") // 模型未定义softdelete字段
 	// update
 	ErrUpdateMapKeyInvalid   error = errors.New("update parms map-key invalid")  // 更新结构的key非法
 	ErrUpdateMapTypeUnknown  error = errors.New("update parms map type unknown") // 更新的输入参数不支持
 	ErrUpdateIncValueInvalid error = errors.New("update parms inc-val invalid")  // 更新结构的value非法
+	ErrVersionConflict       error = errors.New("version conflict")              // 乐观锁: 记录已被其它操作更新
+	// migration
+	ErrMigrationInvalid   error = errors.New("migration invalid")           // 版本号非法, 或当前驱动下无可执行的语句
+	ErrMigrationDuplicate error = errors.New("migration version duplicate") // 版本号重复注册
+	ErrMigrationModified  error = errors.New("migration modified")          // 已执行的迁移内容被修改
+	ErrMigrationLocked    error = errors.New("migration locked")            // 其它进程正在迁移
 	// index
-	ErrIndexTextParamsInvalid error = errors.New("text index params error") // 全文索引参数错误
+	ErrIndexTextParamsInvalid error = errors.New("text index params error")              // 全文索引参数错误
+	ErrIndexTextSortInvalid   error = errors.New("text sort without text query")         // 按相关度排序但查询中无$text$条件
+	ErrIndexWhereInvalid      error = errors.New("index where value invalid")            // 部分索引的条件中有无法写入语句的值
+	ErrIndexNotSupport        error = errors.New("index option not supported by driver") // 驱动不支持该索引参数
 )
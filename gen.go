@@ -0,0 +1,235 @@
+package orm
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+)
+
+var (
+	// genInitialism 生成字段名时整体大写的缩写
+	genInitialism = map[string]bool{
+		"id": true, "uid": true, "uuid": true, "url": true, "ip": true, "api": true, "json": true, "sql": true,
+	}
+)
+
+// genField 生成的结构体字段
+type genField struct {
+	Name    string   // Go字段名
+	Type    string   // Go类型
+	Tags    []string // sorm标签
+	Comment string   // 行尾注释
+	primary bool     // 带primary标签
+	index   bool     // 带index标签
+	unique  bool     // 带unique标签
+}
+
+// GenName 表名或字段名转为Go的驼峰命名, 如user_id -> UserID
+func GenName(s string) (r string) {
+	for _, w := range strings.FieldsFunc(s, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+	}) {
+		if genInitialism[strings.ToLower(w)] {
+			r += strings.ToUpper(w)
+		} else {
+			r += strings.ToUpper(w[:1]) + w[1:]
+		}
+	}
+	if len(r) == 0 || !unicode.IsLetter(rune(r[0])) {
+		r = "F" + r
+	}
+	return
+}
+
+// genColumn 按库中类型选择Go类型及标签, 使StructModelInfo解析后Ensure生成相同的类型
+func genColumn(c *ColumnInfo) (f *genField) {
+	var (
+		kind = planKind(c.Kind)
+		base = kind
+		args = ""
+		ptr  = c.Null && len(c.Default) == 0 && !c.Primary && !c.Serial // 可空且无默认值时用指针
+	)
+	f = &genField{Name: GenName(c.Name)}
+	if i := strings.Index(kind, "("); i >= 0 {
+		base, args = kind[:i], strings.Trim(kind[i:], "()")
+	}
+	switch base {
+	case "varchar", "nvarchar":
+		f.Type = "string"
+		if len(args) > 0 && args != "max" {
+			f.Tags = append(f.Tags, fmt.Sprintf("size(%s)", args))
+		}
+	case "char", "nchar":
+		f.Type, ptr = "string", false
+		f.Tags = append(f.Tags, fmt.Sprintf("char(%s)", args))
+	case "text", "longtext", "mediumtext", "tinytext", "ntext":
+		f.Type = "string"
+	case "integer", "smallint", "tinyint", "mediumint":
+		f.Type = "int"
+	case "bigint":
+		f.Type, ptr = "int64", false
+	case "boolean":
+		f.Type = "bool"
+	case "float", "real", "double", "float4":
+		f.Type = "float64"
+	case "decimal":
+		f.Type = "float64"
+		if len(args) > 0 {
+			f.Tags = append(f.Tags, fmt.Sprintf("decimal(%s)", args))
+		}
+	case "timestamp", "timestamp with time zone", "timestamp without time zone",
+		"datetime", "datetime2", "datetimeoffset", "date":
+		f.Type = "time.Time"
+	case "json", "jsonb":
+		f.Type, ptr = "types.JSONMap", false
+		f.Tags = append(f.Tags, "json")
+	case "bytea", "blob", "tinyblob", "mediumblob", "longblob", "binary", "varbinary":
+		f.Type, ptr = "[]byte", false
+	default:
+		f.Type, ptr = "string", false
+		f.Comment = "库中类型: " + c.Kind
+	}
+	if c.Serial {
+		if base == "bigint" {
+			f.Type = "int64"
+		} else {
+			f.Type = "int"
+		}
+		f.Tags = append([]string{"serial"}, f.Tags...)
+	}
+	if !c.Null && !c.Primary && !c.Serial {
+		f.Tags = append(f.Tags, "notnull")
+	}
+	if ptr {
+		f.Type = "*" + f.Type
+	}
+	return
+}
+
+// GenStruct 按库中表结构生成带sorm标签的结构体, name为空时按表名命名; 无法用标签表达的索引记在注释中
+func GenStruct(info *TableInfo, name string) string {
+	var (
+		buf     bytes.Buffer
+		fields  []*genField
+		colMap  = make(map[string]*genField)
+		pkLis   []string
+		skipLis []string
+	)
+	if len(name) == 0 {
+		name = GenName(info.Name)
+	}
+	for _, c := range info.Columns {
+		f := genColumn(c)
+		fields = append(fields, f)
+		colMap[c.Name] = f
+		if c.Primary {
+			pkLis = append(pkLis, c.Name)
+		}
+	}
+	// 主键: 以主键索引的顺序为准
+	for _, idx := range info.Indexes {
+		if idx.Primary {
+			pkLis = idx.Key
+			break
+		}
+	}
+	if len(pkLis) > 0 {
+		if f := colMap[pkLis[0]]; f != nil {
+			tag := "primary"
+			if len(pkLis) > 1 {
+				tag = fmt.Sprintf("primary(%s)", strings.Join(pkLis[1:], ","))
+			}
+			f.Tags, f.primary = append([]string{tag}, f.Tags...), true
+		}
+	}
+	// 索引: 标在首个字段上, 每个字段至多一个index及一个unique
+	for _, idx := range info.Indexes {
+		if idx.Primary || len(idx.Key) == 0 {
+			continue
+		}
+		f := colMap[idx.Key[0]]
+		if idx.Text && f != nil {
+			f.Tags = append(f.Tags, "text")
+			continue
+		}
+		if f == nil || f.primary || (idx.Unique && f.unique) || (!idx.Unique && f.index) {
+			skipLis = append(skipLis, fmt.Sprintf("%s(%s)", idx.Name, strings.Join(idx.Key, ",")))
+			continue
+		}
+		tag := "index"
+		if idx.Unique {
+			tag = "unique"
+		}
+		if len(idx.Key) > 1 {
+			tag = fmt.Sprintf("%s(%s)", tag, strings.Join(idx.Key[1:], ","))
+		}
+		f.Tags = append(f.Tags, tag)
+		f.index, f.unique = f.index || !idx.Unique, f.unique || idx.Unique
+	}
+	// 外键: 库的默认动作NO ACTION不写入标签
+	for _, fk := range info.Fks {
+		f := colMap[fk.Column]
+		if f == nil {
+			continue
+		}
+		tag := fk.RefTable + "." + fk.RefColumn
+		for _, a := range [][2]string{{"ondelete", fk.OnDelete}, {"onupdate", fk.OnUpdate}} {
+			if len(a[1]) > 0 && a[1] != "NO ACTION" {
+				tag += "," + a[0] + "=" + strings.Replace(strings.ToLower(a[1]), " ", "_", -1)
+			}
+		}
+		f.Tags = append(f.Tags, fmt.Sprintf("fk(%s)", tag))
+	}
+
+	fmt.Fprintf(&buf, "// %s 由表%s生成\n", name, info.Name)
+	for _, s := range skipLis {
+		fmt.Fprintf(&buf, "// 未生成的索引: %s\n", s)
+	}
+	fmt.Fprintf(&buf, "type %s struct {\n", name)
+	for i, f := range fields {
+		col := info.Columns[i].Name
+		tag := ""
+		if len(f.Tags) > 0 {
+			tag = fmt.Sprintf(`sorm:"%s" `, strings.Join(f.Tags, ";"))
+		}
+		if strings.ToLower(f.Name) != col {
+			tag += fmt.Sprintf(`db:"%s" `, col)
+		}
+		tag += fmt.Sprintf(`json:"%s"`, col)
+		fmt.Fprintf(&buf, "\t%s %s `%s`", f.Name, f.Type, tag)
+		if len(f.Comment) > 0 {
+			fmt.Fprintf(&buf, " // %s", f.Comment)
+		}
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// GenFile 读取库中的表, 生成含package及import的go文件
+func GenFile(db Database, pkg string, tables ...string) (src []byte, err error) {
+	var (
+		body    bytes.Buffer
+		info    *TableInfo
+		imports []string
+	)
+	for _, t := range tables {
+		if info, err = db.Model(t).Inspect(); err != nil {
+			return
+		}
+		body.WriteString("\n" + GenStruct(info, ""))
+	}
+	if bytes.Contains(body.Bytes(), []byte("time.Time")) {
+		imports = append(imports, `"time"`)
+	}
+	if bytes.Contains(body.Bytes(), []byte("types.")) {
+		imports = append(imports, `"github.com/suboat/sorm/types"`)
+	}
+	head := fmt.Sprintf("// 由sorm-gen根据库中表结构生成\n\npackage %s\n", pkg)
+	if len(imports) > 0 {
+		head += fmt.Sprintf("\nimport (\n\t%s\n)\n", strings.Join(imports, "\n\t"))
+	}
+	return format.Source(append([]byte(head), body.Bytes()...))
+}
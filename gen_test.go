@@ -0,0 +1,103 @@
+package orm
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenName(t *testing.T) {
+	for _, v := range [][2]string{
+		{"user_id", "UserID"},
+		{"order_item", "OrderItem"},
+		{"api_url", "APIURL"},
+		{"2fa", "F2fa"},
+	} {
+		if s := GenName(v[0]); s != v[1] {
+			t.Fatalf("%s: %s != %s", v[0], s, v[1])
+		}
+	}
+}
+
+func TestGenStruct(t *testing.T) {
+	info := &TableInfo{
+		Name: "gen_order",
+		Columns: []*ColumnInfo{
+			{Name: "id", Kind: "integer", Default: "nextval('gen_order_id_seq'::regclass)", Primary: true, Serial: true},
+			{Name: "uid", Kind: "character varying(36)", Default: "''::character varying", Null: true},
+			{Name: "user_id", Kind: "character varying(36)", Default: "''::character varying", Null: true},
+			{Name: "amount", Kind: "numeric(20,8)", Default: "0", Null: true},
+			{Name: "state", Kind: "integer", Default: "0"},
+			{Name: "meta", Kind: "jsonb", Default: "'{}'::jsonb", Null: true},
+			{Name: "paid", Kind: "timestamp with time zone", Null: true},
+			{Name: "at", Kind: "timestamp with time zone", Default: "'0001-01-01 00:00:00'", Null: true},
+		},
+		Indexes: []*IndexInfo{
+			{Name: "gen_order_pkey", Key: []string{"id"}, Unique: true, Primary: true},
+			{Name: "gen_order_uid", Key: []string{"uid"}, Unique: true},
+			{Name: "gen_order_user_id_at", Key: []string{"user_id", "at"}},
+			{Name: "gen_order_id_uid", Key: []string{"id", "uid"}},
+		},
+		Fks: []*FkInfo{
+			{Name: "gen_order_user_id_fkey", Column: "user_id", RefTable: "gen_user", RefColumn: "uid",
+				OnDelete: "CASCADE", OnUpdate: "NO ACTION"},
+		},
+	}
+	src := GenStruct(info, "")
+	for _, s := range []string{
+		"type GenOrder struct {",
+		"// 未生成的索引: gen_order_id_uid(id,uid)",
+		"ID int `sorm:\"primary;serial\" json:\"id\"`",
+		"UID string `sorm:\"size(36);unique\" json:\"uid\"`",
+		"UserID string `sorm:\"size(36);index(at);fk(gen_user.uid,ondelete=cascade)\" db:\"user_id\" json:\"user_id\"`",
+		"Amount float64 `sorm:\"decimal(20,8)\" json:\"amount\"`",
+		"State int `sorm:\"notnull\" json:\"state\"`",
+		"Meta types.JSONMap `sorm:\"json\" json:\"meta\"`",
+		"Paid *time.Time `json:\"paid\"`",
+		"At time.Time `json:\"at\"`",
+	} {
+		if !strings.Contains(src, s) {
+			t.Fatalf("missing %q in:\n%s", s, src)
+		}
+	}
+
+	// 生成的标签经StructModelInfo解析后与表结构一致
+	type genOrder struct {
+		ID     int                    `sorm:"primary;serial" json:"id"`
+		UID    string                 `sorm:"size(36);unique" json:"uid"`
+		UserID string                 `sorm:"size(36);index(at);fk(gen_user.uid,ondelete=cascade)" db:"user_id" json:"user_id"`
+		Amount float64                `sorm:"decimal(20,8)" json:"amount"`
+		State  int                    `sorm:"notnull" json:"state"`
+		Meta   map[string]interface{} `sorm:"json" json:"meta"`
+		Paid   *time.Time             `json:"paid"`
+		At     time.Time              `json:"at"`
+	}
+	lis, err := StructModelInfo(&genOrder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lis) != len(info.Columns) {
+		t.Fatalf("fields %d != %d", len(lis), len(info.Columns))
+	}
+	for i, f := range lis {
+		if f.Name != info.Columns[i].Name {
+			t.Fatalf("name %s != %s", f.Name, info.Columns[i].Name)
+		}
+	}
+	if !lis[0].Primary || !lis[0].Serial || !lis[1].Unique || lis[1].Size != 36 ||
+		!lis[2].Index || strings.Join(lis[2].IndexKeys, ",") != "user_id,at" ||
+		lis[2].Fk == nil || lis[2].Fk.Table != "gen_user" || lis[2].Fk.OnDelete != "CASCADE" ||
+		lis[3].Size != 20 || lis[3].Precision != 8 || lis[4].AllowNull || lis[6].DefaultVal != nil {
+		t.Fatal("tag mismatch")
+	}
+
+	// 全文索引
+	src = GenStruct(&TableInfo{
+		Name:    "gen_post",
+		Columns: []*ColumnInfo{{Name: "title", Kind: "text", Default: "''", Null: true}},
+		Indexes: []*IndexInfo{{Name: "gen_post_title_text", Key: []string{"title"}, Text: true}},
+	}, "")
+	if s := "Title string `sorm:\"text\" json:\"title\"`"; !strings.Contains(src, s) {
+		t.Fatalf("missing %q in:\n%s", s, src)
+	}
+}
@@ -0,0 +1,100 @@
+package orm
+
+import (
+	"reflect"
+)
+
+// 钩子名
+const (
+	HookBeforeCreate = "BeforeCreate" // Create CreateMany 插入前
+	HookAfterCreate  = "AfterCreate"  // Create CreateMany 插入后
+	HookBeforeUpdate = "BeforeUpdate" // Update UpdateOne 更新前
+	HookAfterUpdate  = "AfterUpdate"  // Update UpdateOne 更新后
+	HookBeforeDelete = "BeforeDelete" // Delete DeleteOne 删除前, 需将记录传入Delete
+	HookAfterFind    = "AfterFind"    // All One Iter 读取后
+)
+
+// BeforeCreateHook 插入前回调, 返回错误时中止插入
+type BeforeCreateHook interface {
+	BeforeCreate() error
+}
+
+// AfterCreateHook 插入后回调
+type AfterCreateHook interface {
+	AfterCreate() error
+}
+
+// BeforeUpdateHook 更新前回调, 返回错误时中止更新
+type BeforeUpdateHook interface {
+	BeforeUpdate() error
+}
+
+// AfterUpdateHook 更新后回调
+type AfterUpdateHook interface {
+	AfterUpdate() error
+}
+
+// BeforeDeleteHook 删除前回调, 返回错误时中止删除
+type BeforeDeleteHook interface {
+	BeforeDelete() error
+}
+
+// AfterFindHook 读取后回调
+type AfterFindHook interface {
+	AfterFind() error
+}
+
+// HookCall 调用记录上的钩子, record可为结构体(指针)或其切片; 未实现钩子时忽略
+func HookCall(name string, record interface{}) (err error) {
+	if record == nil {
+		return
+	}
+	if ok, _err := hookCallOne(name, record); ok {
+		return _err
+	}
+	v := reflect.Indirect(reflect.ValueOf(record))
+	if v.Kind() != reflect.Slice {
+		return
+	}
+	for i := 0; i < v.Len(); i++ {
+		e := v.Index(i)
+		if e.Kind() != reflect.Ptr && e.CanAddr() {
+			e = e.Addr()
+		}
+		if _, err = hookCallOne(name, e.Interface()); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// hookCallOne 调用单条记录的钩子
+func hookCallOne(name string, record interface{}) (ok bool, err error) {
+	switch name {
+	case HookBeforeCreate:
+		if h, _ok := record.(BeforeCreateHook); _ok {
+			return true, h.BeforeCreate()
+		}
+	case HookAfterCreate:
+		if h, _ok := record.(AfterCreateHook); _ok {
+			return true, h.AfterCreate()
+		}
+	case HookBeforeUpdate:
+		if h, _ok := record.(BeforeUpdateHook); _ok {
+			return true, h.BeforeUpdate()
+		}
+	case HookAfterUpdate:
+		if h, _ok := record.(AfterUpdateHook); _ok {
+			return true, h.AfterUpdate()
+		}
+	case HookBeforeDelete:
+		if h, _ok := record.(BeforeDeleteHook); _ok {
+			return true, h.BeforeDelete()
+		}
+	case HookAfterFind:
+		if h, _ok := record.(AfterFindHook); _ok {
+			return true, h.AfterFind()
+		}
+	}
+	return
+}
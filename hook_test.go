@@ -0,0 +1,42 @@
+package orm
+
+import (
+	"errors"
+	"testing"
+)
+
+type hookRecord struct {
+	Name  string
+	Found bool
+}
+
+func (r *hookRecord) AfterFind() error {
+	r.Found = true
+	return nil
+}
+
+func (r *hookRecord) BeforeCreate() error {
+	if len(r.Name) == 0 {
+		return errors.New("name empty")
+	}
+	return nil
+}
+
+func TestHookCall(t *testing.T) {
+	lis := []hookRecord{{Name: "a"}, {Name: "b"}}
+	if err := HookCall(HookAfterFind, &lis); err != nil {
+		t.Fatal(err)
+	} else if !lis[0].Found || !lis[1].Found {
+		t.Fatalf("get %v", lis)
+	}
+	if err := HookCall(HookBeforeCreate, []*hookRecord{{Name: "a"}, {}}); err == nil {
+		t.Fatal("expect error")
+	}
+	// 未实现钩子或非结构体时忽略
+	if err := HookCall(HookBeforeDelete, &hookRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := HookCall(HookAfterFind, M{"name": "a"}); err != nil {
+		t.Fatal(err)
+	}
+}
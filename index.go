@@ -0,0 +1,114 @@
+package orm
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// regIndexName 索引名中不能出现的字符
+	regIndexName = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+	// regIndexHolder 条件语句中的占位符
+	regIndexHolder = regexp.MustCompile(`\?|\$[0-9]+`)
+)
+
+// IndexKey 解析索引字段的排序前缀: "-at"为降序, "+at"及"at"为升序
+func IndexKey(k string) (name string, desc bool) {
+	name = strings.TrimSpace(k)
+	if strings.HasPrefix(name, "-") {
+		return name[1:], true
+	}
+	return strings.TrimPrefix(name, "+"), false
+}
+
+// IndexName 由表名及字段或表达式生成默认的索引名, 如lower(email) -> table_lower_email
+func IndexName(table string, keys []string) string {
+	lis := []string{table}
+	for _, k := range keys {
+		k, _ = IndexKey(k)
+		if s := strings.Trim(regIndexName.ReplaceAllString(k, "_"), "_"); len(s) > 0 {
+			lis = append(lis, s)
+		}
+	}
+	return strings.Join(lis, "_")
+}
+
+// IndexWhere 将部分索引的条件转为sql; 建索引的语句不支持参数, 值直接写入语句
+func IndexWhere(driverName string, where M) (sql string, err error) {
+	var (
+		vals []interface{}
+		lis  []string
+		i    = 0
+	)
+	if sql, vals, err = where.SQL(driverName, 0); err != nil {
+		return
+	}
+	for _, v := range vals {
+		var s string
+		if s, err = indexLiteral(driverName, v); err != nil {
+			return
+		}
+		lis = append(lis, s)
+	}
+	// 一次替换, 避免值中的?或$被再次替换
+	sql = regIndexHolder.ReplaceAllStringFunc(sql, func(h string) (s string) {
+		if h != "?" {
+			fmt.Sscanf(h, "$%d", &i)
+			i--
+		}
+		if i >= 0 && i < len(lis) {
+			s = lis[i]
+		}
+		if h == "?" {
+			i++
+		}
+		return
+	})
+	return
+}
+
+// indexLiteral 值转为sql字面量
+func indexLiteral(driverName string, v interface{}) (s string, err error) {
+	switch _v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case bool:
+		switch {
+		case driverName == DriverNameSQLite || driverName == DriverNameMsSql:
+			s = "0"
+			if _v {
+				s = "1"
+			}
+		case _v:
+			s = "TRUE"
+		default:
+			s = "FALSE"
+		}
+		return
+	case string:
+		if driverName == DriverNameMysql {
+			_v = strings.Replace(_v, `\`, `\\`, -1)
+		}
+		return "'" + strings.Replace(_v, "'", "''", -1) + "'", nil
+	case time.Time:
+		return "'" + _v.UTC().Format("2006-01-02 15:04:05.999999") + "'", nil
+	case driver.Valuer:
+		if v, err = _v.Value(); err != nil {
+			return
+		}
+		return indexLiteral(driverName, v)
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		s = fmt.Sprint(v)
+	default:
+		err = ErrIndexWhereInvalid
+	}
+	return
+}
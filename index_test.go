@@ -0,0 +1,63 @@
+package orm
+
+import (
+	"github.com/suboat/sorm/songo"
+
+	"testing"
+)
+
+func TestIndexKey(t *testing.T) {
+	for k, v := range map[string]bool{"at": false, "+at": false, "-at": true, " -at ": true} {
+		if name, desc := IndexKey(k); name != "at" || desc != v {
+			t.Fatalf("%q: %s %v", k, name, desc)
+		}
+	}
+}
+
+func TestIndexName(t *testing.T) {
+	for _, v := range []struct {
+		keys []string
+		name string
+	}{
+		{[]string{"name", "-at"}, "user_name_at"},
+		{[]string{"lower(email)"}, "user_lower_email"},
+		{[]string{"(a + b)", "coalesce(c, '')"}, "user_a_b_coalesce_c"},
+	} {
+		if s := IndexName("user", v.keys); s != v.name {
+			t.Fatalf("%v: %s != %s", v.keys, s, v.name)
+		}
+	}
+}
+
+func TestIndexWhere(t *testing.T) {
+	pg, my := HookParseSQL[DriverNamePostgres], HookParseSQL[DriverNameMysql]
+	defer func() {
+		HookParseSQL[DriverNamePostgres], HookParseSQL[DriverNameMysql] = pg, my
+	}()
+	HookParseSQL[DriverNamePostgres] = songo.ParseSQL
+	HookParseSQL[DriverNameMysql] = songo.ParseMysql
+
+	for _, v := range []struct {
+		driver string
+		where  M
+		sql    string
+	}{
+		{DriverNamePostgres, M{"deleted": nil}, `("deleted" IS NULL)`},
+		{DriverNamePostgres, M{"deleted$ne$": nil}, `("deleted" IS NOT NULL)`},
+		{DriverNamePostgres, M{"name": "it's $1"}, `("name" = 'it''s $1')`},
+		{DriverNamePostgres, M{"age$gt$": 18}, `("age" > 18)`},
+		{DriverNamePostgres, M{"ok": true}, `("ok" = TRUE)`},
+		{DriverNameMysql, M{"name": `a\?`}, "(`name` = 'a\\\\?')"},
+	} {
+		s, err := IndexWhere(v.driver, v.where)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s != v.sql {
+			t.Fatalf("%v: %s != %s", v.where, s, v.sql)
+		}
+	}
+	if _, err := IndexWhere(DriverNamePostgres, M{"tags": []int{1}}); err == nil {
+		t.Fatal("invalid value expected")
+	}
+}
@@ -0,0 +1,334 @@
+package orm
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// 迁移: 按版本号升序执行, 已执行的版本记录在MigrationTable中
+var (
+	MigrationTable       = "sorm_migration"      // 已执行的迁移
+	MigrationLockTable   = "sorm_migration_lock" // 迁移锁, 同一时间只允许一个进程迁移
+	MigrationLockTimeout = 10 * time.Minute      // 超时未刷新的锁视为失效, 持有期间每1/3超时刷新一次
+)
+
+// MigrationFunc Go函数迁移; 驱动支持在事务中执行DDL时t为迁移所在的事务, 否则为nil
+type MigrationFunc func(db Database, t Trans) error
+
+// Migration 一个版本的迁移, 同时定义Go函数及SQL时只执行Go函数
+type Migration struct {
+	Version int64             // 版本号, 大于0
+	Name    string            // 说明
+	UpSQL   map[string]string // 驱动名->升级语句
+	DownSQL map[string]string // 驱动名->回滚语句
+	Up      MigrationFunc     // 升级
+	Down    MigrationFunc     // 回滚
+}
+
+// Checksum 迁移在驱动下的校验和, 用于发现已执行的迁移被修改
+func (m *Migration) Checksum(driverName string) string {
+	h := sha256.Sum256([]byte(fmt.Sprintf("%d\n%s\n%s\n%s", m.Version, m.Name, m.UpSQL[driverName], m.DownSQL[driverName])))
+	return hex.EncodeToString(h[:])
+}
+
+// MigrationRecord 已执行的迁移
+type MigrationRecord struct {
+	Version   int64     `sorm:"primary" json:"version"`
+	Name      string    `sorm:"size(255)" json:"name"`
+	Checksum  string    `sorm:"size(64)" json:"checksum"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// MigrationStatus 迁移状态
+type MigrationStatus struct {
+	Version   int64     `json:"version"`
+	Name      string    `json:"name"`
+	Applied   bool      `json:"applied"`   // 已执行
+	AppliedAt time.Time `json:"appliedAt"` // 执行时间
+	Modified  bool      `json:"modified"`  // 执行后迁移内容被修改
+	Missing   bool      `json:"missing"`   // 已执行但未注册
+}
+
+// migrationLock 迁移锁, 只有一条记录
+// 主键不用id: mongo不为名为id的主键建唯一索引, 无法互斥
+type migrationLock struct {
+	LockID   int       `sorm:"primary" json:"lockId"`
+	Owner    string    `sorm:"size(255)" json:"owner"`
+	LockTime time.Time `json:"lockTime"`
+}
+
+// MigrationTransDDL 驱动是否支持在事务中执行DDL
+func MigrationTransDDL(driverName string) bool {
+	switch driverName {
+	case DriverNamePostgres, DriverNameSQLite, DriverNameMsSql:
+		return true
+	}
+	return false
+}
+
+// Migrator 迁移执行器
+type Migrator struct {
+	LockRefresh time.Duration // 持有锁时刷新的间隔, 为空时为MigrationLockTimeout的1/3
+
+	db    Database
+	lis   []*Migration  // 按版本号升序
+	owner string        // 持有锁时的标识
+	stop  chan struct{} // 持有锁时停止刷新锁
+	done  chan struct{} // 刷新已停止
+}
+
+// NewMigrator 新建迁移执行器
+func NewMigrator(db Database) *Migrator {
+	return &Migrator{db: db}
+}
+
+// Register 注册迁移, 可多次调用, 执行时按版本号排序
+func (mg *Migrator) Register(lis ...*Migration) (err error) {
+	for _, m := range lis {
+		if m == nil || m.Version <= 0 {
+			return ErrMigrationInvalid
+		}
+		for _, v := range mg.lis {
+			if v.Version == m.Version {
+				return ErrMigrationDuplicate
+			}
+		}
+		mg.lis = append(mg.lis, m)
+	}
+	sort.Slice(mg.lis, func(i, j int) bool { return mg.lis[i].Version < mg.lis[j].Version })
+	return
+}
+
+// Migrate 执行所有未执行的迁移, 返回执行的数目
+func (mg *Migrator) Migrate() (n int, err error) {
+	if err = mg.lock(); err != nil {
+		return
+	}
+	defer mg.unlock(&err)
+	var applied map[int64]*MigrationRecord
+	if applied, err = mg.applied(); err != nil {
+		return
+	}
+	driverName := mg.db.DriverName()
+	for _, m := range mg.lis {
+		if r, ok := applied[m.Version]; ok && r.Checksum != m.Checksum(driverName) {
+			return 0, ErrMigrationModified
+		}
+	}
+	for _, m := range mg.lis {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		if err = mg.run(m, true); err != nil {
+			return
+		}
+		n++
+	}
+	return
+}
+
+// Rollback 按版本号倒序回滚最近执行的n个迁移, 返回回滚的数目
+func (mg *Migrator) Rollback(n int) (ret int, err error) {
+	if err = mg.lock(); err != nil {
+		return
+	}
+	defer mg.unlock(&err)
+	var applied map[int64]*MigrationRecord
+	if applied, err = mg.applied(); err != nil {
+		return
+	}
+	versions := make([]int64, 0, len(applied))
+	for v := range applied {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] > versions[j] })
+	for _, v := range versions {
+		if ret >= n {
+			break
+		}
+		var m *Migration
+		for _, _m := range mg.lis {
+			if _m.Version == v {
+				m = _m
+			}
+		}
+		if m == nil {
+			return ret, ErrMigrationInvalid
+		}
+		if err = mg.run(m, false); err != nil {
+			return
+		}
+		ret++
+	}
+	return
+}
+
+// Status 已注册及已执行的迁移状态, 按版本号升序
+func (mg *Migrator) Status() (lis []*MigrationStatus, err error) {
+	if err = mg.ensure(); err != nil {
+		return
+	}
+	var applied map[int64]*MigrationRecord
+	if applied, err = mg.applied(); err != nil {
+		return
+	}
+	driverName := mg.db.DriverName()
+	for _, m := range mg.lis {
+		s := &MigrationStatus{Version: m.Version, Name: m.Name}
+		if r, ok := applied[m.Version]; ok {
+			s.Applied, s.AppliedAt = true, r.AppliedAt
+			s.Modified = r.Checksum != m.Checksum(driverName)
+			delete(applied, m.Version)
+		}
+		lis = append(lis, s)
+	}
+	for _, r := range applied {
+		lis = append(lis, &MigrationStatus{Version: r.Version, Name: r.Name, Applied: true, AppliedAt: r.AppliedAt, Missing: true})
+	}
+	sort.Slice(lis, func(i, j int) bool { return lis[i].Version < lis[j].Version })
+	return
+}
+
+// run 执行一个迁移并记录, 驱动支持时在事务中执行
+func (mg *Migrator) run(m *Migration, up bool) (err error) {
+	var (
+		driverName = mg.db.DriverName()
+		model      = mg.db.Model(MigrationTable)
+		fn         = m.Up
+		sqlCmd     = m.UpSQL[driverName]
+		t          Trans
+	)
+	if !up {
+		fn, sqlCmd = m.Down, m.DownSQL[driverName]
+	}
+	if fn == nil && len(sqlCmd) == 0 {
+		return ErrMigrationInvalid
+	}
+	if MigrationTransDDL(driverName) {
+		if t, err = model.Begin(); err != nil {
+			return
+		}
+		defer func() {
+			if err != nil {
+				_ = t.Rollback()
+			} else {
+				err = t.Commit()
+			}
+		}()
+	}
+	// 执行
+	if fn != nil {
+		err = fn(mg.db, t)
+	} else if t != nil {
+		_, err = t.Exec(sqlCmd)
+	} else {
+		_, err = model.Exec(sqlCmd)
+	}
+	if err != nil {
+		return
+	}
+	// 记录
+	if up {
+		rec := &MigrationRecord{Version: m.Version, Name: m.Name, Checksum: m.Checksum(driverName), AppliedAt: time.Now()}
+		if t != nil {
+			err = model.Objects().TCreate(rec, t)
+		} else {
+			err = model.Objects().Create(rec)
+		}
+	} else {
+		obs := model.Objects().Filter(M{"version": m.Version})
+		if t != nil {
+			err = obs.TDelete(t)
+		} else {
+			err = obs.Delete()
+		}
+	}
+	return
+}
+
+// applied 已执行的迁移
+func (mg *Migrator) applied() (ret map[int64]*MigrationRecord, err error) {
+	var lis []*MigrationRecord
+	if err = mg.db.Model(MigrationTable).Objects().All(&lis); err != nil {
+		return
+	}
+	ret = make(map[int64]*MigrationRecord, len(lis))
+	for _, r := range lis {
+		ret[r.Version] = r
+	}
+	return
+}
+
+// ensure 确认记录表及锁表
+func (mg *Migrator) ensure() (err error) {
+	if err = mg.db.Model(MigrationTable).Ensure(&MigrationRecord{}); err != nil {
+		return
+	}
+	return mg.db.Model(MigrationLockTable).Ensure(&migrationLock{})
+}
+
+// lock 加锁并定时刷新, 锁已被其它进程持有时返回ErrMigrationLocked
+func (mg *Migrator) lock() (err error) {
+	if err = mg.ensure(); err != nil {
+		return
+	}
+	var (
+		model = mg.db.Model(MigrationLockTable)
+		host  string
+	)
+	// 清理超时的锁
+	_ = model.Objects().Filter(M{"lockid": 1, "locktime$lt$": time.Now().Add(-MigrationLockTimeout)}).Delete()
+	if host, err = os.Hostname(); err != nil {
+		host = "unknown"
+	}
+	mg.owner = fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+	if err = model.Objects().Create(&migrationLock{LockID: 1, Owner: mg.owner, LockTime: time.Now()}); err != nil {
+		// 主键冲突: 锁已存在
+		if DuplicateKey(err) {
+			err = ErrMigrationLocked
+		}
+		return
+	}
+	interval := mg.LockRefresh
+	if interval <= 0 {
+		interval = MigrationLockTimeout / 3
+	}
+	mg.stop, mg.done = make(chan struct{}), make(chan struct{})
+	go mg.refresh(mg.owner, interval, mg.stop, mg.done)
+	return nil
+}
+
+// refresh 持有锁期间每隔interval刷新locktime, 避免执行较久时被其它进程视为超时; 退出时关闭done
+func (mg *Migrator) refresh(owner string, interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	tick := time.NewTicker(interval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick.C:
+			obs := mg.db.Model(MigrationLockTable).Objects().Filter(M{"lockid": 1, "owner": owner})
+			if err := obs.Update(map[string]interface{}{"locktime": time.Now()}); err != nil && Log != nil {
+				Log.Warnf("migration lock refresh: %v", err)
+			}
+		}
+	}
+}
+
+// unlock 停止刷新并等待其退出后释放锁, 释放出错时只在迁移本身成功时返回
+func (mg *Migrator) unlock(err *error) {
+	if mg.stop != nil {
+		close(mg.stop)
+		<-mg.done
+		mg.stop, mg.done = nil, nil
+	}
+	_err := mg.db.Model(MigrationLockTable).Objects().Filter(M{"lockid": 1, "owner": mg.owner}).Delete()
+	if *err == nil && _err != nil {
+		*err = _err
+	}
+}
@@ -0,0 +1,33 @@
+package orm
+
+import (
+	"testing"
+)
+
+func TestMigratorRegister(t *testing.T) {
+	mg := NewMigrator(nil)
+	if err := mg.Register(&Migration{Version: 2, Name: "b"}, &Migration{Version: 1, Name: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if mg.lis[0].Version != 1 || mg.lis[1].Version != 2 {
+		t.Fatalf("get %v %v", mg.lis[0].Version, mg.lis[1].Version)
+	}
+	if err := mg.Register(&Migration{Version: 1}); err != ErrMigrationDuplicate {
+		t.Fatalf("get %v", err)
+	}
+	if err := mg.Register(&Migration{Version: 0}); err != ErrMigrationInvalid {
+		t.Fatalf("get %v", err)
+	}
+
+	// 校验和按驱动区分
+	m := &Migration{Version: 1, UpSQL: map[string]string{DriverNamePostgres: "ALTER TABLE a ADD b int"}}
+	if m.Checksum(DriverNamePostgres) == m.Checksum(DriverNameMysql) {
+		t.Fatal("checksum not differ by driver")
+	}
+	if c := m.Checksum(DriverNameMysql); c != (&Migration{Version: 1}).Checksum(DriverNameMysql) {
+		t.Fatalf("get %v", c)
+	}
+	if MigrationTransDDL(DriverNameMysql) || !MigrationTransDDL(DriverNamePostgres) {
+		t.Fatal("trans ddl")
+	}
+}
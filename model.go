@@ -1,18 +1,38 @@
 package orm
 
 import (
+	"context"
 	"database/sql/driver"
 	"encoding/json"
 	"time"
 )
 
-// Index 索引信息
+// Index 索引信息, 可用的key:
+//
+//	Key     []string 字段, "-"前缀为降序, 如[]string{"uid", "-at"}
+//	Unique  []string 唯一索引的字段(sql); bool时表示Key或Expr为唯一索引
+//	Expr    []string 表达式索引, 如[]string{"lower(email)"}, mssql及mongo不支持
+//	Where   M        部分索引的条件, 如M{"deleted_at": nil}, mysql不支持
+//	Include []string 覆盖索引的附加字段, 仅postgres及mssql生效
+//	Name    string   索引名, 默认由表名及字段生成
 type Index map[string]interface{}
 
 // ArgTrans 开启事务的参数
 type ArgTrans struct {
-	Level   string        // 事务级别
-	Timeout time.Duration // 超时回滚
+	Level            string        // 事务级别
+	Timeout          time.Duration // 超时回滚, 为空时使用TransTimeout
+	StatementTimeout time.Duration // 单条语句超时, 仅postgres及mysql生效; mysql(max_execution_time)只限制只读的SELECT, mariadb限制所有语句
+	ReadOnly         bool          // 只读事务, mssql不支持, 忽略
+	Deferrable       bool          // 与Serializable及ReadOnly同用时等待安全的快照, 之后不会因序列化失败中止, 仅postgres支持, 其他驱动返回ErrTransOptionNotSupport
+	Lock             string        // sqlite开启事务时的锁: TransDeferred(默认), TransImmediate, TransExclusive, 其他驱动返回ErrTransOptionNotSupport
+}
+
+// ArgEnsure EnsureWith的参数, 均需显式开启
+type ArgEnsure struct {
+	AlterColumn bool // 修改类型/长度/默认值/可空与定义不一致的字段
+	DropColumn  bool // 删除struct中未定义的字段
+	DropIndex   bool // 删除struct中未定义的索引
+	Rename      bool // 按was(旧名)标签重命名字段
 }
 
 // ArgObjects 获取Objects的定制参数
@@ -29,19 +49,26 @@ type Model interface {
 	// 通过tag来定义索引:
 	// unique索引    Name string `sorm:"unique"`
 	// index索引     Name string `sorm:"index"`
-	// TODO: 全文索引 Name string `sorm:"text"`
+	// 全文索引     Name string `sorm:"text"`, 以$text$搜索, Sort("-$text$")按相关度排序
 	Ensure(st interface{}) error // 通过struct的tag来 添加字段,确认索引
+	// 同Ensure, 并按参数修改/删除/重命名已有的字段及索引
+	EnsureWith(st interface{}, opt *ArgEnsure) error
 	// 确认字段存在
 	EnsureColumn(st interface{}) error
 	// 确认索引存在
 	EnsureIndex(index Index) error
+	// 对比struct与库中的表结构, 返回Ensure将执行的语句, 不执行
+	Plan(st interface{}) (*Plan, error)
+	// 读取库中表的字段及索引
+	Inspect() (*TableInfo, error)
 
 	// 事务
-	Begin() (Trans, error)                  // 事务开始
-	BeginWith(opt *ArgTrans) (Trans, error) // 指定事务开始
-	Commit(Trans) error                     // 阶段二提交
-	Rollback(Trans) error                   // 回滚操作
-	AutoTrans(Trans) error                  // 依据Trans内是否储存有错误来自动决定回滚或提交
+	Begin() (Trans, error)                   // 事务开始
+	BeginWith(opt *ArgTrans) (Trans, error)  // 指定事务开始
+	BeginNested(parent Trans) (Trans, error) // 在parent中以保存点开启子事务, parent为空时同Begin
+	Commit(Trans) error                      // 阶段二提交
+	Rollback(Trans) error                    // 回滚操作
+	AutoTrans(Trans) error                   // 依据Trans内是否储存有错误来自动决定回滚或提交
 
 	// 表的读写锁
 	Lock()
@@ -56,7 +83,8 @@ type Model interface {
 	Drop() error
 
 	// other
-	With(opt *ArgModel) Model // 返回一个新的model
+	With(opt *ArgModel) Model              // 返回一个新的model
+	WithContext(ctx context.Context) Model // 返回绑定上下文的model, 其语句及事务随ctx取消或超时
 }
 
 // Result summarizes an executed SQL command. 结果信息
@@ -74,6 +102,29 @@ type Result interface {
 	RowsAffected() (int64, error)
 }
 
+// ResultBatch 批量操作结果, 每批一条
+type ResultBatch []Result
+
+// LastInsertId 最后一批的结果
+func (r ResultBatch) LastInsertId() (int64, error) {
+	if len(r) == 0 {
+		return 0, nil
+	}
+	return r[len(r)-1].LastInsertId()
+}
+
+// RowsAffected 各批影响行数之和
+func (r ResultBatch) RowsAffected() (n int64, err error) {
+	var _n int64
+	for _, res := range r {
+		if _n, err = res.RowsAffected(); err != nil {
+			return
+		}
+		n += _n
+	}
+	return
+}
+
 // TypeJSONValue 通用json内联字段
 type TypeJSONValue struct{}
 
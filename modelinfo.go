@@ -0,0 +1,109 @@
+package orm
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// ModelInfoTable 记录各表的附加信息, Ensure时写入; 重启后未经Ensure的表由此读取, 以免软删除退化为物理删除
+var ModelInfoTable = "sorm_model_info"
+
+// modelInfoRecord ModelInfoTable的一条记录
+type modelInfoRecord struct {
+	Name string `sorm:"primary;size(255)" json:"name"` // 表名
+	Info string `json:"info"`                          // ModelInfo的json
+}
+
+// ModelInfo 表的附加信息, 供map更新及软删除等按表名查找
+type ModelInfo struct {
+	Updated    []string   // updated字段名
+	SoftDelete *FieldInfo // softdelete字段, 未定义时为nil
+	Version    *FieldInfo // version字段, 未定义时为nil
+}
+
+// ModelInfoMap 表名->*ModelInfo, 由各数据库对象持有, 零值可用
+type ModelInfoMap struct {
+	m sync.Map
+}
+
+// NewModelInfo 由结构体字段生成表的附加信息
+func NewModelInfo(fieldInfoLis []*FieldInfo) (info *ModelInfo) {
+	info = new(ModelInfo)
+	for _, f := range fieldInfoLis {
+		if f.Updated {
+			info.Updated = append(info.Updated, f.Name)
+		}
+		if f.SoftDelete && info.SoftDelete == nil {
+			info.SoftDelete = f
+		}
+		if f.Version && info.Version == nil {
+			info.Version = f
+		}
+	}
+	return
+}
+
+// Init 表未登记时记录info, 已登记时保留原值; 用于从ModelInfoTable读取的结果
+func (s *ModelInfoMap) Init(table string, info *ModelInfo) {
+	s.m.LoadOrStore(table, info)
+}
+
+// Register 记录表的附加信息, 由Ensure/Create及ArgModel.Struct调用
+func (s *ModelInfoMap) Register(table string, fieldInfoLis []*FieldInfo) {
+	s.m.Store(table, NewModelInfo(fieldInfoLis))
+}
+
+// Get 读取表的附加信息, 未登记时返回nil
+func (s *ModelInfoMap) Get(table string) (info *ModelInfo) {
+	if s == nil {
+		return
+	}
+	if v, ok := s.m.Load(table); ok {
+		info = v.(*ModelInfo)
+	}
+	return
+}
+
+// ModelInfoSave 将表的附加信息写入ModelInfoTable
+func ModelInfoSave(db Database, table string, info *ModelInfo) (err error) {
+	if table == ModelInfoTable {
+		return
+	}
+	if info == nil {
+		info = new(ModelInfo)
+	}
+	var b []byte
+	if b, err = json.Marshal(info); err != nil {
+		return
+	}
+	m := db.Model(ModelInfoTable)
+	if err = m.Ensure(&modelInfoRecord{}); err != nil {
+		return
+	}
+	return m.Objects().Upsert(&modelInfoRecord{Name: table, Info: string(b)}, "name")
+}
+
+// ModelInfoLoad 从ModelInfoTable读取表的附加信息, 无记录或未建该表时返回空的ModelInfo
+func ModelInfoLoad(db Database, table string) (info *ModelInfo, err error) {
+	info = new(ModelInfo)
+	if table == ModelInfoTable {
+		return
+	}
+	var (
+		m   = db.Model(ModelInfoTable)
+		rec = new(modelInfoRecord)
+	)
+	if _, err = m.Inspect(); err == ErrSyncTableNotExist {
+		// 从未Ensure过, 不会有附加信息
+		return info, nil
+	} else if err != nil {
+		return
+	}
+	if err = m.Objects().Filter(M{"name": table}).One(rec); err == ErrMatchNone {
+		return info, nil
+	} else if err != nil {
+		return
+	}
+	err = json.Unmarshal([]byte(rec.Info), info)
+	return
+}
@@ -1,6 +1,7 @@
 package orm
 
 import (
+	"context"
 	"strings"
 )
 
@@ -13,28 +14,54 @@ type Objects interface {
 	Skip(int) Objects                // 跳过
 	Sort(...string) Objects          // 排序
 	Group(...string) Objects         // 去重
+	Fields(...string) Objects        // 只取指定字段
+	Omit(...string) Objects          // 排除字段
+	After(token string) Objects      // 游标分页: 取Meta.Next/Prev之后的记录, 需在Sort之后调用
 	Meta() (*Meta, error)            // 摘要信息
 	All(result interface{}) error    // 保存搜索结果至
 	One(result interface{}) error    // 取一条记录
 	Create(insert interface{}) error // 插入一条记录
+	CreateMany(st interface{}) error // 批量插入, 按驱动的占位符上限分批
 	Update(record interface{}) error // 更改(输入struct或map) *** struct为覆盖更新，map为局部更新
 	UpdateOne(obj interface{}) error // 只确保更改一条(输入struct或map)
-	Delete() error                   // 删除
-	DeleteOne() error                // 删除一条记录
+	Delete(...interface{}) error     // 删除, 传入的记录实现BeforeDelete时删除前回调
+	DeleteOne(...interface{}) error  // 删除一条记录
 	// 事务操作
 	TLockUpdate(t Trans) error                 // 行锁
 	TCount(t Trans) (int, error)               // 数目
 	TAll(result interface{}, t Trans) error    // 保存搜索结果至
 	TOne(result interface{}, t Trans) error    // 取一条记录
 	TCreate(insert interface{}, t Trans) error // 插入一条记录
+	TCreateMany(st interface{}, t Trans) error // 批量插入
 	TUpdate(obj interface{}, t Trans) error    // 更改
 	TUpdateOne(obj interface{}, t Trans) error // 更改一条
-	TDelete(t Trans) error                     // 删除
-	TDeleteOne(t Trans) error                  // 删除一条记录
+	TDelete(Trans, ...interface{}) error       // 删除
+	TDeleteOne(Trans, ...interface{}) error    // 删除一条记录
+	// 插入或更新: keys为冲突字段, 默认取主键或唯一索引; 冲突更新时version字段在原值上加1
+	// mysql以ON DUPLICATE KEY UPDATE实现, keys需恰为主键或唯一索引, 且任一唯一索引冲突都会更新该行
+	Upsert(record interface{}, keys ...string) error
+	TUpsert(record interface{}, t Trans, keys ...string) error
+	// 聚合: 无匹配记录时返回0
+	Sum(field string) (float64, error)                                          // 求和
+	Avg(field string) (float64, error)                                          // 平均值
+	Min(field string) (float64, error)                                          // 最小值
+	Max(field string) (float64, error)                                          // 最大值
+	Aggregate(groupBy []string, aggs map[string]string, dest interface{}) error // 分组聚合, aggs如{"total": "sum(amount)"}, 每组一行写入dest
+	// 软删除: 模型含`sorm:"softdelete"`字段时Delete只做标记, 搜索默认排除已删除的记录
+	WithDeleted() Objects   // 包含已软删除的记录
+	OnlyDeleted() Objects   // 只取已软删除的记录
+	Restore() error         // 恢复已软删除的记录
+	TRestore(t Trans) error // 在事务中恢复
+	// 游标
+	Iter() Iter                                               // 逐条读取搜索结果
+	Each(result interface{}, fn func() error) error           // 逐条读取至result并回调fn, fn返回错误时中止
+	TIter(t Trans) Iter                                       // 在事务中逐条读取
+	TEach(result interface{}, fn func() error, t Trans) error // 在事务中逐条读取并回调
 	// result
 	GetResult() (Result, error) // 取返回结果
 	// other
-	With(opt *ArgObjects) Objects // 以新的日志级别运行
+	With(opt *ArgObjects) Objects            // 以新的日志级别运行
+	WithContext(ctx context.Context) Objects // 绑定上下文,ctx取消或超时后语句中断并返回ErrContextCanceled
 }
 
 // MetaReader Meta对象操作
@@ -53,6 +80,29 @@ type ResultReader interface {
 	DataReader
 }
 
+// Iter 游标, 用完需Close
+type Iter interface {
+	Next(result interface{}) bool // 读取下一条记录至result, 读完或出错时返回false
+	Err() error                   // 迭代中的错误
+	Close() error                 // 关闭游标
+}
+
+// IterEach 逐条读取至result并回调fn, 结束后关闭游标
+func IterEach(it Iter, result interface{}, fn func() error) (err error) {
+	defer func() {
+		if _err := it.Close(); err == nil {
+			err = _err
+		}
+	}()
+	for it.Next(result) {
+		if err = fn(); err != nil {
+			return
+		}
+	}
+	err = it.Err()
+	return
+}
+
 // M 搜索条件
 type M map[string]interface{}
 
@@ -16,14 +16,25 @@ const (
 
 // project key
 const (
-	OrmKey        = "sorm"    //
-	OrmTagJSON    = "json"    // string to json
-	OrmTagChar    = "char"    // string to char
-	OrmTagSize    = "size"    // string size
-	OrmTagIndex   = "index"   //
-	OrmTagUnique  = "unique"  //
-	OrmTagPrimary = "primary" //
-	OrmTagSerial  = "serial"  //
+	OrmKey           = "sorm"       //
+	OrmTagJSON       = "json"       // string to json
+	OrmTagChar       = "char"       // string to char
+	OrmTagSize       = "size"       // string size
+	OrmTagIndex      = "index"      //
+	OrmTagUnique     = "unique"     //
+	OrmTagPrimary    = "primary"    //
+	OrmTagSerial     = "serial"     //
+	OrmTagCreated    = "created"    // 插入时自动填充当前时间
+	OrmTagUpdated    = "updated"    // 插入及更新时自动刷新当前时间
+	OrmTagSoftDelete = "softdelete" // 软删除: Delete改为标记该字段
+	OrmTagVersion    = "version"    // 乐观锁: 按结构体更新时校验并递增
+	OrmTagWas        = "was"        // 字段改名前的名称, 供EnsureWith重命名
+	OrmTagFk         = "fk"         // 外键: fk(table.column,ondelete=cascade,onupdate=restrict), sqlite需在连接参数中开启_foreign_keys
+	OrmTagNotNull    = "notnull"    // 字段不可空
+	OrmTagDefault    = "default"    // 默认值, 按SQL原样写入: default(0), default('guest')
+	OrmTagCheck      = "check"      // 检查约束: check(age >= 0)
+	OrmTagComment    = "comment"    // 字段注释
+	OrmTagText       = "text"       // 全文索引, 供$text$搜索
 )
 
 // Tag key
@@ -41,11 +52,21 @@ const (
 	// mean key
 	TagQueryKeyOr  = `$or$`
 	TagQueryKeyAnd = `$and$`
-	TagQueryKeyIn  = `$in$`
+	TagQueryKeyIn  = `$in$`  // 在列表中, 空列表不匹配任何记录
+	TagQueryKeyNin = `$nin$` // 不在列表中, 空列表匹配所有记录
 	// mean update
 	TagUpdateInc = `$inc$` // 数据库级别增
 )
 
+// 聚合函数
+const (
+	AggSum   = "sum"   // 求和
+	AggAvg   = "avg"   // 平均值
+	AggMin   = "min"   // 最小值
+	AggMax   = "max"   // 最大值
+	AggCount = "count" // 计数, 字段可为*
+)
+
 const (
 	// LevelPanic level, highest level of severity. Logs and then calls panic with the
 	// message passed to Debug, Info, ...
@@ -75,6 +96,14 @@ var (
 	LogLevel = LevelError
 	// TransTimeout 事务悬浮10分钟后自动回滚
 	TransTimeout = time.Minute * 10
+	// TransRetryMax RunInTrans默认最多执行3次
+	TransRetryMax = 3
+	// TransRetryBackoff RunInTrans首次重试前默认等待10毫秒, 之后逐次翻倍
+	TransRetryBackoff = time.Millisecond * 10
+	// HookTransRetryable : 按驱动判断错误是否可重试, 如序列化失败及死锁
+	HookTransRetryable = make(map[string]func(err error) bool)
+	// HookDuplicateKey : 按驱动判断错误是否为主键或唯一索引冲突
+	HookDuplicateKey = make(map[string]func(err error) bool)
 	// HookParseSafe : 将map过滤为安全的map
 	HookParseSafe = defaultHookParseSafe
 	// HookParseSQL : 将map转为sql
@@ -101,6 +130,10 @@ type Meta struct {
 	Key   interface{} `json:"key,omitempty"`   // 可选信息.刚才用户提交的搜索信息
 	Sort  interface{} `json:"sort,omitempty"`  // 可选信息.刚才用户提交的排序信息
 	Group interface{} `json:"group,omitempty"` // 可选信息.刚才用户提交的排序信息
+	Field interface{} `json:"field,omitempty"` // 可选信息.刚才用户指定的返回字段
+	Omit  interface{} `json:"omit,omitempty"`  // 可选信息.刚才用户排除的返回字段
+	Next  string      `json:"next,omitempty"`  // 可选信息.游标分页的下一页游标, 传给Objects.After
+	Prev  string      `json:"prev,omitempty"`  // 可选信息.游标分页的上一页游标
 }
 
 // Logger 日志输出
@@ -0,0 +1,380 @@
+package orm
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Plan中字段不一致的项
+const (
+	PlanDiffKind    = "kind"    // 类型或长度不一致
+	PlanDiffNull    = "null"    // 可空不一致
+	PlanDiffDefault = "default" // 默认值不一致
+)
+
+// Plan Ensure的预览结果, 由Model.Plan生成, 不执行任何语句
+type Plan struct {
+	Table         string        `json:"table"`
+	TableExist    bool          `json:"tableExist"`
+	ColumnMissing []*PlanColumn `json:"columnMissing"` // 库中缺少的字段, Ensure会添加
+	ColumnChanged []*PlanColumn `json:"columnChanged"` // 类型/长度/可空/默认值不一致, Ensure不会修改
+	ColumnExtra   []*PlanColumn `json:"columnExtra"`   // 库中有但struct未定义的字段
+	ColumnRenamed []*PlanColumn `json:"columnRenamed"` // 库中为was(旧名)的字段, 可由EnsureWith重命名
+	IndexMissing  []*PlanIndex  `json:"indexMissing"`  // 库中缺少的索引, Ensure会添加
+	IndexExtra    []*PlanIndex  `json:"indexExtra"`    // 库中有但struct未定义的索引
+	IndexChanged  []*PlanIndex  `json:"indexChanged"`  // 库中同名索引唯一性不一致, Ensure不会修改
+	FkMissing     []*FkInfo     `json:"fkMissing"`     // 库中缺少的外键
+	DDL           []string      `json:"ddl"`           // Ensure将执行的语句
+
+	columnExpect []*PlanColumn          // struct定义的字段
+	columnActual map[string]*PlanColumn // 库中字段
+	indexActual  []*PlanIndex           // 库中索引
+	indexExpect  map[string]bool        // struct定义的索引名
+	uniqueExpect map[string]bool        // 类型中带UNIQUE修饰的字段
+	fkActual     []*FkInfo              // 库中外键
+	primary      map[string]bool        // 主键字段
+}
+
+// PlanColumn 字段对比
+type PlanColumn struct {
+	Name       string   `json:"name"`
+	Was        string   `json:"was"`       // 改名前的字段名
+	Kind       string   `json:"kind"`      // 定义的类型, 如varchar(36)
+	Define     string   `json:"define"`    // 完整定义, 如varchar(36) DEFAULT '' NULL
+	Null       bool     `json:"null"`      // 定义是否可空
+	Default    string   `json:"default"`   // 定义的默认值
+	DBKind     string   `json:"dbKind"`    // 库中的类型
+	DBNull     bool     `json:"dbNull"`    // 库中是否可空
+	DBDefault  string   `json:"dbDefault"` // 库中的默认值
+	Diff       []string `json:"diff"`      // 不一致的项: kind null default
+	DefaultSQL string   `json:"-"`         // 定义的默认值原文, 如'0'
+}
+
+// TableInfo 库中表的结构, 由Model.Inspect读取
+type TableInfo struct {
+	Name    string        `json:"name"`
+	Columns []*ColumnInfo `json:"columns"`
+	Indexes []*IndexInfo  `json:"indexes"`
+	Fks     []*FkInfo     `json:"fks"`
+}
+
+// ColumnInfo 库中的字段
+type ColumnInfo struct {
+	Name    string `json:"name"`
+	Kind    string `json:"kind"`    // 库中的类型, 如varchar(36)
+	Null    bool   `json:"null"`    // 可空
+	Default string `json:"default"` // 默认值原文, 无默认值时为空
+	Primary bool   `json:"primary"` // 主键字段
+	Serial  bool   `json:"serial"`  // 自增字段
+}
+
+// IndexInfo 库中的索引
+type IndexInfo struct {
+	Name    string   `json:"name"`
+	Key     []string `json:"key"`
+	Unique  bool     `json:"unique"`
+	Primary bool     `json:"primary"` // 主键索引
+	Text    bool     `json:"text"`    // 全文索引
+}
+
+// FkInfo 库中的外键
+type FkInfo struct {
+	Name      string `json:"name"`
+	Column    string `json:"column"`
+	RefTable  string `json:"refTable"`
+	RefColumn string `json:"refColumn"`
+	OnDelete  string `json:"onDelete"` // 如CASCADE, 库的默认值时为空
+	OnUpdate  string `json:"onUpdate"`
+}
+
+// PlanIndex 索引对比
+type PlanIndex struct {
+	Name   string   `json:"name"`
+	Key    []string `json:"key"`
+	Unique bool     `json:"unique"`
+}
+
+var (
+	// planDefaultRe 取DEFAULT子句的值
+	planDefaultRe = regexp.MustCompile(`(?i)DEFAULT\s+('(?:[^']|'')*'|\S+)`)
+	// planCastRe pg默认值的类型转换, 如 '0'::numeric
+	planCastRe = regexp.MustCompile(`::[a-z ]+(\([0-9, ]*\))?$`)
+	// planKindAlias 同义类型
+	planKindAlias = map[string]string{
+		"int":                      "integer",
+		"int4":                     "integer",
+		"serial":                   "integer",
+		"int8":                     "bigint",
+		"bigserial":                "bigint",
+		"bool":                     "boolean",
+		"bit":                      "boolean",
+		"numeric":                  "decimal",
+		"character varying":        "varchar",
+		"character":                "char",
+		"double precision":         "float",
+		"float8":                   "float",
+		"timestamptz":              "timestamp with time zone",
+		"timestamp with time zone": "timestamp with time zone",
+	}
+	// planKindModifier 类型中的修饰词, 整词匹配; 唯一性由索引对比
+	planKindModifier = map[string]bool{
+		"auto_increment": true,
+		"unique":         true,
+		"identity(1,1)":  true,
+	}
+	// planTimeLayout 默认值中的时间格式
+	planTimeLayout = []string{
+		"2006-01-02 15:04:05Z07",
+		"2006-01-02 15:04:05Z07:00",
+		"2006-01-02 15:04:05 Z07:00",
+		"2006-01-02 15:04:05.999999999Z07",
+		"2006-01-02 15:04:05.999999999 Z07:00",
+		"2006-01-02 15:04:05",
+	}
+)
+
+// NewPlan 新建表的预览
+func NewPlan(table string) *Plan {
+	return &Plan{
+		Table:        table,
+		columnActual: make(map[string]*PlanColumn),
+		indexExpect:  make(map[string]bool),
+		uniqueExpect: make(map[string]bool),
+		primary:      make(map[string]bool),
+	}
+}
+
+// ColumnActual 登记库中的字段
+func (p *Plan) ColumnActual(name, kind string, null bool, def string) {
+	p.columnActual[strings.ToLower(name)] = &PlanColumn{
+		Name:      name,
+		DBKind:    kind,
+		DBNull:    null,
+		DBDefault: planDefault(def),
+	}
+}
+
+// TableActual 登记库中的字段及索引
+func (p *Plan) TableActual(info *TableInfo) {
+	for _, c := range info.Columns {
+		p.ColumnActual(c.Name, c.Kind, c.Null, c.Default)
+	}
+	for _, idx := range info.Indexes {
+		p.IndexActual(idx.Name, idx.Key, idx.Unique)
+	}
+	p.fkActual = append(p.fkActual, info.Fks...)
+}
+
+// IndexActual 登记库中的索引
+func (p *Plan) IndexActual(name string, key []string, unique bool) {
+	p.indexActual = append(p.indexActual, &PlanIndex{Name: name, Key: key, Unique: unique})
+}
+
+// ColumnExpect 登记struct定义的字段, kind为Ensure生成的类型, def为其后的DEFAULT/NULL子句
+func (p *Plan) ColumnExpect(f *FieldInfo, kind, def string) {
+	c := &PlanColumn{Name: f.Name, Was: f.Was, Kind: strings.TrimSpace(kind)}
+	c.Define = strings.TrimSpace(c.Kind + " " + strings.TrimSpace(def))
+	// 主键及无默认值的自增字段不可空
+	c.Null = !strings.Contains(strings.ToUpper(def), "NOT NULL") && !(f.Serial && len(def) == 0)
+	if s := planDefaultRe.FindStringSubmatch(def); len(s) > 1 {
+		c.Default, c.DefaultSQL = planDefault(s[1]), s[1]
+	}
+	if planKindHas(c.Kind, "unique") {
+		p.uniqueExpect[strings.ToLower(f.Name)] = true
+	}
+	if f.Primary {
+		for _, k := range f.PrimaryKeys {
+			p.primary[strings.ToLower(k)] = true
+		}
+	}
+	p.columnExpect = append(p.columnExpect, c)
+}
+
+// IndexExpect 登记struct定义的索引, 返回库中是否已存在; 不存在时计入IndexMissing, 唯一性不一致时计入IndexChanged
+func (p *Plan) IndexExpect(name string, key []string, unique bool) (exist bool) {
+	name = strings.ToLower(name)
+	p.indexExpect[name] = true
+	for _, idx := range p.indexActual {
+		if strings.ToLower(idx.Name) == name {
+			if idx.Unique != unique {
+				p.IndexChanged = append(p.IndexChanged, &PlanIndex{Name: name, Key: key, Unique: unique})
+			}
+			return true
+		}
+	}
+	p.IndexMissing = append(p.IndexMissing, &PlanIndex{Name: name, Key: key, Unique: unique})
+	return
+}
+
+// FkExpect 登记struct定义的外键, 返回库中是否已存在同字段同引用的外键; 不存在时计入FkMissing
+func (p *Plan) FkExpect(name string, f *FieldInfo) (exist bool) {
+	for _, fk := range p.fkActual {
+		if strings.EqualFold(fk.Column, f.Name) && strings.EqualFold(fk.RefTable, f.Fk.Table) &&
+			strings.EqualFold(fk.RefColumn, f.Fk.Column) {
+			return true
+		}
+	}
+	p.FkMissing = append(p.FkMissing, &FkInfo{Name: name, Column: f.Name, RefTable: f.Fk.Table,
+		RefColumn: f.Fk.Column, OnDelete: f.Fk.OnDelete, OnUpdate: f.Fk.OnUpdate})
+	return
+}
+
+// Diff 对比登记的字段及索引, 填充ColumnMissing ColumnChanged IndexExtra
+func (p *Plan) Diff() {
+	var (
+		used      = make(map[string]bool)
+		indexUsed = make(map[*PlanIndex]bool)
+	)
+	p.ColumnMissing, p.ColumnChanged, p.ColumnExtra, p.ColumnRenamed, p.IndexExtra = nil, nil, nil, nil, nil
+	for _, c := range p.columnExpect {
+		if p.primary[strings.ToLower(c.Name)] {
+			c.Null = false
+		}
+		a, ok := p.columnActual[strings.ToLower(c.Name)]
+		if !ok && len(c.Was) > 0 {
+			// 新名不存在而旧名存在
+			if a, ok = p.columnActual[strings.ToLower(c.Was)]; ok && !used[strings.ToLower(c.Was)] {
+				c.DBKind, c.DBNull, c.DBDefault = a.DBKind, a.DBNull, a.DBDefault
+				used[strings.ToLower(c.Was)] = true
+				p.ColumnRenamed = append(p.ColumnRenamed, c)
+				continue
+			}
+		}
+		if !ok {
+			p.ColumnMissing = append(p.ColumnMissing, c)
+			continue
+		}
+		used[strings.ToLower(c.Name)] = true
+		c.DBKind, c.DBNull, c.DBDefault, c.Diff = a.DBKind, a.DBNull, a.DBDefault, nil
+		if planKind(c.Kind) != planKind(c.DBKind) {
+			c.Diff = append(c.Diff, PlanDiffKind)
+		}
+		if c.Null != c.DBNull {
+			c.Diff = append(c.Diff, PlanDiffNull)
+		}
+		if len(c.DefaultSQL) > 0 && !planDefaultEqual(c.Default, c.DBDefault) {
+			c.Diff = append(c.Diff, PlanDiffDefault)
+		}
+		if len(c.Diff) > 0 {
+			p.ColumnChanged = append(p.ColumnChanged, c)
+		}
+		if p.uniqueExpect[strings.ToLower(c.Name)] {
+			// 类型中的UNIQUE由库生成单字段索引, 不算多余
+			for _, idx := range p.indexActual {
+				if len(idx.Key) != 1 || !strings.EqualFold(idx.Key[0], c.Name) || p.indexExpect[strings.ToLower(idx.Name)] {
+					continue
+				}
+				indexUsed[idx] = true
+				if !idx.Unique {
+					p.IndexChanged = append(p.IndexChanged, &PlanIndex{Name: idx.Name, Key: idx.Key, Unique: true})
+				}
+				break
+			}
+		}
+	}
+	for name, a := range p.columnActual {
+		if !used[name] {
+			p.ColumnExtra = append(p.ColumnExtra, a)
+		}
+	}
+	sort.Slice(p.ColumnExtra, func(i, j int) bool { return p.ColumnExtra[i].Name < p.ColumnExtra[j].Name })
+	for _, idx := range p.indexActual {
+		if !p.indexExpect[strings.ToLower(idx.Name)] && !indexUsed[idx] {
+			p.IndexExtra = append(p.IndexExtra, idx)
+		}
+	}
+}
+
+// Empty 库与struct一致, Ensure无需执行任何语句
+func (p *Plan) Empty() bool {
+	return len(p.DDL) == 0 && len(p.ColumnMissing) == 0 && len(p.ColumnChanged) == 0 &&
+		len(p.ColumnExtra) == 0 && len(p.ColumnRenamed) == 0 && len(p.IndexMissing) == 0 && len(p.IndexExtra) == 0 &&
+		len(p.IndexChanged) == 0 && len(p.FkMissing) == 0
+}
+
+// planKind 统一类型写法以便比较: 小写, 去空格及自增/唯一修饰, 同义词, 整型去显示宽度
+func planKind(s string) (r string) {
+	var lis []string
+	for _, w := range strings.Fields(strings.ToLower(s)) {
+		if !planKindModifier[w] {
+			lis = append(lis, w)
+		}
+	}
+	r = strings.Join(lis, " ")
+	if r == "tinyint(1)" {
+		return "boolean"
+	}
+	base, args := r, ""
+	if i := strings.Index(r, "("); i >= 0 {
+		base, args = strings.TrimSpace(r[:i]), strings.Replace(r[i:], " ", "", -1)
+	}
+	if v, ok := planKindAlias[base]; ok {
+		base = v
+	}
+	switch base {
+	case "integer", "bigint", "smallint", "tinyint", "mediumint":
+		args = ""
+	case "decimal":
+		args = strings.Replace(args, ",0)", ")", 1) // 标度为0时省略
+	}
+	return base + args
+}
+
+// planKindHas 类型中是否带某修饰词
+func planKindHas(s, w string) bool {
+	for _, v := range strings.Fields(s) {
+		if strings.EqualFold(v, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// planDefault 去掉默认值的括号, 类型转换及引号
+func planDefault(s string) (r string) {
+	r = strings.TrimSpace(s)
+	for len(r) > 1 && r[0] == '(' && r[len(r)-1] == ')' {
+		r = strings.TrimSpace(r[1 : len(r)-1])
+	}
+	r = planCastRe.ReplaceAllString(r, "")
+	if len(r) > 2 && (r[0] == 'N' || r[0] == 'n') && r[1] == '\'' {
+		r = r[1:]
+	}
+	if len(r) > 1 && r[0] == '\'' && r[len(r)-1] == '\'' {
+		r = strings.Replace(r[1:len(r)-1], "''", "'", -1)
+	} else if strings.ToLower(r) == "null" {
+		r = ""
+	}
+	return
+}
+
+// planDefaultEqual 默认值比较, 数值与时间按值比较
+func planDefaultEqual(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			return x == y
+		}
+	}
+	if x, ok := planTime(a); ok {
+		if y, ok := planTime(b); ok {
+			return x.Equal(y)
+		}
+	}
+	return strings.EqualFold(a, b)
+}
+
+// planTime 解析默认值中的时间
+func planTime(s string) (t time.Time, ok bool) {
+	for _, l := range planTimeLayout {
+		if v, err := time.Parse(l, s); err == nil {
+			return v, true
+		}
+	}
+	return
+}
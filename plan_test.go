@@ -0,0 +1,198 @@
+package orm
+
+import (
+	"testing"
+)
+
+func TestPlanKind(t *testing.T) {
+	for _, v := range [][2]string{
+		{"varchar(36)", "character varying(36)"},
+		{"decimal (20,8)", "numeric(20,8)"},
+		{"int(11)", "integer"},
+		{"int AUTO_INCREMENT", "int"},
+		{"int IDENTITY(1,1)", "int"},
+		{"int UNIQUE AUTO_INCREMENT", "int"},
+		{"boolean", "tinyint(1)"},
+		{"bit", "boolean"},
+		{"float", "double precision"},
+		{"timestamp with time zone", "timestamptz"},
+	} {
+		if planKind(v[0]) != planKind(v[1]) {
+			t.Fatalf("%s(%s) != %s(%s)", v[0], planKind(v[0]), v[1], planKind(v[1]))
+		}
+	}
+	if planKind("varchar(36)") == planKind("varchar(64)") {
+		t.Fatal("size mismatch expected")
+	}
+	if planKind("uniqueidentifier") == planKind("identifier") {
+		t.Fatal("modifier stripped inside word")
+	}
+}
+
+func TestPlanDefault(t *testing.T) {
+	for _, v := range [][2]string{
+		{"''::character varying", ""},
+		{"'0'::numeric", "0"},
+		{"((0))", "0"},
+		{"(N'it''s')", "it's"},
+		{"NULL", ""},
+		{"false", "false"},
+	} {
+		if s := planDefault(v[0]); s != v[1] {
+			t.Fatalf("%s: %s != %s", v[0], s, v[1])
+		}
+	}
+	if !planDefaultEqual("0", "0.00000000") || !planDefaultEqual(DefaultTimeStr, "0001-01-01 00:00:00") {
+		t.Fatal("expect equal")
+	}
+	if planDefaultEqual("0", "1") {
+		t.Fatal("expect not equal")
+	}
+}
+
+func TestPlanDiff(t *testing.T) {
+	type planRecord struct {
+		UID   string `sorm:"primary size(36)"`
+		Name  string `sorm:"size(32)"`
+		Email string `sorm:"size(64)"`
+	}
+	lis, err := StructModelInfo(&planRecord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlan("plan_record")
+	p.TableExist = true
+	p.ColumnActual("uid", "character varying(36)", false, "''::character varying")
+	p.ColumnActual("name", "character varying(16)", true, "''::character varying")
+	p.IndexActual("plan_record_pkey", []string{"uid"}, true)
+	p.IndexActual("plan_record_old", []string{"name"}, false)
+	for _, f := range lis {
+		p.ColumnExpect(f, "varchar("+map[string]string{"uid": "36", "name": "32", "email": "64"}[f.Name]+")", "DEFAULT '' NULL")
+	}
+	if p.IndexExpect("plan_record_pkey", []string{"uid"}, true) != true {
+		t.Fatal("expect pkey exist")
+	}
+	if p.IndexExpect("plan_record_email", []string{"email"}, false) != false {
+		t.Fatal("expect index missing")
+	}
+	p.Diff()
+	if len(p.ColumnMissing) != 1 || p.ColumnMissing[0].Name != "email" {
+		t.Fatalf("missing %s", JSONMust(p.ColumnMissing))
+	}
+	if len(p.ColumnChanged) != 1 || p.ColumnChanged[0].Name != "name" || p.ColumnChanged[0].Diff[0] != PlanDiffKind {
+		t.Fatalf("changed %s", JSONMust(p.ColumnChanged))
+	}
+	if len(p.IndexMissing) != 1 || len(p.IndexExtra) != 1 || p.IndexExtra[0].Name != "plan_record_old" {
+		t.Fatalf("index %s %s", JSONMust(p.IndexMissing), JSONMust(p.IndexExtra))
+	}
+	if p.Empty() {
+		t.Fatal("expect not empty")
+	}
+}
+
+func TestPlanIndexUnique(t *testing.T) {
+	type uniqueRecord struct {
+		UID  string `sorm:"primary size(36)"`
+		Name string `sorm:"size(32)"`
+		Seq  int    `sorm:"serial"`
+	}
+	lis, err := StructModelInfo(&uniqueRecord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kind := map[string]string{"uid": "varchar(36)", "name": "varchar(32)", "seq": "int UNIQUE AUTO_INCREMENT"}
+
+	// 同名索引唯一性不一致, 类型中UNIQUE生成的索引不算多余
+	p := NewPlan("unique_record")
+	p.ColumnActual("uid", "varchar(36)", false, "")
+	p.ColumnActual("name", "varchar(32)", false, "")
+	p.ColumnActual("seq", "int(11)", false, "")
+	p.IndexActual("unique_record_name", []string{"name"}, false)
+	p.IndexActual("seq", []string{"seq"}, true)
+	for _, f := range lis {
+		p.ColumnExpect(f, kind[f.Name], "NOT NULL")
+	}
+	if p.IndexExpect("unique_record_name", []string{"name"}, true) != true {
+		t.Fatal("expect index exist")
+	}
+	p.Diff()
+	if len(p.ColumnChanged) != 0 || len(p.IndexExtra) != 0 || len(p.IndexMissing) != 0 {
+		t.Fatalf("plan %s", JSONMust(p))
+	}
+	if len(p.IndexChanged) != 1 || p.IndexChanged[0].Name != "unique_record_name" || !p.IndexChanged[0].Unique {
+		t.Fatalf("index changed %s", JSONMust(p.IndexChanged))
+	}
+	if p.Empty() {
+		t.Fatal("expect not empty")
+	}
+
+	// 类型要求UNIQUE而库中索引非唯一
+	p = NewPlan("unique_record")
+	p.ColumnActual("uid", "varchar(36)", false, "")
+	p.ColumnActual("name", "varchar(32)", false, "")
+	p.ColumnActual("seq", "int(11)", false, "")
+	p.IndexActual("seq", []string{"seq"}, false)
+	for _, f := range lis {
+		p.ColumnExpect(f, kind[f.Name], "NOT NULL")
+	}
+	p.Diff()
+	if len(p.IndexExtra) != 0 || len(p.IndexChanged) != 1 || p.IndexChanged[0].Name != "seq" {
+		t.Fatalf("plan %s", JSONMust(p))
+	}
+}
+
+func TestPlanRename(t *testing.T) {
+	type renameRecord struct {
+		UID   string `sorm:"primary size(36)"`
+		Alias string `sorm:"size(16);was(nick)"`
+	}
+	lis, err := StructModelInfo(&renameRecord{})
+	if err != nil {
+		t.Fatal(err)
+	} else if lis[1].Was != "nick" {
+		t.Fatalf("was %s", lis[1].Was)
+	}
+	p := NewPlan("rename_record")
+	p.TableExist = true
+	p.ColumnActual("uid", "varchar(36)", false, "")
+	p.ColumnActual("nick", "varchar(16)", true, "")
+	p.ColumnActual("old", "integer", true, "0")
+	p.ColumnExpect(lis[0], "varchar(36)", "")
+	p.ColumnExpect(lis[1], "varchar(16)", "DEFAULT '' NULL")
+	p.Diff()
+	if len(p.ColumnRenamed) != 1 || p.ColumnRenamed[0].Name != "alias" || len(p.ColumnMissing) != 0 {
+		t.Fatalf("renamed %s", JSONMust(p))
+	}
+	if len(p.ColumnExtra) != 1 || p.ColumnExtra[0].Name != "old" {
+		t.Fatalf("extra %s", JSONMust(p.ColumnExtra))
+	}
+	if p.ColumnRenamed[0].Define != "varchar(16) DEFAULT '' NULL" || p.ColumnRenamed[0].DefaultSQL != "''" {
+		t.Fatalf("define %s", JSONMust(p.ColumnRenamed[0]))
+	}
+}
+
+func TestPlanFk(t *testing.T) {
+	type fkRecord struct {
+		UID     string `sorm:"primary size(36)"`
+		UserUID string `sorm:"size(36);fk(fk_user.uid,ondelete=cascade)"`
+		ShopID  int    `sorm:"fk(fk_shop.id)"`
+	}
+	lis, err := StructModelInfo(&fkRecord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := NewPlan("fk_record")
+	p.TableExist = true
+	p.TableActual(&TableInfo{Name: "fk_record", Fks: []*FkInfo{
+		{Name: "custom_fk", Column: "useruid", RefTable: "FK_USER", RefColumn: "uid", OnDelete: "CASCADE"},
+	}})
+	if !p.FkExpect("fk_record_useruid_fkey", lis[1]) {
+		t.Fatal("fk should exist")
+	}
+	if p.FkExpect("fk_record_shopid_fkey", lis[2]) {
+		t.Fatal("fk should missing")
+	}
+	if len(p.FkMissing) != 1 || p.FkMissing[0].RefTable != "fk_shop" || p.Empty() {
+		t.Fatalf("missing %s", JSONMust(p.FkMissing))
+	}
+}
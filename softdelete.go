@@ -0,0 +1,75 @@
+package orm
+
+import (
+	"reflect"
+	"time"
+)
+
+// 软删除的搜索范围
+const (
+	SoftDeleteExclude = iota // 默认: 排除已软删除的记录
+	SoftDeleteWith           // 包含已软删除的记录
+	SoftDeleteOnly           // 只取已软删除的记录
+)
+
+var (
+	// SoftDeleteTimeMin 时间类型的softdelete字段大于等于此值时视为已删除, 零值及mysql填充的零值均小于此值
+	SoftDeleteTimeMin = time.Unix(60*60*24, 0).UTC()
+	// SoftDeleteTimeNone 恢复时写入的时间
+	SoftDeleteTimeNone = time.Unix(0, 0).UTC()
+)
+
+// SoftDeleteQuery 按搜索范围给query追加软删除条件, info为nil或未定义softdelete字段时原样返回
+// 指针字段为NULL时视为未删除
+func SoftDeleteQuery(info *ModelInfo, query M, scope int) M {
+	if info == nil || info.SoftDelete == nil || scope == SoftDeleteWith {
+		return query
+	}
+	var (
+		f    = info.SoftDelete
+		null = f.nullable()
+		cond M
+	)
+	switch {
+	case f.Kind == "boolean" && scope == SoftDeleteOnly:
+		cond = M{f.Name: true}
+	case f.Kind == "boolean" && null:
+		cond = M{TagQueryKeyOr: []interface{}{map[string]interface{}{f.Name: nil}, map[string]interface{}{f.Name: false}}}
+	case f.Kind == "boolean":
+		// 兼容mongo中缺少该字段的文档
+		cond = M{f.Name + TagValNe: true}
+	case null && scope == SoftDeleteOnly:
+		cond = M{f.Name + TagValNe: nil}
+	case null:
+		cond = M{f.Name: nil}
+	case scope == SoftDeleteOnly:
+		cond = M{f.Name + TagValGte: SoftDeleteTimeMin}
+	default:
+		cond = M{f.Name + TagValLt: SoftDeleteTimeMin}
+	}
+	return QueryAnd(query, cond)
+}
+
+// SoftDeleteSet 软删除(deleted为true)或恢复时需更新的字段, 未定义softdelete字段时ok为false
+func SoftDeleteSet(info *ModelInfo, deleted bool) (set map[string]interface{}, ok bool) {
+	if info == nil || info.SoftDelete == nil {
+		return
+	}
+	f := info.SoftDelete
+	switch {
+	case f.Kind == "boolean":
+		set = map[string]interface{}{f.Name: deleted}
+	case deleted:
+		set = map[string]interface{}{f.Name: time.Now()}
+	case f.nullable():
+		set = map[string]interface{}{f.Name: nil}
+	default:
+		set = map[string]interface{}{f.Name: SoftDeleteTimeNone}
+	}
+	return set, true
+}
+
+// nullable 字段为指针时可存NULL
+func (f *FieldInfo) nullable() bool {
+	return reflect.Kind(f.ReflectKind) == reflect.Ptr
+}
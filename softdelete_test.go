@@ -0,0 +1,78 @@
+package orm
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSoftDelete(t *testing.T) {
+	var models ModelInfoMap
+	type softRecord struct {
+		Name       string
+		DeleteTime time.Time `sorm:"softdelete"`
+	}
+	type flagRecord struct {
+		Name    string
+		Deleted bool `sorm:"softdelete"`
+	}
+	type ptrRecord struct {
+		Name       string
+		DeleteTime *time.Time `sorm:"softdelete"`
+	}
+	type ptrFlagRecord struct {
+		Deleted *bool `sorm:"softdelete"`
+	}
+	for table, st := range map[string]interface{}{"soft_record": &softRecord{}, "flag_record": &flagRecord{},
+		"ptr_record": &ptrRecord{}, "ptrflag_record": &ptrFlagRecord{}} {
+		lis, err := StructModelInfo(st)
+		if err != nil {
+			t.Fatal(err)
+		}
+		models.Register(table, lis)
+	}
+
+	q := M{"name": "a"}
+	if m := SoftDeleteQuery(models.Get("soft_record"), q, SoftDeleteExclude); len(m[TagQueryKeyAnd].([]interface{})) != 2 {
+		t.Fatalf("get %v", m)
+	}
+	if m := SoftDeleteQuery(models.Get("soft_record"), nil, SoftDeleteOnly); m["deletetime"+TagValGte] != SoftDeleteTimeMin {
+		t.Fatalf("get %v", m)
+	}
+	if m := SoftDeleteQuery(models.Get("flag_record"), nil, SoftDeleteExclude); m["deleted"+TagValNe] != true {
+		t.Fatalf("get %v", m)
+	}
+	if m := SoftDeleteQuery(models.Get("soft_record"), q, SoftDeleteWith); len(m) != 1 {
+		t.Fatalf("get %v", m)
+	}
+	// 指针时间为NULL时未删除
+	if m := SoftDeleteQuery(models.Get("ptr_record"), nil, SoftDeleteExclude); len(m) != 1 || m["deletetime"] != nil {
+		t.Fatalf("get %v", m)
+	}
+	if m := SoftDeleteQuery(models.Get("ptr_record"), nil, SoftDeleteOnly); len(m) != 1 || m["deletetime"+TagValNe] != nil {
+		t.Fatalf("get %v", m)
+	}
+	if m := SoftDeleteQuery(models.Get("ptrflag_record"), nil, SoftDeleteExclude); len(m[TagQueryKeyOr].([]interface{})) != 2 {
+		t.Fatalf("get %v", m)
+	}
+
+	if set, ok := SoftDeleteSet(models.Get("soft_record"), true); !ok || set["deletetime"].(time.Time).Before(SoftDeleteTimeMin) {
+		t.Fatalf("get %v", set)
+	}
+	if set, ok := SoftDeleteSet(models.Get("flag_record"), false); !ok || set["deleted"] != false {
+		t.Fatalf("get %v", set)
+	}
+	if set, ok := SoftDeleteSet(models.Get("ptr_record"), true); !ok || set["deletetime"].(time.Time).IsZero() {
+		t.Fatalf("get %v", set)
+	}
+	if set, ok := SoftDeleteSet(models.Get("ptr_record"), false); !ok || set["deletetime"] != nil {
+		t.Fatalf("get %v", set)
+	}
+
+	// 不支持的字段类型
+	type badRecord struct {
+		Deleted string `sorm:"softdelete"`
+	}
+	if _, err := StructModelInfo(&badRecord{}); err != ErrSyncTagKindInvalid {
+		t.Fatal(err)
+	}
+}
@@ -30,8 +30,10 @@ const (
 	TagQueryKeyOr = `$or$`
 	// TagQueryKeyAnd and
 	TagQueryKeyAnd = `$and$`
-	// TagQueryKeyIn in
+	// TagQueryKeyIn in, 空列表不匹配任何记录
 	TagQueryKeyIn = `$in$`
+	// TagQueryKeyNin not in, 空列表匹配所有记录
+	TagQueryKeyNin = `$nin$`
 	// TagUpdateInc mean update
 	TagUpdateInc = `$inc$` // 数据库级别增
 )
@@ -55,11 +57,17 @@ const (
 	SQLValAnd = `AND`
 	// SQLValOr 或
 	SQLValOr = `OR`
+	// SQLValIn 在列表中
+	SQLValIn = `IN`
+	// SQLValNin 不在列表中
+	SQLValNin = `NOT IN`
 )
 
 var (
 	// ParseMapMax 目前允许5层解析
 	ParseMapMax = 5
+	// TextConfig postgres全文搜索的分词配置, 建索引与查询需一致
+	TextConfig = "simple"
 )
 
 // ParseSQL 将songo格式解析为sql
@@ -67,7 +75,7 @@ func ParseSQL(m map[string]interface{}, prefix int) (sql string, vals []interfac
 	if err = isSongoMapValid(m); err != nil {
 		return
 	}
-	sql, vals, err = parserSQL(m, prefix, "$")
+	sql, vals, err = parserSQL(m, prefix, "$", TextPg)
 	return
 }
 
@@ -76,7 +84,20 @@ func ParseMysql(m map[string]interface{}, prefix int) (sql string, vals []interf
 	if err = isSongoMapValid(m); err != nil {
 		return
 	}
-	sql, vals, err = parserSQL(m, prefix, "?")
+	sql, vals, err = parserSQL(m, prefix, "?", TextMysql)
+	return
+}
+
+// ParseSqlite 将songo格式解析为sqlite, $text$在表的FTS5影子表中匹配; table为空时退化为like
+func ParseSqlite(m map[string]interface{}, prefix int, table string) (sql string, vals []interface{}, err error) {
+	if err = isSongoMapValid(m); err != nil {
+		return
+	}
+	var text TextFunc
+	if len(table) > 0 {
+		text = TextSqlite(table)
+	}
+	sql, vals, err = parserSQL(m, prefix, "?", text)
 	return
 }
 
@@ -106,6 +127,6 @@ func ParseMssql(m map[string]interface{}, prefix int) (sql string, vals []interf
 	if err = isSongoMapValid(m); err != nil {
 		return
 	}
-	sql, vals, err = parserSQL(m, prefix, "$")
+	sql, vals, err = parserSQL(m, prefix, "$", TextMssql)
 	return
 }
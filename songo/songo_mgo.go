@@ -29,6 +29,8 @@ func songoTagMongo(tag string) string {
 		tag = "$and"
 	case TagQueryKeyIn:
 		tag = "$in"
+	case TagQueryKeyNin:
+		tag = "$nin"
 	case TagUpdateInc:
 		tag = "$inc"
 	default:
@@ -213,6 +215,13 @@ func parseM(s *map[string]interface{}, deep int) (d *map[string]interface{}, err
 				break
 			}
 
+			// 全文搜索: mongo按集合的text索引匹配, 不区分字段
+			if tag == TagValText {
+				delete(*s, k)
+				(*s)["$text"] = map[string]interface{}{"$search": vFix}
+				continue
+			}
+
 			// 值转换
 			if len(tag) > 0 {
 				// tag转换
@@ -2,6 +2,7 @@ package songo
 
 import (
 	"fmt"
+	"reflect"
 	"sort"
 	"strings"
 )
@@ -11,8 +12,141 @@ const (
 	sqlSepOr  = " " + SQLValOr + " "
 )
 
-// parserSQLUnit 转为sql条件语句 TODO: 全文搜索
-func parserSQLUnit(k string, v interface{}, idx int, sep string) (sql string, val interface{}, err error) {
+// sqlValLis in/nin 展开后的值, 每个值占用一个占位符
+type sqlValLis []interface{}
+
+// TextFunc 生成全文搜索条件, k为字段名, holder为值的占位符
+type TextFunc func(k string, holder string) string
+
+// TextPg postgres: 按TextConfig分词匹配, 与Ensure建立的gin表达式索引一致
+func TextPg(k string, holder string) string {
+	return fmt.Sprintf(`to_tsvector('%s', "%s") @@ plainto_tsquery('%s', %s)`, TextConfig, k, TextConfig, holder)
+}
+
+// TextMysql mysql: 自然语言模式匹配FULLTEXT索引
+func TextMysql(k string, holder string) string {
+	return fmt.Sprintf("MATCH (`%s`) AGAINST (%s IN NATURAL LANGUAGE MODE)", k, holder)
+}
+
+// TextMssql mssql: 匹配全文索引
+func TextMssql(k string, holder string) string {
+	return fmt.Sprintf(`FREETEXT("%s", %s)`, k, holder)
+}
+
+// TextSqlite sqlite: 在table_字段_fts的FTS5影子表中匹配, 值为FTS5查询语法
+func TextSqlite(table string) TextFunc {
+	return func(k string, holder string) string {
+		return fmt.Sprintf(`rowid IN (SELECT rowid FROM "%s_%s_fts" WHERE "%s_%s_fts" MATCH %s)`,
+			table, k, table, k, holder)
+	}
+}
+
+// TextField 查找条件中的首个全文搜索, 返回字段名及搜索值; 只查找顶层及$and$内的条件
+func TextField(m map[string]interface{}) (k string, v interface{}, ok bool) {
+	lis := make([]string, 0, len(m))
+	for _k := range m {
+		lis = append(lis, _k)
+	}
+	sort.Strings(lis)
+	for _, _k := range lis {
+		_v := m[_k]
+		oper, comp, key := keyParse(strings.ToLower(_k))
+		if oper == TagQueryKeyAnd {
+			_lis, _ok := _v.([]interface{})
+			if !_ok {
+				_lis = []interface{}{_v}
+			}
+			for _, v2 := range _lis {
+				if m2, _ok := v2.(map[string]interface{}); _ok {
+					if k, v, ok = TextField(m2); ok {
+						return
+					}
+				}
+			}
+			continue
+		} else if len(oper) > 0 {
+			continue
+		}
+		switch val := _v.(type) {
+		case string:
+			if tag, s := isTagVal(val); len(comp) == 0 && tag == TagValText {
+				return key, s, true
+			}
+		case map[string]interface{}:
+			if v2, _ok := val[TagValText]; _ok && len(comp) == 0 && len(val) == 1 {
+				return key, v2, true
+			}
+		}
+		if comp == TagValText {
+			return key, _v, true
+		}
+	}
+	return
+}
+
+// parserSQLIn 转为in/nin条件语句
+func parserSQLIn(k string, tag string, v interface{}, idx int, sep string) (sql string, val sqlValLis, err error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			// []byte 不视为列表
+			err = ErrSongoFormatInvalid
+			return
+		}
+	default:
+		err = ErrSongoFormatInvalid
+		return
+	}
+
+	// 空列表: in 恒假, nin 恒真
+	val = make(sqlValLis, rv.Len())
+	if len(val) == 0 {
+		if tag == TagQueryKeyIn {
+			sql = "1 = 0"
+		} else {
+			sql = "1 = 1"
+		}
+		return
+	}
+
+	oper := SQLValIn
+	if tag == TagQueryKeyNin {
+		oper = SQLValNin
+	}
+	holders := make([]string, len(val))
+	for i := range val {
+		val[i] = rv.Index(i).Interface()
+		if sep == "?" {
+			holders[i] = "?"
+		} else {
+			holders[i] = fmt.Sprintf("$%d", idx+i)
+		}
+	}
+	if sep == "?" {
+		sql = fmt.Sprintf("`%s` %s (%s)", k, oper, strings.Join(holders, ", "))
+	} else {
+		sql = fmt.Sprintf(`"%s" %s (%s)`, k, oper, strings.Join(holders, ", "))
+	}
+	return
+}
+
+// parserSQLVal 记录解析出的值, in/nin 按实际占位符数目修正序号; nil转为IS NULL, 不占用占位符
+func parserSQLVal(prefix *int, vals *[]interface{}, val interface{}) {
+	if val == nil {
+		*prefix--
+		return
+	}
+	if lis, ok := val.(sqlValLis); ok {
+		*vals = append(*vals, lis...)
+		*prefix += len(lis) - 1
+		return
+	}
+	*vals = append(*vals, val)
+}
+
+// parserSQLUnit 转为sql条件语句, text为空时全文搜索退化为like
+func parserSQLUnit(k string, v interface{}, idx int, sep string, text TextFunc) (sql string, val interface{}, err error) {
 	// valid
 	if len(k) != 0 {
 		k = strings.ToLower(k)
@@ -91,7 +225,17 @@ func parserSQLUnit(k string, v interface{}, idx int, sep string) (sql string, va
 	// sql
 	if len(tag) > 0 {
 		switch tag {
-		case TagValLike, TagValText: // TODO: 全文搜索
+		case TagValText:
+			if text != nil {
+				holder := "?"
+				if sep != "?" {
+					holder = fmt.Sprintf("$%d", idx)
+				}
+				sql = text(k, holder)
+				break
+			}
+			fallthrough
+		case TagValLike:
 			if sep == "?" {
 				sql = fmt.Sprintf("`%s` %s ?", k, SQLValLike)
 			} else {
@@ -127,18 +271,28 @@ func parserSQLUnit(k string, v interface{}, idx int, sep string) (sql string, va
 			}
 			// break
 		case TagValNo, TagValNe:
-			if sep == "?" {
+			if val == nil && sep == "?" {
+				sql = fmt.Sprintf("`%s` IS NOT NULL", k)
+			} else if val == nil {
+				sql = fmt.Sprintf(`"%s" IS NOT NULL`, k)
+			} else if sep == "?" {
 				sql = fmt.Sprintf("`%s` %s ?", k, SQLValNe)
 			} else {
 				sql = fmt.Sprintf(`"%s" %s $%d`, k, SQLValNe, idx)
 			}
+		case TagQueryKeyIn, TagQueryKeyNin:
+			sql, val, err = parserSQLIn(k, tag, val, idx, sep)
 		default:
 			err = ErrSongoMapOperatorInvalid
 			return
 		}
 	} else {
 		if sep == "?" {
-			sql = fmt.Sprintf("`%s` %s ?", k, SQLValEq)
+			if val == nil {
+				sql = fmt.Sprintf("`%s` IS NULL", k)
+			} else {
+				sql = fmt.Sprintf("`%s` %s ?", k, SQLValEq)
+			}
 		} else {
 			if val == nil {
 				sql = fmt.Sprintf(`"%s" IS NULL`, k)
@@ -152,7 +306,7 @@ func parserSQLUnit(k string, v interface{}, idx int, sep string) (sql string, va
 }
 
 // parserSQL 解析M为sql
-func parserSQL(m map[string]interface{}, prefix int, sep string) (sql string, vals []interface{}, err error) {
+func parserSQL(m map[string]interface{}, prefix int, sep string, text TextFunc) (sql string, vals []interface{}, err error) {
 	var (
 		nameLis = []string{}
 	)
@@ -174,7 +328,7 @@ func parserSQL(m map[string]interface{}, prefix int, sep string) (sql string, va
 		v := m[k]
 
 		// 递归解析
-		if err = parserSQLOper(0, &prefix, sep, k, v, &nameLis, &vals); err != nil {
+		if err = parserSQLOper(0, &prefix, sep, text, k, v, &nameLis, &vals); err != nil {
 			return
 		}
 	}
@@ -183,7 +337,7 @@ func parserSQL(m map[string]interface{}, prefix int, sep string) (sql string, va
 }
 
 // parserSQLOper 解析操作符
-func parserSQLOper(deep int, prefix *int, sep string, k string, v interface{}, nameLis *[]string, vals *[]interface{}) (err error) {
+func parserSQLOper(deep int, prefix *int, sep string, text TextFunc, k string, v interface{}, nameLis *[]string, vals *[]interface{}) (err error) {
 	if deep+1 >= ParseMapMax {
 		err = ErrSongoMapDeepOutOf
 		return
@@ -231,7 +385,7 @@ func parserSQLOper(deep int, prefix *int, sep string, k string, v interface{}, n
 
 						//
 						for _, v3 := range _lis2 {
-							if err = parserSQLOper(deep+1, prefix, sep, org, v3, &_nameLis2, vals); err != nil {
+							if err = parserSQLOper(deep+1, prefix, sep, text, org, v3, &_nameLis2, vals); err != nil {
 								return
 							}
 						}
@@ -246,9 +400,9 @@ func parserSQLOper(deep int, prefix *int, sep string, k string, v interface{}, n
 						// break
 					default:
 						*prefix++
-						if _sql, _val, err2 := parserSQLUnit(k, v, *prefix, sep); err2 == nil {
+						if _sql, _val, err2 := parserSQLUnit(k, v, *prefix, sep, text); err2 == nil {
 							_nameLis = append(_nameLis, _sql)
-							*vals = append(*vals, _val)
+							parserSQLVal(prefix, vals, _val)
 						} else {
 							err = err2
 							return
@@ -260,9 +414,9 @@ func parserSQLOper(deep int, prefix *int, sep string, k string, v interface{}, n
 			default:
 				// string int 等
 				*prefix++
-				if _sql, _val, err2 := parserSQLUnit(k, _v, *prefix, sep); err2 == nil {
+				if _sql, _val, err2 := parserSQLUnit(k, _v, *prefix, sep, text); err2 == nil {
 					_nameLis = append(_nameLis, _sql)
-					*vals = append(*vals, _val)
+					parserSQLVal(prefix, vals, _val)
 				} else {
 					err = err2
 					return
@@ -283,11 +437,9 @@ func parserSQLOper(deep int, prefix *int, sep string, k string, v interface{}, n
 	default:
 		//
 		*prefix++
-		if _sql, _val, err2 := parserSQLUnit(k, v, *prefix, sep); err2 == nil {
+		if _sql, _val, err2 := parserSQLUnit(k, v, *prefix, sep, text); err2 == nil {
 			*nameLis = append(*nameLis, "("+_sql+")")
-			if _val != nil {
-				*vals = append(*vals, _val)
-			}
+			parserSQLVal(prefix, vals, _val)
 		} else {
 			err = err2
 			return
@@ -396,6 +396,137 @@ func Test_SongoParseMgo(t *testing.T) {
 	}
 }
 
+// in 与 nin
+func Test_SongoParseIn(t *testing.T) {
+	var (
+		mLis = []map[string]interface{}{
+			{
+				"age$in$": []interface{}{10, 20, 30},
+				"city":    "nanning",
+				"name":    "jack",
+			},
+			{
+				"status": map[string]interface{}{
+					TagQueryKeyNin: []string{"a", "b"},
+				},
+				orm.TagQueryKeyOr: []interface{}{
+					map[string]interface{}{"age$in$": []int{1, 2}},
+					map[string]interface{}{"age": 3},
+				},
+			},
+			{
+				"age$in$":     []interface{}{},
+				"name$nin$":   []interface{}{},
+				"status$gte$": 1,
+			},
+		}
+		sqlLis = []string{
+			`("age" IN ($1, $2, $3)) AND ("city" = $4) AND ("name" = $5)`,
+			`("age" IN ($1, $2) OR "age" = $3) AND ("status" NOT IN ($4, $5))`,
+			`(1 = 0) AND (1 = 1) AND ("status" >= $1)`,
+		}
+		mysqlLis = []string{
+			"(`age` IN (?, ?, ?)) AND (`city` = ?) AND (`name` = ?)",
+			"(`age` IN (?, ?) OR `age` = ?) AND (`status` NOT IN (?, ?))",
+			"(1 = 0) AND (1 = 1) AND (`status` >= ?)",
+		}
+		valLis = []string{
+			`[10,20,30,"nanning","jack"]`,
+			`[1,2,3,"a","b"]`,
+			`[1]`,
+		}
+	)
+	for i, m := range mLis {
+		if sql, vals, err := ParseSQL(m, 0); err != nil {
+			t.Fatalf("E%d %v", i+1, err)
+		} else if v, _ := json.Marshal(vals); sql != sqlLis[i] || string(v) != valLis[i] {
+			t.Fatalf("E%d %s <- %s", i+1, sql, string(v))
+		}
+		if sql, vals, err := ParseMysql(m, 0); err != nil {
+			t.Fatalf("E%d %v", i+1, err)
+		} else if v, _ := json.Marshal(vals); sql != mysqlLis[i] || string(v) != valLis[i] {
+			t.Fatalf("E%d %s <- %s", i+1, sql, string(v))
+		}
+	}
+
+	// 前缀序号
+	if sql, _, err := ParseSQL(map[string]interface{}{"age$in$": []int{1, 2}}, 3); err != nil {
+		t.Fatal(err)
+	} else if sql != `("age" IN ($4, $5))` {
+		t.Fatalf("prefix %s", sql)
+	}
+
+	// 非列表
+	for _, v := range []interface{}{1, "a", []byte("ab")} {
+		if _, _, err := ParseSQL(map[string]interface{}{"age$in$": v}, 0); err != ErrSongoFormatInvalid {
+			t.Fatalf("%v expect %v, get %v", v, ErrSongoFormatInvalid, err)
+		}
+	}
+
+	// mgo
+	if vals, err := ParseMgo(map[string]interface{}{
+		"age$in$":   []interface{}{1, 2},
+		"name$nin$": []interface{}{},
+	}); err != nil {
+		t.Fatal(err)
+	} else if v, _ := json.Marshal(vals); string(v) != `{"age":{"$in":[1,2]},"name":{"$nin":[]}}` {
+		t.Fatalf("mgo %s", string(v))
+	}
+}
+
+// 全文搜索
+func Test_SongoParseText(t *testing.T) {
+	var (
+		m = map[string]interface{}{
+			"title$text$": "hello world",
+			"state":       1,
+		}
+		vals = `[1,"hello world"]`
+	)
+	for i, c := range []struct {
+		parse func(map[string]interface{}, int) (string, []interface{}, error)
+		sql   string
+	}{
+		{ParseSQL, `("state" = $1) AND (to_tsvector('simple', "title") @@ plainto_tsquery('simple', $2))`},
+		{ParseMysql, "(`state` = ?) AND (MATCH (`title`) AGAINST (? IN NATURAL LANGUAGE MODE))"},
+		{ParseMssql, `("state" = $1) AND (FREETEXT("title", $2))`},
+		{func(m map[string]interface{}, prefix int) (string, []interface{}, error) {
+			return ParseSqlite(m, prefix, "article")
+		}, "(`state` = ?) AND (rowid IN (SELECT rowid FROM \"article_title_fts\" WHERE \"article_title_fts\" MATCH ?))"},
+		{func(m map[string]interface{}, prefix int) (string, []interface{}, error) {
+			return ParseSqlite(m, prefix, "")
+		}, "(`state` = ?) AND (`title` LIKE ?)"},
+	} {
+		if sql, v, err := c.parse(m, 0); err != nil {
+			t.Fatalf("E%d %v", i+1, err)
+		} else if b, _ := json.Marshal(v); sql != c.sql || string(b) != vals {
+			t.Fatalf("E%d %s <- %s", i+1, sql, string(b))
+		}
+	}
+
+	// 查找全文搜索条件
+	for i, q := range []map[string]interface{}{
+		m,
+		{"title": "$text$hello world"},
+		{"title": map[string]interface{}{TagValText: "hello world"}},
+		{TagQueryKeyAnd: []interface{}{map[string]interface{}{"state": 1}, m}},
+	} {
+		if k, v, ok := TextField(q); !ok || k != "title" || v != "hello world" {
+			t.Fatalf("E%d %s %v %v", i+1, k, v, ok)
+		}
+	}
+	if _, _, ok := TextField(map[string]interface{}{"title$like$": "%hello%"}); ok {
+		t.Fatal("like is not text")
+	}
+
+	// mgo
+	if d, err := ParseMgo(m); err != nil {
+		t.Fatal(err)
+	} else if b, _ := json.Marshal(d); string(b) != `{"$text":{"$search":"hello world"},"state":1}` {
+		t.Fatalf("mgo %s", string(b))
+	}
+}
+
 // 参数过滤
 func Test_SongoParseSafe(t *testing.T) {
 	whiteLis := map[string]interface{}{
@@ -448,3 +579,26 @@ func Test_SongoSortSafe(t *testing.T) {
 		t.Logf("SongoSortSafe: %v", res)
 	}
 }
+
+// nil值解析为IS NULL
+func Test_SongoParseNull(t *testing.T) {
+	for _, v := range []struct {
+		m   map[string]interface{}
+		sql string
+	}{
+		{map[string]interface{}{"deleted": nil}, `("deleted" IS NULL)`},
+		{map[string]interface{}{"deleted$ne$": nil}, `("deleted" IS NOT NULL)`},
+		{map[string]interface{}{"deleted": nil, "age$gt$": 1}, `("age" > $1) AND ("deleted" IS NULL)`},
+	} {
+		if sql, vals, err := ParseSQL(v.m, 0); err != nil {
+			t.Fatal(err)
+		} else if sql != v.sql {
+			t.Fatalf("%s <- %v", sql, vals)
+		}
+	}
+	if sql, vals, err := ParseMysql(map[string]interface{}{"deleted": nil, "age": 1}, 0); err != nil {
+		t.Fatal(err)
+	} else if sql != "(`age` = ?) AND (`deleted` IS NULL)" || len(vals) != 1 {
+		t.Fatalf("%s <- %v", sql, vals)
+	}
+}
@@ -15,7 +15,18 @@ import (
 // 将结构体同步至数据库
 var (
 	// RegFieldTag 匹配规则
-	RegFieldTag = regexp.MustCompile(`(\w+)(\(([\w,]+)\))?`)
+	RegFieldTag = regexp.MustCompile(`(\w+)(\((.*)\))?`)
+	// fkAction 外键动作标签值
+	fkAction = map[string]string{
+		"cascade":     "CASCADE",
+		"restrict":    "RESTRICT",
+		"set_null":    "SET NULL",
+		"setnull":     "SET NULL",
+		"set_default": "SET DEFAULT",
+		"setdefault":  "SET DEFAULT",
+		"no_action":   "NO ACTION",
+		"noaction":    "NO ACTION",
+	}
 )
 
 // FieldInfo 结构体中字段信息
@@ -33,9 +44,112 @@ type FieldInfo struct {
 	UniqueKeys  []string    //
 	Size        int         //
 	Precision   int         //
-	IndexText   bool        //
+	IndexText   bool        // 全文索引
 	DefaultVal  interface{} //
 	AllowNull   bool        //
+	Created     bool        // 插入时自动填充的时间字段
+	Updated     bool        // 插入及更新时自动刷新的时间字段
+	SoftDelete  bool        // 软删除标记字段, bool或时间; 指针为nil时视为未删除
+	Version     bool        // 乐观锁版本字段, 整数; 指针为nil时视为0
+	Was         string      // 改名前的字段名
+	Fk          *ForeignKey // 外键
+	NotNull     bool        // notnull标签
+	Default     string      // default标签的值, 原样写入DDL
+	Check       string      // 检查约束的表达式
+	Comment     string      // 字段注释
+}
+
+// ForeignKey 外键, 由fk(table.column,ondelete=cascade,onupdate=restrict)解析
+type ForeignKey struct {
+	Table    string // 引用的表
+	Column   string // 引用的字段
+	OnDelete string // 删除时的动作, 如CASCADE; 为空时取库的默认值
+	OnUpdate string // 更新时的动作
+}
+
+// ParseForeignKey 解析fk标签的参数
+func ParseForeignKey(v string) (fk *ForeignKey, err error) {
+	var (
+		lis = strings.Split(v, ",")
+		ref = strings.Split(lis[0], ".")
+	)
+	if len(ref) != 2 || len(ref[0]) == 0 || len(ref[1]) == 0 {
+		err = ErrSyncFkInvalid
+		return
+	}
+	fk = &ForeignKey{Table: ref[0], Column: ref[1]}
+	for _, s := range lis[1:] {
+		kv := strings.SplitN(s, "=", 2)
+		if len(kv) != 2 || len(fkAction[kv[1]]) == 0 {
+			err = ErrSyncFkInvalid
+			return
+		}
+		switch kv[0] {
+		case "ondelete":
+			fk.OnDelete = fkAction[kv[1]]
+		case "onupdate":
+			fk.OnUpdate = fkAction[kv[1]]
+		default:
+			err = ErrSyncFkInvalid
+			return
+		}
+	}
+	return
+}
+
+// ColumnDefine 按notnull/default标签改写驱动生成的DEFAULT及NULL子句def, 未设置标签时原样返回
+func ColumnDefine(f *FieldInfo, def string) string {
+	if (len(f.Default) == 0 && !f.NotNull) || f.Serial {
+		return def
+	}
+	dflt, null := f.Default, "NULL"
+	if len(dflt) == 0 {
+		if s := planDefaultRe.FindStringSubmatch(def); len(s) > 1 && !strings.EqualFold(s[1], "NULL") {
+			dflt = s[1]
+		}
+	}
+	if !f.AllowNull {
+		null = "NOT NULL"
+	}
+	if len(dflt) > 0 {
+		return "DEFAULT " + dflt + " " + null
+	}
+	return null
+}
+
+// tagSplit 按sep拆分标签, 括号内的sep不拆分, 如check(a > 0)
+func tagSplit(s string, sep rune) (lis []string) {
+	var (
+		depth = 0
+		start = 0
+	)
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+			}
+		case sep:
+			if depth == 0 {
+				lis = append(lis, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(lis, s[start:])
+}
+
+// Action 外键的ON DELETE/ON UPDATE子句
+func (fk *ForeignKey) Action() (s string) {
+	if len(fk.OnDelete) > 0 {
+		s += " ON DELETE " + fk.OnDelete
+	}
+	if len(fk.OnUpdate) > 0 {
+		s += " ON UPDATE " + fk.OnUpdate
+	}
+	return
 }
 
 // 将结构体中的字段转为map映射，供搜索用。目前只支持两层嵌套内的string TODO: 优化
@@ -97,6 +211,98 @@ func StructModelInfoNoPrimary(st interface{}) (res []*FieldInfo, err error) {
 	return structModelInfo(st, &res, &primary)
 }
 
+// UpsertFields 取upsert所需字段: 冲突字段, 插入字段, 冲突时更新的字段, 版本字段
+// keys为空时取非自增主键, 其次取第一个唯一索引; skip为不插入的自增字段; created字段冲突时不更新
+// version字段不在update中, 冲突时由驱动在原值上递增, 保证乐观锁不被覆盖
+func UpsertFields(st interface{}, keys []string, skip string) (conflict, columns, update []string, version string, err error) {
+	var (
+		fieldInfoLis []*FieldInfo
+		colMap       = make(map[string]bool)
+		keyMap       = make(map[string]bool)
+		createdMap   = make(map[string]bool)
+	)
+	if fieldInfoLis, err = StructModelInfo(st); err != nil {
+		return
+	}
+	for _, f := range fieldInfoLis {
+		if f.Name == skip || f.Serial {
+			continue
+		}
+		columns = append(columns, f.Name)
+		colMap[f.Name] = true
+		createdMap[f.Name] = f.Created
+		if f.Version && len(version) == 0 {
+			version = f.Name
+		}
+	}
+	// 默认冲突字段
+	if len(keys) == 0 {
+		for _, f := range fieldInfoLis {
+			if f.Primary && colMap[f.Name] {
+				keys = f.PrimaryKeys
+				break
+			}
+		}
+	}
+	if len(keys) == 0 {
+		for _, f := range fieldInfoLis {
+			if f.Unique && colMap[f.Name] {
+				keys = f.UniqueKeys
+				break
+			}
+		}
+	}
+	if len(keys) == 0 {
+		err = ErrUpsertKeyEmpty
+		return
+	}
+	for _, k := range keys {
+		if k = strings.ToLower(k); keyMap[k] {
+			continue
+		} else if !colMap[k] {
+			err = ErrUpsertKeyInvalid
+			return
+		}
+		keyMap[k] = true
+		conflict = append(conflict, k)
+	}
+	for _, c := range columns {
+		if !keyMap[c] && !createdMap[c] && c != version {
+			update = append(update, c)
+		}
+	}
+	return
+}
+
+// UpsertKeysUnique 冲突字段是否恰为主键或某个唯一索引的字段
+func UpsertKeysUnique(st interface{}, conflict []string) (ok bool) {
+	fieldInfoLis, err := StructModelInfo(st)
+	if err != nil {
+		return
+	}
+	keyMap := make(map[string]bool)
+	for _, k := range conflict {
+		keyMap[k] = true
+	}
+	same := func(keys []string) bool {
+		if len(keys) != len(keyMap) {
+			return false
+		}
+		for _, k := range keys {
+			if !keyMap[strings.ToLower(k)] {
+				return false
+			}
+		}
+		return true
+	}
+	for _, f := range fieldInfoLis {
+		if (f.Primary && same(f.PrimaryKeys)) || (f.Unique && same(f.UniqueKeys)) {
+			return true
+		}
+	}
+	return
+}
+
 //
 func structModelInfo(st interface{}, src *[]*FieldInfo, primary *string) (res []*FieldInfo, err error) {
 	if src != nil {
@@ -119,7 +325,8 @@ func structModelInfo(st interface{}, src *[]*FieldInfo, primary *string) (res []
 				fType = stType.Field(i) // 变量类型 如: {UID  string sorm:"size(36);unique" json:"uid" 8 [1] false}
 				fVal  = stVal.Field(i)  // 字段的默认值
 				info  = new(FieldInfo)
-				fKey  = strings.ToLower(fType.Tag.Get(OrmKey))     // key 如:size(36);unique
+				fTag  = fType.Tag.Get(OrmKey)                      // 标签原文, default/check/comment保留大小写
+				fKey  = strings.ToLower(fTag)                      // key 如:size(36);unique
 				dKey  = strings.Split(fType.Tag.Get("db"), ",")[0] // alias field name 使用db字眼的时候才有
 				c     = int(rune(fType.Name[0]))                   // 字段的首字母的ascii值
 			)
@@ -139,13 +346,13 @@ func structModelInfo(st interface{}, src *[]*FieldInfo, primary *string) (res []
 			// parser name of column
 			info.TableName = tbName
 			info.Name = strings.ToLower(fType.Name)
-			info.PrimaryKeys = []string{info.Name}
-			info.IndexKeys = []string{info.Name}
-			info.UniqueKeys = []string{info.Name}
 			// 如果dkey存在则以dkey为字段名
 			if len(dKey) > 0 {
 				info.Name = dKey
 			}
+			info.PrimaryKeys = []string{info.Name}
+			info.IndexKeys = []string{info.Name}
+			info.UniqueKeys = []string{info.Name}
 			//Log.Debugf(`[struct-info] %s %s`, info.Name, fType.Type.Kind().String())
 
 			// parser type of column  对字段的数据类型进行转换
@@ -256,14 +463,14 @@ func structModelInfo(st interface{}, src *[]*FieldInfo, primary *string) (res []
 			}
 
 			// parser tag
-			for _, vStr := range strings.Split(fKey, ";") { // key里面的处理 如: "index;size(36)"
-				for _, s := range strings.Split(vStr, " ") {
+			for _, vStr := range tagSplit(fTag, ';') { // key里面的处理 如: "index;size(36)"
+				for _, s := range tagSplit(vStr, ' ') {
 					r := RegFieldTag.FindStringSubmatch(s)
 					if len(r) == 0 {
 						continue
 					}
-					k := r[1]
-					v := r[3]
+					k := strings.ToLower(r[1])
+					v := strings.ToLower(r[3])
 
 					// fallback primary to unique
 					if (k == "primary") && (primary != nil) {
@@ -348,11 +555,58 @@ func structModelInfo(st interface{}, src *[]*FieldInfo, primary *string) (res []
 							info.Precision = -1 // 未定义精度
 						}
 						info.Kind = "decimal"
+					case "text":
+						info.IndexText = true
+					case "was":
+						info.Was = v
+					case "fk":
+						if info.Fk, err = ParseForeignKey(v); err != nil {
+							return
+						}
+					case "notnull":
+						info.NotNull = true
+						info.AllowNull = false
+					case "default":
+						info.Default = strings.TrimSpace(r[3])
+					case "check":
+						info.Check = strings.TrimSpace(r[3])
+					case "comment":
+						info.Comment = r[3]
+					case "created":
+						info.Created = info.Kind == "timestamp"
+					case "updated":
+						info.Updated = info.Kind == "timestamp"
+					case "version":
+						info.Version = info.Kind == "integer" || info.Kind == "bigint" || info.Kind == "unit"
+						if !info.Version {
+							err = ErrSyncTagKindInvalid
+							return
+						}
+					case "softdelete":
+						info.SoftDelete = info.Kind == "boolean" || info.Kind == "timestamp"
+						if !info.SoftDelete {
+							err = ErrSyncTagKindInvalid
+							return
+						}
 					default:
 					}
 				}
 			}
 
+			// 指针字段notnull且无default时以类型零值为默认值, 否则已有数据的表无法新增该字段
+			if info.NotNull && info.DefaultVal == nil && len(info.Default) == 0 {
+				switch info.Kind {
+				case "text", "varchar", "char":
+					info.DefaultVal = ""
+				case "integer", "float":
+					info.DefaultVal = 0
+				case "boolean":
+					info.DefaultVal = false
+				case "timestamp":
+					info.DefaultVal = DefaultTimeStr
+				}
+			}
+
 			// final
 			res = append(res, info)
 			//println(i, fType.Name, fType.Type.Kind().String(), info.Index)
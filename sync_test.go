@@ -40,3 +40,116 @@ func Test_Sync(t *testing.T) {
 		}
 	}
 }
+
+type upsertUser struct {
+	ID       int64  `sorm:"serial;primary"`
+	Username string `sorm:"size(36);unique"`
+	Email    string `sorm:"size(64);unique"`
+	Age      int
+}
+
+func Test_UpsertFields(t *testing.T) {
+	as := require.New(t)
+	// 自增主键不作为冲突字段, 回退到第一个唯一索引
+	conflict, columns, update, _, err := UpsertFields(&upsertUser{}, nil, "")
+	as.Nil(err)
+	as.Equal([]string{"username"}, conflict)
+	as.Equal([]string{"username", "email", "age"}, columns)
+	as.Equal([]string{"email", "age"}, update)
+	// 指定冲突字段
+	conflict, _, update, _, err = UpsertFields(&upsertUser{}, []string{"Email", "email"}, "")
+	as.Nil(err)
+	as.Equal([]string{"email"}, conflict)
+	as.Equal([]string{"username", "age"}, update)
+	// 非法字段
+	_, _, _, _, err = UpsertFields(&upsertUser{}, []string{"id"}, "")
+	as.Equal(ErrUpsertKeyInvalid, err)
+	_, _, _, _, err = UpsertFields(&student{}, nil, "")
+	as.Equal(ErrUpsertKeyEmpty, err)
+	// 版本字段不在更新列表中, 由驱动递增
+	type verUser struct {
+		Username string `sorm:"primary;size(36)"`
+		Age      int
+		Ver      int64 `sorm:"version"`
+	}
+	_, columns, update, version, err := UpsertFields(&verUser{}, nil, "")
+	as.Nil(err)
+	as.Equal([]string{"username", "age", "ver"}, columns)
+	as.Equal([]string{"age"}, update)
+	as.Equal("ver", version)
+	// 冲突字段需恰为主键或唯一索引
+	as.True(UpsertKeysUnique(&upsertUser{}, []string{"email"}))
+	as.False(UpsertKeysUnique(&upsertUser{}, []string{"age"}))
+	as.False(UpsertKeysUnique(&upsertUser{}, []string{"username", "email"}))
+}
+
+func Test_SyncForeignKey(t *testing.T) {
+	as := require.New(t)
+	type fkOrder struct {
+		UID     string `sorm:"primary;size(36)"`
+		UserUID string `sorm:"size(36);index;fk(fk_user.uid,ondelete=cascade,onupdate=set_null)"`
+		ShopID  int    `sorm:"fk(fk_shop.id)"`
+	}
+	lis, err := StructModelInfo(&fkOrder{})
+	as.Nil(err)
+	as.Nil(lis[0].Fk)
+	as.Equal(&ForeignKey{Table: "fk_user", Column: "uid", OnDelete: "CASCADE", OnUpdate: "SET NULL"}, lis[1].Fk)
+	as.True(lis[1].Index)
+	as.Equal(" ON DELETE CASCADE ON UPDATE SET NULL", lis[1].Fk.Action())
+	as.Equal(&ForeignKey{Table: "fk_shop", Column: "id"}, lis[2].Fk)
+	as.Equal("", lis[2].Fk.Action())
+	// 非法参数
+	for _, v := range []string{"fk_user", "fk_user.uid,ondelete=drop", "fk_user.uid,onmove=cascade", ".uid"} {
+		_, err = ParseForeignKey(v)
+		as.Equal(ErrSyncFkInvalid, err, v)
+	}
+}
+
+func Test_SyncColumnTags(t *testing.T) {
+	as := require.New(t)
+	type tagUser struct {
+		UID   string  `sorm:"primary;size(36)"`
+		Name  string  `sorm:"size(32);notnull;default('Guest');comment(user's name; shown)"`
+		Age   int     `sorm:"notnull;check(age >= 0 AND age < 200)"`
+		Score float64 `sorm:"default(1.5)"`
+		Note  *string `sorm:"notnull"`
+		Level int
+	}
+	lis, err := StructModelInfo(&tagUser{})
+	as.Nil(err)
+	as.Equal(6, len(lis))
+	as.True(lis[1].NotNull)
+	as.False(lis[1].AllowNull)
+	as.Equal(32, lis[1].Size)
+	as.Equal("'Guest'", lis[1].Default)
+	as.Equal("user's name; shown", lis[1].Comment)
+	as.Equal("age >= 0 AND age < 200", lis[2].Check)
+	as.Equal("1.5", lis[3].Default)
+
+	// DEFAULT及NULL子句
+	as.Equal("DEFAULT 'Guest' NOT NULL", ColumnDefine(lis[1], "DEFAULT '' NULL"))
+	as.Equal("DEFAULT 0 NOT NULL", ColumnDefine(lis[2], "DEFAULT 0 NULL"))
+	as.Equal("DEFAULT 1.5 NULL", ColumnDefine(lis[3], "DEFAULT '0' NULL"))
+	as.Equal("", lis[4].DefaultVal)
+	as.Equal("DEFAULT '' NOT NULL", ColumnDefine(lis[4], "DEFAULT '' NULL"))
+	as.Equal("DEFAULT 0 NULL", ColumnDefine(lis[5], "DEFAULT 0 NULL"))
+}
+
+func Test_SyncTextTag(t *testing.T) {
+	as := require.New(t)
+	type textArticle struct {
+		ID    int    `sorm:"primary;serial"`
+		Title string `sorm:"size(128);text"`
+		Body  string `sorm:"text"`
+		Tag   string `sorm:"index"`
+	}
+	lis, err := StructModelInfo(&textArticle{})
+	as.Nil(err)
+	as.True(lis[1].IndexText)
+	as.False(lis[1].Index)
+	as.Equal("varchar", lis[1].Kind)
+	as.Equal([]string{"title"}, lis[1].IndexKeys)
+	as.True(lis[2].IndexText)
+	as.Equal("text", lis[2].Kind)
+	as.False(lis[3].IndexText)
+}
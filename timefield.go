@@ -0,0 +1,95 @@
+package orm
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// 自动时间字段: `sorm:"created"`插入时填充, `sorm:"updated"`插入及更新时刷新
+
+// TimeFill 填充record中的时间字段, record为结构体指针或其切片
+// updated字段总是刷新; create为true时同时填充零值的created字段
+func TimeFill(record interface{}, create bool) {
+	if record == nil {
+		return
+	}
+	now := time.Now()
+	v := reflect.Indirect(reflect.ValueOf(record))
+	if v.Kind() == reflect.Slice {
+		for i := 0; i < v.Len(); i++ {
+			timeFillStruct(reflect.Indirect(v.Index(i)), now, create)
+		}
+		return
+	}
+	timeFillStruct(v, now, create)
+}
+
+// TimeFillMap map更新时补充表的updated字段, 已指定的字段不覆盖; 返回新的map
+func TimeFillMap(info *ModelInfo, m map[string]interface{}) map[string]interface{} {
+	if info == nil || len(info.Updated) == 0 {
+		return m
+	}
+	ret := make(map[string]interface{}, len(m)+1)
+	for k, v := range m {
+		ret[strings.ToLower(k)] = v
+	}
+	now := time.Now()
+	for _, f := range info.Updated {
+		if _, ok := ret[f]; !ok {
+			ret[f] = now
+		}
+	}
+	return ret
+}
+
+// timeFillStruct 按tag填充结构体的时间字段, 含继承的结构体
+func timeFillStruct(v reflect.Value, now time.Time, create bool) {
+	if v.Kind() != reflect.Struct || !v.CanSet() {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		var (
+			fType = t.Field(i)
+			fVal  = v.Field(i)
+		)
+		if fType.Anonymous {
+			timeFillStruct(reflect.Indirect(fVal), now, create)
+			continue
+		}
+		if len(fType.PkgPath) > 0 {
+			continue
+		}
+		created, updated := timeFieldTag(fType.Tag.Get(OrmKey))
+		if !(updated || (created && create)) {
+			continue
+		}
+		switch _v := fVal.Addr().Interface().(type) {
+		case *time.Time:
+			if updated || _v.IsZero() {
+				*_v = now
+			}
+		case **time.Time:
+			if updated || *_v == nil || (*_v).IsZero() {
+				_now := now
+				*_v = &_now
+			}
+		}
+	}
+}
+
+// timeFieldTag 解析tag中的created/updated
+func timeFieldTag(tag string) (created bool, updated bool) {
+	for _, vStr := range strings.Split(tag, ";") {
+		for _, s := range strings.Split(vStr, " ") {
+			switch strings.TrimSpace(s) {
+			case OrmTagCreated:
+				created = true
+			case OrmTagUpdated:
+				updated = true
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,51 @@
+package orm
+
+import (
+	"testing"
+	"time"
+)
+
+type timeRecord struct {
+	Name       string
+	CreateTime time.Time  `sorm:"created"`
+	UpdateTime *time.Time `sorm:"index;updated"`
+	OtherTime  time.Time
+}
+
+func TestTimeFill(t *testing.T) {
+	lis, err := StructModelInfo(&timeRecord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range lis {
+		if f.Created != (f.Name == "createtime") || f.Updated != (f.Name == "updatetime") {
+			t.Fatalf("%s created:%v updated:%v", f.Name, f.Created, f.Updated)
+		}
+	}
+
+	old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	recs := []timeRecord{{}, {CreateTime: old, UpdateTime: &old}}
+	TimeFill(&recs, true)
+	if recs[0].CreateTime.IsZero() || recs[0].UpdateTime == nil || !recs[0].OtherTime.IsZero() {
+		t.Fatalf("get %v", recs[0])
+	}
+	if recs[1].CreateTime != old || recs[1].UpdateTime.Year() == 2000 {
+		t.Fatalf("get %v", recs[1])
+	}
+	r := &timeRecord{}
+	if TimeFill(r, false); !r.CreateTime.IsZero() || r.UpdateTime == nil {
+		t.Fatalf("get %v", r)
+	}
+
+	var models ModelInfoMap
+	models.Register("time_record", lis)
+	if m := TimeFillMap(models.Get("time_record"), map[string]interface{}{"Name": "a"}); m["name"] != "a" || m["updatetime"] == nil {
+		t.Fatalf("get %v", m)
+	}
+	if m := TimeFillMap(models.Get("time_record"), map[string]interface{}{"UpdateTime": old}); m["updatetime"] != old {
+		t.Fatalf("get %v", m)
+	}
+	if m := TimeFillMap(models.Get("time_unknown"), map[string]interface{}{"Name": "a"}); len(m) != 1 || m["Name"] != "a" {
+		t.Fatalf("get %v", m)
+	}
+}
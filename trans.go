@@ -1,7 +1,10 @@
 package orm
 
 import (
+	"context"
 	"database/sql"
+	"regexp"
+	"time"
 )
 
 const (
@@ -13,6 +16,18 @@ const (
 	TransRepeatableRead = "Repeatable read"
 	// TransSerializable 串行化:可避免脏读、不可重复读、幻读的发生
 	TransSerializable = "Serializable"
+
+	// TransDeferred sqlite: 首次读写时才加锁
+	TransDeferred = "deferred"
+	// TransImmediate sqlite: 开启时即取写锁, 避免写入时才遇到SQLITE_BUSY
+	TransImmediate = "immediate"
+	// TransExclusive sqlite: 开启时即取排它锁
+	TransExclusive = "exclusive"
+)
+
+var (
+	// regSavepoint 保存点名称只允许字母数字及下划线
+	regSavepoint = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
 )
 
 // Trans 事务
@@ -26,7 +41,12 @@ type Trans interface {
 	Promise() []func(error)                    // func(error) 别的事件, error!=nil时会回滚
 	PromiseAdd(pfn ...func(error)) (err error) // 绑定commit和rollback时触发的函数
 	//
-	Exec(query string, args ...interface{}) (sql.Result, error) //
+	Exec(query string, args ...interface{}) (sql.Result, error)                             //
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) // 带上下文执行
+	//
+	Savepoint(name string) error  // 设置保存点
+	RollbackTo(name string) error // 回滚至保存点, 并清除事务中积累的错误
+	Release(name string) error    // 释放保存点
 
 	//DebugPush(info ...string) error            // DEBUG: 往事务中记录信息,方便出错时打印调试
 	// sqlx的注意方法
@@ -34,3 +54,106 @@ type Trans interface {
 	//Get(dest interface{}, query string, args ...interface{}) error    // 计数
 	//Select(dest interface{}, query string, args ...interface{}) error // 搜索
 }
+
+// TransBeginner 可开启事务, 如Model及Database
+type TransBeginner interface {
+	BeginWith(opt *ArgTrans) (Trans, error)
+}
+
+// ArgRetry RunInTrans的参数
+type ArgRetry struct {
+	Trans       *ArgTrans                    // 开启事务的参数
+	MaxAttempts int                          // 最多执行次数, 默认TransRetryMax
+	Backoff     time.Duration                // 首次重试前的等待, 之后逐次翻倍, 默认TransRetryBackoff
+	OnRetry     func(attempt int, err error) // 重试前回调, attempt为已执行的次数
+}
+
+// RunInTrans 开启事务执行fn并提交, fn返回错误时回滚; 遇到序列化失败或死锁时重新开启事务执行
+func RunInTrans(b TransBeginner, opt *ArgRetry, fn func(t Trans) error) (err error) {
+	var (
+		arg     *ArgTrans
+		maxTry  = TransRetryMax
+		backoff = TransRetryBackoff
+		retry   bool
+	)
+	if opt != nil {
+		arg = opt.Trans
+		if opt.MaxAttempts > 0 {
+			maxTry = opt.MaxAttempts
+		}
+		if opt.Backoff > 0 {
+			backoff = opt.Backoff
+		}
+	}
+	for attempt := 1; ; attempt++ {
+		if retry, err = runInTrans(b, arg, fn); err == nil || !retry || attempt >= maxTry {
+			return
+		}
+		if opt != nil && opt.OnRetry != nil {
+			opt.OnRetry(attempt, err)
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// runInTrans 执行一次, retry表示错误可重试
+func runInTrans(b TransBeginner, arg *ArgTrans, fn func(t Trans) error) (retry bool, err error) {
+	var t Trans
+	if t, err = b.BeginWith(arg); err != nil {
+		return TransRetryable(err), err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			_ = t.Rollback()
+			panic(r)
+		}
+	}()
+	if err = fn(t); err != nil {
+		// fn可能未原样返回驱动的错误, 以事务中记录的错误判断
+		retry = TransRetryable(err) || TransRetryable(t.Error())
+		if t.Error() == nil {
+			t.ErrorSet(err)
+		}
+		_ = t.Rollback()
+		return
+	}
+	if err = t.Commit(); err != nil {
+		retry = TransRetryable(err)
+	}
+	return
+}
+
+// TransRetryable 错误是否可重试, 由驱动注册HookTransRetryable判断
+func TransRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, fn := range HookTransRetryable {
+		if fn(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// DuplicateKey 错误是否为主键或唯一索引冲突, 由驱动注册HookDuplicateKey判断
+func DuplicateKey(err error) bool {
+	if err == nil {
+		return false
+	}
+	for _, fn := range HookDuplicateKey {
+		if fn(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// SavepointCheck 检查保存点名称, 名称会直接写入语句
+func SavepointCheck(name string) error {
+	if !regSavepoint.MatchString(name) {
+		return ErrTransSavepointInvalid
+	}
+	return nil
+}
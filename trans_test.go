@@ -0,0 +1,97 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTestRetry = errors.New("test: deadlock")
+
+// testTrans 记录提交及回滚的事务
+type testTrans struct {
+	err       error
+	commitErr error
+	commit    int
+	rollback  int
+}
+
+func (t *testTrans) Commit() error {
+	t.commit++
+	if t.err != nil {
+		return t.err
+	}
+	return t.commitErr
+}
+func (t *testTrans) Rollback() error                                 { t.rollback++; return nil }
+func (t *testTrans) Error() error                                    { return t.err }
+func (t *testTrans) ErrorSet(err error)                              { t.err = err }
+func (t *testTrans) Promise() []func(error)                          { return nil }
+func (t *testTrans) PromiseAdd(pfn ...func(error)) error             { return nil }
+func (t *testTrans) Savepoint(name string) error                     { return nil }
+func (t *testTrans) RollbackTo(name string) error                    { return nil }
+func (t *testTrans) Release(name string) error                       { return nil }
+func (t *testTrans) Exec(string, ...interface{}) (sql.Result, error) { return nil, nil }
+func (t *testTrans) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, nil
+}
+
+type testBeginner struct {
+	lis       []*testTrans
+	commitErr error
+}
+
+func (b *testBeginner) BeginWith(opt *ArgTrans) (Trans, error) {
+	t := &testTrans{commitErr: b.commitErr}
+	b.lis = append(b.lis, t)
+	return t, nil
+}
+
+func TestRunInTrans(t *testing.T) {
+	HookTransRetryable["test"] = func(err error) bool { return err == errTestRetry }
+	defer delete(HookTransRetryable, "test")
+
+	// 前两次死锁, 第三次提交
+	var (
+		b     = &testBeginner{}
+		retry []int
+		opt   = &ArgRetry{Backoff: time.Millisecond, OnRetry: func(attempt int, err error) {
+			retry = append(retry, attempt)
+		}}
+	)
+	if err := RunInTrans(b, opt, func(tx Trans) error {
+		if len(b.lis) < 3 {
+			tx.ErrorSet(errTestRetry)
+			return errors.New("wrapped")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(b.lis) != 3 || len(retry) != 2 || retry[1] != 2 {
+		t.Fatalf("attempts %d retry %v", len(b.lis), retry)
+	}
+	if b.lis[0].rollback != 1 || b.lis[2].commit != 1 || b.lis[2].rollback != 0 {
+		t.Fatalf("trans %+v %+v", b.lis[0], b.lis[2])
+	}
+
+	// 不可重试的错误
+	b = &testBeginner{}
+	if err := RunInTrans(b, nil, func(tx Trans) error { return ErrMatchNone }); err != ErrMatchNone {
+		t.Fatal(err)
+	} else if len(b.lis) != 1 || b.lis[0].rollback != 1 {
+		t.Fatalf("attempts %d", len(b.lis))
+	}
+
+	// 提交时失败, 达到次数上限
+	b = &testBeginner{commitErr: errTestRetry}
+	if err := RunInTrans(b, &ArgRetry{MaxAttempts: 2, Backoff: time.Millisecond}, func(tx Trans) error {
+		return nil
+	}); err != errTestRetry {
+		t.Fatal(err)
+	} else if len(b.lis) != 2 {
+		t.Fatalf("attempts %d", len(b.lis))
+	}
+}
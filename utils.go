@@ -3,10 +3,12 @@ package orm
 import (
 	"github.com/shopspring/decimal"
 
+	"context"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strings"
 	"time"
 )
@@ -23,6 +25,10 @@ var (
 	NormalBeginData = time.Date(1950, 1, 1, 0, 0, 0, 0, time.Now().Location())
 	// ZoneOffset 时差标记(服务器)
 	ZoneOffset = ""
+	// RegAggregateExpr 聚合表达式 sum(amount) count(*)
+	RegAggregateExpr = regexp.MustCompile(`^\s*(\w+)\s*\(\s*(\*|\w+)\s*\)\s*$`)
+	// RegAggregateName 聚合的字段或别名
+	RegAggregateName = regexp.MustCompile(`^\w+$`)
 	// CfgFloatAutoRound 浮点数默认保留位数
 	CfgFloatAutoRound int32 = 8
 )
@@ -155,6 +161,185 @@ func JSONMust(inf interface{}) (r string) {
 	return
 }
 
+// ContextErr 上下文已取消或超时的情况下, 将驱动返回的错误统一为ErrContextCanceled
+func ContextErr(ctx context.Context, err error) error {
+	if err == nil || err == ErrContextCanceled {
+		return err
+	}
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return ErrContextCanceled
+	}
+	if ctx != nil && ctx.Err() != nil {
+		return ErrContextCanceled
+	}
+	return err
+}
+
+// QueryAnd 以$and$合并两个搜索条件, 游标及软删除条件追加到原搜索条件时使用
+func QueryAnd(query M, cond M) M {
+	if len(query) == 0 {
+		return cond
+	}
+	if len(cond) == 0 {
+		return query
+	}
+	return M{TagQueryKeyAnd: []interface{}{map[string]interface{}(query), map[string]interface{}(cond)}}
+}
+
+// FieldsSelect 按Fields/Omit计算要查询的字段, 返回nil表示不限制
+// 未指定Fields时以dest结构体的字段为基础排除Omit, dest非结构体时Omit不生效; 字段名统一小写
+func FieldsSelect(fields []string, omit []string, dest interface{}) (ret []string) {
+	if len(fields) == 0 && len(omit) == 0 {
+		return
+	}
+	if len(fields) == 0 {
+		if lis, err := StructModelInfoByDest(dest); err == nil {
+			for _, f := range lis {
+				fields = append(fields, f.Name)
+			}
+		}
+	}
+	omitMap := make(map[string]bool)
+	for _, f := range omit {
+		omitMap[strings.ToLower(f)] = true
+	}
+	for _, f := range fields {
+		if f = strings.ToLower(f); !omitMap[f] {
+			ret = append(ret, f)
+		}
+	}
+	return
+}
+
+// AggregateField 聚合项
+type AggregateField struct {
+	Alias string // 结果字段名
+	Func  string // 聚合函数: sum avg min max count
+	Field string // 字段, count时可为*
+}
+
+// AggregateParse 解析聚合定义{别名: "sum(字段)"}, 按别名排序; 字段名统一小写
+func AggregateParse(aggs map[string]string) (ret []*AggregateField, err error) {
+	if len(aggs) == 0 {
+		return nil, ErrAggregateInvalid
+	}
+	for alias, expr := range aggs {
+		var f *AggregateField
+		if f, err = AggregateFieldNew(alias, expr); err != nil {
+			return nil, err
+		}
+		ret = append(ret, f)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Alias < ret[j].Alias })
+	return
+}
+
+// AggregateFieldNew 解析单个聚合表达式
+func AggregateFieldNew(alias string, expr string) (ret *AggregateField, err error) {
+	lis := RegAggregateExpr.FindStringSubmatch(expr)
+	if lis == nil || !RegAggregateName.MatchString(alias) {
+		return nil, ErrAggregateInvalid
+	}
+	ret = &AggregateField{Alias: strings.ToLower(alias), Func: strings.ToLower(lis[1]), Field: strings.ToLower(lis[2])}
+	switch ret.Func {
+	case AggSum, AggAvg, AggMin, AggMax:
+		if ret.Field == "*" {
+			return nil, ErrAggregateInvalid
+		}
+	case AggCount:
+		break
+	default:
+		return nil, ErrAggregateInvalid
+	}
+	return
+}
+
+// AggregateGroupBy 校验分组字段, 字段名统一小写
+func AggregateGroupBy(groupBy []string) (ret []string, err error) {
+	for _, f := range groupBy {
+		if !RegAggregateName.MatchString(f) {
+			return nil, ErrAggregateInvalid
+		}
+		ret = append(ret, strings.ToLower(f))
+	}
+	return
+}
+
+// AggregateSorts 校验分组聚合的排序字段, 只能是分组字段或聚合别名
+func AggregateSorts(sorts []string, groupBy []string, lis []*AggregateField) (err error) {
+	for _, s := range sorts {
+		s = strings.ToLower(strings.TrimLeft(strings.TrimSpace(s), "+-"))
+		if len(s) == 0 {
+			continue
+		}
+		ok := false
+		for _, f := range groupBy {
+			ok = ok || f == s
+		}
+		for _, a := range lis {
+			ok = ok || a.Alias == s
+		}
+		if !ok {
+			return ErrAggregateSortInvalid
+		}
+	}
+	return
+}
+
+// MapScanner 可逐行读取为map的结果集, 如*sqlx.Rows
+type MapScanner interface {
+	Next() bool
+	MapScan(dest map[string]interface{}) error
+	Err() error
+}
+
+// IsMapDest 判断接收者是否为map: *M *[]M *map[string]interface{} *[]map[string]interface{}
+func IsMapDest(dest interface{}) bool {
+	switch dest.(type) {
+	case *M, *[]M, *map[string]interface{}, *[]map[string]interface{}:
+		return true
+	}
+	return false
+}
+
+// ScanMaps 将结果集读入map接收者, 单个map只读第一行; []byte值转为string
+func ScanMaps(rows MapScanner, dest interface{}) (n int, err error) {
+	single := false
+	switch dest.(type) {
+	case *M, *map[string]interface{}:
+		single = true
+	}
+	for rows.Next() {
+		m := make(map[string]interface{})
+		if err = rows.MapScan(m); err != nil {
+			return
+		}
+		for k, v := range m {
+			if b, ok := v.([]byte); ok {
+				m[k] = string(b)
+			}
+		}
+		n++
+		switch d := dest.(type) {
+		case *M:
+			*d = m
+		case *map[string]interface{}:
+			*d = m
+		case *[]M:
+			*d = append(*d, m)
+		case *[]map[string]interface{}:
+			*d = append(*d, m)
+		}
+		if single {
+			break
+		}
+	}
+	if err = rows.Err(); err == nil && n == 0 && single {
+		err = ErrMatchNone
+	}
+	return
+}
+
 // 解析并创建一个实例
 func ReflectElemNew(a interface{}) (ret interface{}) {
 	if t := reflectElemType(a); t != nil {
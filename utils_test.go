@@ -3,6 +3,8 @@ package orm
 import (
 	"testing"
 
+	"context"
+	"errors"
 	"time"
 )
 
@@ -57,3 +59,67 @@ func TestJSONMust(t *testing.T) {
 	}
 	t.Log(JSONMust(a))
 }
+
+//
+func TestContextErr(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	errOther := errors.New("other")
+	if err := ContextErr(ctx, errOther); err != errOther {
+		t.Fatalf("get %v", err)
+	}
+	if err := ContextErr(ctx, nil); err != nil {
+		t.Fatalf("get %v", err)
+	}
+	cancel()
+	if err := ContextErr(ctx, errOther); err != ErrContextCanceled {
+		t.Fatalf("get %v", err)
+	}
+	if err := ContextErr(nil, context.DeadlineExceeded); err != ErrContextCanceled {
+		t.Fatalf("get %v", err)
+	}
+}
+
+func TestFieldsSelect(t *testing.T) {
+	type user struct {
+		Username string
+		Amount   float64
+		Balance  float64
+	}
+	if ret := FieldsSelect(nil, nil, &user{}); ret != nil {
+		t.Fatalf("get %v", ret)
+	}
+	if ret := FieldsSelect([]string{"Username", "amount"}, []string{"amount"}, nil); len(ret) != 1 || ret[0] != "username" {
+		t.Fatalf("get %v", ret)
+	}
+	if ret := FieldsSelect(nil, []string{"amount"}, &[]*user{}); len(ret) != 2 || ret[1] != "balance" {
+		t.Fatalf("get %v", ret)
+	}
+}
+
+func TestAggregateParse(t *testing.T) {
+	if lis, err := AggregateParse(map[string]string{"total": "SUM( amount )", "num": "count(*)"}); err != nil {
+		t.Fatal(err)
+	} else if len(lis) != 2 || lis[0].Alias != "num" || lis[1].Func != AggSum || lis[1].Field != "amount" {
+		t.Fatalf("get %v", JSONMust(lis))
+	}
+	for _, expr := range []string{"sum(*)", "median(amount)", "sum(a,b)", "sum(amount);drop", ""} {
+		if _, err := AggregateParse(map[string]string{"v": expr}); err != ErrAggregateInvalid {
+			t.Fatalf("%s get %v", expr, err)
+		}
+	}
+	if _, err := AggregateParse(map[string]string{"a b": "sum(amount)"}); err != ErrAggregateInvalid {
+		t.Fatalf("get %v", err)
+	}
+}
+
+func TestAggregateSorts(t *testing.T) {
+	lis, _ := AggregateParse(map[string]string{"total": "sum(amount)"})
+	if err := AggregateSorts([]string{"-Total", "+balance"}, []string{"balance"}, lis); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"amount", "-$text$"} {
+		if err := AggregateSorts([]string{s}, []string{"balance"}, lis); err != ErrAggregateSortInvalid {
+			t.Fatalf("%s get %v", s, err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package orm
+
+import (
+	"reflect"
+	"strings"
+)
+
+// VersionQuery 按结构体更新时给query追加版本条件, 未定义version字段或record非结构体时ok为false
+func VersionQuery(info *ModelInfo, query M, record interface{}) (ret M, ok bool) {
+	if info == nil || info.Version == nil {
+		return query, false
+	}
+	v := versionField(reflect.ValueOf(record), info.Version.Name)
+	if !v.IsValid() {
+		return query, false
+	}
+	// 指针字段NULL视为0, 为nil或0时同时匹配NULL
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() || v.Elem().IsZero() {
+			return QueryAnd(query, M{TagQueryKeyOr: []interface{}{
+				map[string]interface{}{info.Version.Name: nil}, map[string]interface{}{info.Version.Name: 0}}}), true
+		}
+		v = v.Elem()
+	}
+	return QueryAnd(query, M{info.Version.Name: v.Interface()}), true
+}
+
+// VersionAdd 将record中的版本号加n, record需为结构体指针
+func VersionAdd(info *ModelInfo, record interface{}, n int64) {
+	if info == nil || info.Version == nil {
+		return
+	}
+	v := versionField(reflect.ValueOf(record), info.Version.Name)
+	if !v.IsValid() || !v.CanSet() {
+		return
+	}
+	// 指针为nil时视为0, 与数据库中COALESCE一致
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(v.Int() + n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(int64(v.Uint()) + n))
+	}
+}
+
+// versionField 按字段名(同StructModelInfo)查找结构体中的版本字段, 含继承的结构体
+func versionField(v reflect.Value, name string) (ret reflect.Value) {
+	v = reflect.Indirect(v)
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		fType := t.Field(i)
+		if fType.Anonymous {
+			if ret = versionField(v.Field(i), name); ret.IsValid() {
+				return
+			}
+			continue
+		}
+		if len(fType.PkgPath) > 0 {
+			continue
+		}
+		fName := strings.ToLower(fType.Name)
+		if dKey := strings.Split(fType.Tag.Get("db"), ",")[0]; len(dKey) > 0 {
+			fName = dKey
+		}
+		if fName == name {
+			return v.Field(i)
+		}
+	}
+	return
+}
@@ -0,0 +1,57 @@
+package orm
+
+import (
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	var models ModelInfoMap
+	type Base struct {
+		Ver int64 `db:"rev" sorm:"version"`
+	}
+	type verRecord struct {
+		Name string
+		Base
+	}
+	lis, err := StructModelInfo(&verRecord{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	models.Register("ver_record", lis)
+
+	r := &verRecord{Name: "a", Base: Base{Ver: 3}}
+	if m, ok := VersionQuery(models.Get("ver_record"), M{"name": "a"}, r); !ok || len(m[TagQueryKeyAnd].([]interface{})) != 2 {
+		t.Fatalf("get %v %v", m, ok)
+	}
+	if VersionAdd(models.Get("ver_record"), r, 1); r.Ver != 4 {
+		t.Fatalf("get %v", r.Ver)
+	}
+	if m, ok := VersionQuery(models.Get("ver_record"), nil, map[string]interface{}{"name": "a"}); ok || m != nil {
+		t.Fatalf("get %v %v", m, ok)
+	}
+	if _, ok := VersionQuery(models.Get("ver_unknown"), nil, r); ok {
+		t.Fatal("expect undefined")
+	}
+
+	// 指针版本号, nil视为0
+	type ptrRecord struct {
+		Ver *int `sorm:"version"`
+	}
+	if lis, err = StructModelInfo(&ptrRecord{}); err != nil {
+		t.Fatal(err)
+	}
+	models.Register("ver_ptr", lis)
+	p := &ptrRecord{}
+	if m, ok := VersionQuery(models.Get("ver_ptr"), nil, p); !ok || len(m[TagQueryKeyOr].([]interface{})) != 2 {
+		t.Fatalf("get %v %v", m, ok)
+	}
+	if VersionAdd(models.Get("ver_ptr"), p, 1); p.Ver == nil || *p.Ver != 1 {
+		t.Fatalf("get %v", p.Ver)
+	}
+	if m, _ := VersionQuery(models.Get("ver_ptr"), nil, p); m["ver"] != 1 {
+		t.Fatalf("get %v", m)
+	}
+	if VersionAdd(models.Get("ver_ptr"), p, 1); *p.Ver != 2 {
+		t.Fatalf("get %v", *p.Ver)
+	}
+}